@@ -7,6 +7,8 @@ import matplotlib.pyplot as plt
 import torchvision
 import torch.nn as nn
 import hashlib
+import re
+import secrets
 import torch.optim as optim
 from flask import Flask, request, jsonify, send_file
 from werkzeug.utils import secure_filename
@@ -31,6 +33,13 @@ MODELS_FOLDER = 'saved_models'
 os.makedirs(SAMPLES_FOLDER, exist_ok=True)
 os.makedirs(MODELS_FOLDER, exist_ok=True)
 
+# Owners (a username, or '@' and an organisation) and model names become
+# directories under user_data; a block from a peer may carry any value
+SAFE_NAME = re.compile(r'^@?[A-Za-z0-9_-]+$')
+
+def safe_names(username, model_name):
+    return bool(SAFE_NAME.match(username)) and bool(SAFE_NAME.match(model_name)) and not model_name.startswith('@')
+
 # -------------------------------
 #   DCGAN Generator
 # -------------------------------
@@ -108,6 +117,8 @@ def train_model():
     model_name = request.form.get('model_name')
     if not username or not model_name:
         return jsonify({'error': 'Username and model_name are required'}), 400
+    if not safe_names(username, model_name):
+        return jsonify({'error': "username and model_name may only hold letters, digits, '-' and '_'"}), 400
 
     # Define user-specific folders under /user_data
     base_path = os.path.join('user_data', username, model_name)
@@ -115,12 +126,6 @@ def train_model():
     samples_folder = os.path.join(base_path, 'generated_samples')
     models_folder = os.path.join(base_path, 'saved_models')
 
-    # Clear and recreate the folders
-    for folder in [upload_folder, samples_folder, models_folder]:
-        if os.path.exists(folder):
-            shutil.rmtree(folder)
-        os.makedirs(folder, exist_ok=True)
-
     # Save uploaded images
     if 'images' not in request.files:
         return jsonify({'error': 'No images provided'}), 400
@@ -129,9 +134,24 @@ def train_model():
     if len(images) == 0:
         return jsonify({'error': 'No images provided'}), 400
 
-    for img in images:
-        filename = secure_filename(img.filename)
-        img.save(os.path.join(upload_folder, filename))
+    # Two uploads saved under one name would train on one of them
+    filenames = [secure_filename(img.filename) for img in images]
+    if '' in filenames or len(set(filenames)) != len(filenames):
+        return jsonify({'error': 'Every image needs a distinct file name'}), 400
+
+    # Clear and recreate the folders
+    for folder in [upload_folder, samples_folder, models_folder]:
+        if os.path.exists(folder):
+            shutil.rmtree(folder)
+        os.makedirs(folder, exist_ok=True)
+
+    # Hash every upload as saved; the dataset root of the training proof
+    # commits to these hashes
+    image_hashes = []
+    for img, filename in zip(images, filenames):
+        img_path = os.path.join(upload_folder, filename)
+        img.save(img_path)
+        image_hashes.append(file_sha256(img_path))
 
     # Get hyperparameters from the request
     epochs = request.form.get('epochs', type=int, default=100)
@@ -141,6 +161,11 @@ def train_model():
     n_critic = request.form.get('n_critic', type=int, default=5)
     lambda_gp = request.form.get('lambda_gp', type=float, default=10)
 
+    # The seed of the proof sample; random unless the caller picks one
+    seed = request.form.get('seed', type=int)
+    if seed is None:
+        seed = secrets.randbits(63)
+
     # Transform and dataset
     transform = transforms.Compose([
         transforms.Resize((64, 64)),
@@ -200,29 +225,109 @@ def train_model():
             print(f"Saved generated samples to {save_path}")
 
     # Save the trained models
-    torch.save(generator.state_dict(), os.path.join(models_folder, 'generator.pth'))
+    generator_path = os.path.join(models_folder, 'generator.pth')
+    torch.save(generator.state_dict(), generator_path)
     torch.save(discriminator.state_dict(), os.path.join(models_folder, 'discriminator.pth'))
 
-    # Generate AI proof using the generated images (e.g., hash of the first sample image)
-    ai_proof = generate_ai_proof(generator)
+    # Generate the proof sample from the saved weights, exactly as
+    # /regenerate will
+    generator = load_generator(generator_path)
+    ai_proof = sample_hash(generator, seed)
 
     return jsonify({
         'message': 'Training complete',
         'epochs': epochs,
-        'generator_model': os.path.join(models_folder, 'generator.pth'),
+        'generator_model': generator_path,
         'discriminator_model': os.path.join(models_folder, 'discriminator.pth'),
-        'ai_proof': ai_proof
+        'ai_proof': ai_proof,
+        'training_proof': {
+            'weights_hash': file_sha256(generator_path),
+            'dataset_root': dataset_root(image_hashes),
+            'seed': seed,
+            'hyperparameters': {
+                'epochs': epochs,
+                'latent_dim': latent_dim,
+                'batch_size': batch_size,
+                'lr': lr,
+                'n_critic': n_critic,
+                'lambda_gp': lambda_gp,
+            },
+            'sample_hash': ai_proof,
+        },
     })
-    
-def generate_ai_proof(generator):
-    # Generate a sample image (for proof generation)
-    z = torch.randn(1, 100, 1, 1, device=device)  # Latent vector for 1 sample
-    generated_img = generator(z).detach().cpu()
-    
-    # Convert to a byte array and hash the image (for unique proof)
-    img = generated_img.squeeze(0).permute(1, 2, 0).numpy()  # Convert to HxWxC
-    img_hash = hashlib.sha256(img.tobytes()).hexdigest()  # Create a SHA-256 hash for proof
-    return img_hash
+
+@app.route('/regenerate', methods=['POST'])
+def regenerate_sample():
+    # Regenerate the proof sample of a trained model for go-backend's
+    # verifier: it compares both hashes with the block's training proof
+    username = request.form.get('username')
+    model_name = request.form.get('model_name')
+    seed = request.form.get('seed', type=int)
+    if not username or not model_name or seed is None:
+        return jsonify({'error': 'username, model_name and seed are required'}), 400
+    if not safe_names(username, model_name):
+        return jsonify({'error': "username and model_name may only hold letters, digits, '-' and '_'"}), 400
+
+    generator_path = os.path.join('user_data', username, model_name, 'saved_models', 'generator.pth')
+    if not os.path.exists(generator_path):
+        return jsonify({'error': f'Generator model not found at {generator_path}'}), 404
+
+    generator = load_generator(generator_path)
+    return jsonify({
+        'weights_hash': file_sha256(generator_path),
+        'sample_hash': sample_hash(generator, seed),
+    })
+
+# -------------------------------
+#   Training Proof
+# -------------------------------
+def file_sha256(path):
+    h = hashlib.sha256()
+    with open(path, 'rb') as f:
+        for chunk in iter(lambda: f.read(1 << 16), b''):
+            h.update(chunk)
+    return h.hexdigest()
+
+def dataset_root(image_hashes):
+    # Merkle root as go-backend's blockchain.DatasetRoot computes it: leaves
+    # are SHA-256(0x00 | hex digest) over the sorted digests, inner nodes
+    # SHA-256(0x01 | left | right), and a node without a sibling moves up
+    # unchanged. No images give 32 zero bytes.
+    level = [hashlib.sha256(b'\x00' + h.encode()).digest() for h in sorted(image_hashes)]
+    if not level:
+        return (b'\x00' * 32).hex()
+    while len(level) > 1:
+        nxt = []
+        for i in range(0, len(level), 2):
+            if i + 1 == len(level):
+                nxt.append(level[i])
+            else:
+                nxt.append(hashlib.sha256(b'\x01' + level[i] + level[i + 1]).digest())
+        level = nxt
+    return level[0].hex()
+
+def load_generator(generator_path):
+    # Load saved generator weights on the CPU; the latent size is read from
+    # the weights rather than assumed
+    state = torch.load(generator_path, map_location='cpu')
+    latent_dim = state['net.0.weight'].shape[0]
+    generator = DCGANGenerator(latent_dim=latent_dim)
+    generator.load_state_dict(state)
+    generator.eval()
+    return generator
+
+def sample_hash(generator, seed):
+    # SHA-256 of the 8-bit sample the generator produces for seed. It runs
+    # on the CPU in eval mode with its own random generator, so the same
+    # weights and seed always give the same bytes.
+    latent_dim = generator.net[0].in_channels
+    rng = torch.Generator().manual_seed(seed)
+    z = torch.randn(1, latent_dim, 1, 1, generator=rng)
+    with torch.no_grad():
+        img = generator(z)
+    img = img.squeeze(0).permute(1, 2, 0).numpy()  # Convert to HxWxC
+    img = ((img + 1) * 127.5).astype('uint8')  # Denormalize to [0, 255]
+    return hashlib.sha256(img.tobytes()).hexdigest()
 
 @app.route('/generate', methods=['POST'])
 def generate_image():
@@ -234,6 +339,8 @@ def generate_image():
 
         if not username or not model_name or not block_hash:
             return jsonify({"error": "username, model_name, and block_hash are required"}), 400
+        if not safe_names(username, model_name):
+            return jsonify({"error": "username and model_name may only hold letters, digits, '-' and '_'"}), 400
 
         # Path to the generator model
         generator_path = os.path.join('user_data', username, model_name, 'saved_models', 'generator.pth')
@@ -246,8 +353,14 @@ def generate_image():
         generator.load_state_dict(torch.load(generator_path, map_location=device))
         generator.eval()
 
-        # Generate a random latent vector
-        z = torch.randn(1, latent_dim, 1, 1, device=device)
+        # Draw the latent vector from seed when go-backend sends one, on the
+        # CPU as sample_hash does, so the seed it records reproduces the image
+        seed = request.form.get('seed', type=int)
+        if seed is not None:
+            rng = torch.Generator().manual_seed(seed)
+            z = torch.randn(1, latent_dim, 1, 1, generator=rng).to(device)
+        else:
+            z = torch.randn(1, latent_dim, 1, 1, device=device)
 
         # Generate an image
         with torch.no_grad():
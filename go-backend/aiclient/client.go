@@ -0,0 +1,192 @@
+package aiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
+
+// Client talks to the Python AI service (Combine/ai) over its REST API
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, HTTPClient: &http.Client{}}
+}
+
+// Train uploads the images and trains a model for owner, returning the
+// training proof the AI service reports for the run
+func (c *Client) Train(ctx context.Context, filePaths []string, epochs, owner, modelName string) (blockchain.TrainingProof, error) {
+	var proof blockchain.TrainingProof
+
+	// Create a buffer to hold the multipart form data
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	// Add all files to the form
+	for _, filePath := range filePaths {
+		if err := addFile(writer, "images", filePath); err != nil {
+			return proof, err
+		}
+	}
+
+	// Add the epochs, username, and model_name to the form
+	if err := writer.WriteField("epochs", epochs); err != nil {
+		return proof, err
+	}
+	if err := writer.WriteField("username", owner); err != nil {
+		return proof, err
+	}
+	if err := writer.WriteField("model_name", modelName); err != nil {
+		return proof, err
+	}
+
+	// Close the writer to finalize the form
+	writer.Close()
+
+	resp, err := c.post(ctx, "/train", writer.FormDataContentType(), body)
+	if err != nil {
+		return proof, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return proof, fmt.Errorf("training failed: %s", resp.Status)
+	}
+
+	// Extract the training proof from the response
+	var response struct {
+		TrainingProof *blockchain.TrainingProof `json:"training_proof"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return proof, err
+	}
+	if response.TrainingProof == nil || response.TrainingProof.WeightsHash == "" || response.TrainingProof.SampleHash == "" {
+		return proof, fmt.Errorf("training response has no training_proof")
+	}
+
+	// The weights are stored under the names we sent
+	proof = *response.TrainingProof
+	proof.Owner = owner
+	proof.ModelName = modelName
+	return proof, nil
+}
+
+// Sample is what the AI service regenerated for a proof
+type Sample struct {
+	WeightsHash string `json:"weights_hash"` // of the weights it loaded
+	SampleHash  string `json:"sample_hash"`
+}
+
+// Regenerate asks the AI service to load owner's model and generate the
+// sample for seed again, deterministically
+func (c *Client) Regenerate(ctx context.Context, owner, modelName string, seed int64) (Sample, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("username", owner)
+	_ = writer.WriteField("model_name", modelName)
+	_ = writer.WriteField("seed", strconv.FormatInt(seed, 10))
+	writer.Close()
+
+	resp, err := c.post(ctx, "/regenerate", writer.FormDataContentType(), body)
+	if err != nil {
+		return Sample{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Sample{}, fmt.Errorf("regeneration failed: %s", resp.Status)
+	}
+
+	var sample Sample
+	if err := json.NewDecoder(resp.Body).Decode(&sample); err != nil {
+		return Sample{}, err
+	}
+	return sample, nil
+}
+
+// Generate asks the AI service for the image owner's model generates for
+// seed, with blockHash, the hash of the block the model was mined in,
+// embedded in it
+func (c *Client) Generate(ctx context.Context, owner, modelName, blockHash string, seed int64) ([]byte, error) {
+	// Prepare the form data
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("username", owner)
+	_ = writer.WriteField("model_name", modelName)
+	_ = writer.WriteField("block_hash", blockHash)
+	_ = writer.WriteField("seed", strconv.FormatInt(seed, 10))
+	writer.Close()
+
+	resp, err := c.post(ctx, "/generate", writer.FormDataContentType(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch image: %s", resp.Status)
+	}
+
+	// Read the image data from the response
+	imageData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %v", err)
+	}
+
+	return imageData, nil
+}
+
+// Ping checks that the AI service answers HTTP at all. The service has no
+// health endpoint, so any response, even a 404, counts as reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// post sends the request, passing on the request ID of ctx
+func (c *Client) post(ctx context.Context, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.HeaderRequestID, id)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+func addFile(writer *multipart.Writer, field, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	part, err := writer.CreateFormFile(field, file.Name())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	return err
+}
@@ -0,0 +1,175 @@
+// Package anchor is a client of the sample_blockchain node protocol, used to
+// record go-backend block hashes in that UTXO chain. Each request is one
+// TCP connection: a 12-byte command and a gob payload, after which the
+// client closes its write side and reads the answer, framed the same way.
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+)
+
+const commandLength = 12
+
+// MaxData is the most a data output of the UTXO chain carries
+const MaxData = 80
+
+// Requests and answers of the node. Gob matches them by field name, so
+// they mirror the types of sample_blockchain's network package.
+type (
+	anchorRequest struct {
+		AddrFrom string
+		Data     []byte
+	}
+	anchorReply struct {
+		TxID  []byte
+		Error string
+	}
+	txStatusRequest struct {
+		AddrFrom string
+		ID       []byte
+	}
+	txInfo struct {
+		Found      bool
+		Pending    bool
+		BlockHash  []byte
+		Height     int
+		BestHeight int
+		Data       [][]byte
+	}
+)
+
+// Status is where a transaction is in the UTXO chain
+type Status struct {
+	Found      bool // in a block of the node's chain
+	Pending    bool // waiting in the node's memory pool
+	BlockHash  string
+	Height     int
+	BestHeight int
+	Data       [][]byte // the data outputs of the transaction
+}
+
+// Holds reports whether the transaction has a data output carrying data,
+// so an anchor is not taken on the node's word that it mined something
+func (s Status) Holds(data []byte) bool {
+	return slices.ContainsFunc(s.Data, func(out []byte) bool { return bytes.Equal(out, data) })
+}
+
+// Confirmations counts the block holding the transaction and those on top
+// of it; 0 while the transaction is not in a block
+func (s Status) Confirmations() int {
+	if !s.Found {
+		return 0
+	}
+	return s.BestHeight - s.Height + 1
+}
+
+// Client talks to one mining node of the UTXO chain
+type Client struct {
+	Node    string // host:port of the node
+	Timeout time.Duration
+}
+
+func New(node string) *Client {
+	return &Client{Node: node, Timeout: 10 * time.Second}
+}
+
+// Submit asks the node to record data in a transaction paid by its miner
+// wallet and returns the hex ID of that transaction. The node mines it
+// after answering.
+func (c *Client) Submit(ctx context.Context, data []byte) (string, error) {
+	if len(data) > MaxData {
+		return "", fmt.Errorf("anchor data of %d bytes exceeds %d", len(data), MaxData)
+	}
+
+	var reply anchorReply
+	if err := c.call(ctx, "anchor", anchorRequest{Data: data}, "anchored", &reply); err != nil {
+		return "", err
+	}
+	if reply.Error != "" {
+		return "", fmt.Errorf("anchor node: %s", reply.Error)
+	}
+	return hex.EncodeToString(reply.TxID), nil
+}
+
+// Status looks up the transaction with the hex ID txid
+func (c *Client) Status(ctx context.Context, txid string) (Status, error) {
+	id, err := hex.DecodeString(txid)
+	if err != nil {
+		return Status{}, fmt.Errorf("transaction ID: %w", err)
+	}
+
+	var info txInfo
+	if err := c.call(ctx, "txstatus", txStatusRequest{ID: id}, "txinfo", &info); err != nil {
+		return Status{}, err
+	}
+	return Status{
+		Found:      info.Found,
+		Pending:    info.Pending,
+		BlockHash:  hex.EncodeToString(info.BlockHash),
+		Height:     info.Height,
+		BestHeight: info.BestHeight,
+		Data:       info.Data,
+	}, nil
+}
+
+// call sends one command and decodes the answer, which must carry want
+func (c *Client) call(ctx context.Context, cmd string, req any, want string, reply any) error {
+	var dialer net.Dialer
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Node)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(command(cmd))
+	if err := gob.NewEncoder(&buf).Encode(req); err != nil {
+		return err
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	// The node reads its request until EOF
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		if err := tcp.CloseWrite(); err != nil {
+			return err
+		}
+	}
+
+	// Read the answer and no further: the node may keep the connection
+	// open while it mines
+	answer := make([]byte, commandLength)
+	if _, err := io.ReadFull(conn, answer); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.New("anchor node closed the connection without answering")
+	} else if err != nil {
+		return err
+	}
+	if got := string(bytes.TrimRight(answer, "\x00")); got != want {
+		return fmt.Errorf("anchor node answered %q to %q", got, cmd)
+	}
+	return gob.NewDecoder(conn).Decode(reply)
+}
+
+// command pads cmd with zero bytes to the command length
+func command(cmd string) []byte {
+	b := make([]byte, commandLength)
+	copy(b, cmd)
+	return b
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3 document of the HTTP API. It is written by hand;
+// the integration tests check it against the router and regenerate the
+// client from it.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// ServeSpec serves Spec at /openapi.json
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
@@ -0,0 +1,351 @@
+// Package api holds the request and response bodies of the HTTP API and
+// its OpenAPI document. The handlers and the generated client both use
+// these types, so the two cannot drift apart silently.
+package api
+
+import (
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/Kami0rn/ProjectCPE/go-backend/provenance"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+)
+
+// Entities returned as they are stored
+type (
+	Block                = blockchain.Block
+	Transaction          = blockchain.Transaction
+	TrainingProof        = blockchain.TrainingProof
+	Model                = models.Model
+	Organization         = models.Organization
+	OrganizationWithRole = models.OrganizationWithRole
+	Membership           = models.Membership
+	Peer                 = models.Peer
+	AuditEvent           = models.AuditEvent
+	StatementEntry       = rewards.Entry
+	Licence              = blockchain.Licence
+	ModelUsage           = blockchain.Usage
+	GeneratedImage       = models.GeneratedImage
+	ProvenanceManifest   = provenance.Manifest
+	ProvenanceClaim      = provenance.Claim
+)
+
+// ErrorResponse is the body of every 4xx and 5xx response
+type ErrorResponse struct {
+	Error     string       `json:"error"`            // human-readable message
+	Code      string       `json:"code"`             // stable machine-readable code, see package apperr
+	Fields    []FieldError `json:"fields,omitempty"` // validation failures
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError is a validation failure of one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// MessageResponse acknowledges an action that returns no data
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type RegisterRequest struct {
+	// Usernames name storage directories, next to those of organisations
+	// ("@" and their name) and the blob store (".blobs"), so they keep to
+	// a safe charset
+	Username string `json:"username" binding:"required,max=64,safename"`
+	Password string `json:"password" binding:"required,max=72"` // bcrypt ignores anything longer
+	Email    string `json:"email" binding:"omitempty,email,max=254"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LoginResponse struct {
+	Token string `json:"token"`
+}
+
+// MeResponse describes the caller, from the claims of their token
+type MeResponse struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// SigningKeyRequest registers the caller's public key for dataset claims:
+// 32 bytes for ed25519, the 64 bytes X | Y of the point for p256, in hex.
+// Signature is the new key's hex signature of the blockchain.KeyChange of
+// the caller, the key and Challenge; CurrentSignature is that of the key
+// it replaces, required once a key is registered.
+type SigningKeyRequest struct {
+	Type             string `json:"type" binding:"required,oneof=ed25519 p256"`
+	PublicKey        string `json:"public_key" binding:"required"`
+	Challenge        string `json:"challenge" binding:"required"`
+	Signature        string `json:"signature" binding:"required"`
+	CurrentSignature string `json:"current_signature,omitempty"`
+}
+
+// SigningKeyChallenge is what the caller's next SigningKeyRequest must
+// sign. It is used once and expires at ExpiresAt.
+type SigningKeyChallenge struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SigningKeyResponse is a user's key, written "<type>:<hex key>" as
+// transactions carry it
+type SigningKeyResponse struct {
+	Username   string `json:"username"`
+	SigningKey string `json:"signing_key"`
+}
+
+type ChainResponse struct {
+	Length int     `json:"length"`
+	Chain  []Block `json:"chain"`
+}
+
+// ImageMatch is a block holding a transaction for the checked image
+type ImageMatch struct {
+	BlockIndex int       `json:"block_index"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type CheckImageResponse struct {
+	Trained bool         `json:"trained"`
+	Matches []ImageMatch `json:"matches,omitempty"`
+}
+
+// VerifyImageResponse is what the watermark of an uploaded image tells.
+// HashPrefix is the start of the block hash the mark carries and Corrected
+// how many of its coded bytes were repaired. BlockIndex is that of the
+// block on the chain whose hash starts so, -1 if none does; Model is the
+// model mined in it, left out if this node does not know it or it belongs
+// to an organisation the caller is not a member of.
+type VerifyImageResponse struct {
+	Watermarked bool   `json:"watermarked"`
+	HashPrefix  string `json:"hash_prefix,omitempty"`
+	Corrected   int    `json:"corrected"`
+	BlockIndex  int    `json:"block_index"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	Model       *Model `json:"model,omitempty"`
+}
+
+// InspectImageResponse is what the provenance manifest of an uploaded image
+// tells. Valid means a trusted node signed it, the image is unchanged
+// since, and the model it names was mined in the block it names; Problem
+// says why not. Trusted means the signature verifies with a key that is
+// this node's, a validator's or one of its trusted_node_keys. BlockIndex is that of the
+// block the manifest names, -1 if it is not on this node's chain.
+type InspectImageResponse struct {
+	HasManifest bool                `json:"has_manifest"`
+	Valid       bool                `json:"valid"`
+	Trusted     bool                `json:"trusted"`
+	Problem     string              `json:"problem,omitempty"`
+	Manifest    *ProvenanceManifest `json:"manifest,omitempty"`
+	BlockExists bool                `json:"block_exists"`
+	BlockIndex  int                 `json:"block_index"`
+}
+
+// VerifyProofResponse compares a block's training proof with what the AI
+// service regenerated from it
+type VerifyProofResponse struct {
+	BlockIndex   int           `json:"block_index"`
+	Valid        bool          `json:"valid"` // both hashes match
+	WeightsMatch bool          `json:"weights_match"`
+	SampleMatch  bool          `json:"sample_match"`
+	WeightsHash  string        `json:"weights_hash"` // of the weights the AI service loaded
+	SampleHash   string        `json:"sample_hash"`  // of the sample it regenerated
+	Proof        TrainingProof `json:"proof"`
+}
+
+// BalanceResponse is a user's credits. Balance counts the chain only;
+// Pending is the net of their transactions still in the mempool.
+type BalanceResponse struct {
+	Username string  `json:"username"`
+	Balance  float64 `json:"balance"`
+	Credited float64 `json:"credited"`
+	Debited  float64 `json:"debited"`
+	Pending  float64 `json:"pending"`
+}
+
+// StatementResponse lists the credits and debits of a user, oldest first
+type StatementResponse struct {
+	Username string           `json:"username"`
+	Balance  float64          `json:"balance"`
+	Entries  []StatementEntry `json:"entries"`
+}
+
+// BlockAnchorResponse is where a block is anchored in the UTXO chain of
+// sample_blockchain
+type BlockAnchorResponse struct {
+	BlockIndex int    `json:"block_index"`
+	BlockHash  string `json:"block_hash"`
+	// The anchor covers the blocks FromIndex through ToIndex with the
+	// Merkle root Root of their hashes
+	FromIndex int    `json:"from_index"`
+	ToIndex   int    `json:"to_index"`
+	Root      string `json:"root"`
+	TxID      string `json:"tx_id"`
+	// Status is "confirmed" once the transaction is in a block of the UTXO
+	// chain, "pending" while it waits to be mined, "unknown" if the node
+	// does not know it and "mismatch" if its data output does not carry
+	// the root. Unknown and mismatched anchors are made again.
+	Status string `json:"status"`
+	// Confirmations counts the UTXO block holding the transaction and
+	// those on top of it
+	Confirmations int    `json:"confirmations"`
+	UTXOBlockHash string `json:"utxo_block_hash,omitempty"`
+	UTXOHeight    int    `json:"utxo_height,omitempty"`
+}
+
+// Anchor statuses
+const (
+	AnchorConfirmed = "confirmed"
+	AnchorPending   = "pending"
+	AnchorUnknown   = "unknown"
+	AnchorMismatch  = "mismatch"
+)
+
+type AddPeerRequest struct {
+	Peer string `json:"peer" binding:"required"`
+}
+
+// PeerInfo is a stored peer with the progress of replication to it
+type PeerInfo struct {
+	Peer
+	Replication *blockchain.ReplicationStatus `json:"replication,omitempty"`
+}
+
+type PeersResponse struct {
+	Peers []PeerInfo `json:"peers"`
+}
+
+// ValidatorsResponse describes the consensus of the node. Validators, in
+// slot order, SlotDurationMS and Proposals are set in "poa" mode only; Self
+// is the node's own validator key if it has one.
+type ValidatorsResponse struct {
+	Mode           string              `json:"mode"`
+	SlotDurationMS int64               `json:"slot_duration_ms,omitempty"`
+	Validators     []string            `json:"validators"`
+	Proposals      []ValidatorProposal `json:"proposals,omitempty"`
+	Self           string              `json:"self,omitempty"`
+}
+
+// ValidatorProposal is a validator set change on the chain that fewer than
+// a majority of the validators proposed so far
+type ValidatorProposal struct {
+	Action    string   `json:"action"`
+	PublicKey string   `json:"public_key"`
+	Proposers []string `json:"proposers"`
+}
+
+// ValidatorChangeRequest proposes a validator set change in the next block
+// this node produces
+type ValidatorChangeRequest struct {
+	Action    string `json:"action" binding:"required,oneof=add remove"`
+	PublicKey string `json:"public_key" binding:"required"`
+}
+
+type ValidatorChangeResponse struct {
+	Message     string                 `json:"message"`
+	Transaction blockchain.Transaction `json:"transaction"`
+}
+
+// ModelRequest names a personal model by username or an organisation model
+// by organization
+type ModelRequest struct {
+	Username     string `json:"username"`
+	ModelName    string `json:"model_name" binding:"required"`
+	Organization string `json:"organization"`
+}
+
+type ModelResponse struct {
+	Model        Model    `json:"model"`
+	SampleImages []string `json:"sample_images"` // base64, up to 4 training images
+	Licence      *Licence `json:"licence,omitempty"`
+}
+
+// GalleryResponse is a page of the images generated from a model, newest
+// first; pass the ID of the last as before for the next page
+type GalleryResponse struct {
+	Model  Model            `json:"model"`
+	Images []GeneratedImage `json:"images"`
+}
+
+// ImageProvenanceResponse tells which model produced a generated image.
+// BlockIndex is that of the block the model was mined in, -1 if that block
+// is not on this node's chain.
+type ImageProvenanceResponse struct {
+	Image      GeneratedImage `json:"image"`
+	Model      Model          `json:"model"`
+	BlockIndex int            `json:"block_index"`
+}
+
+// LicenceRequest sets the licence of a model of the caller, or of an
+// organisation they administer
+type LicenceRequest struct {
+	ModelName    string  `json:"model_name" binding:"required"`
+	Organization string  `json:"organization"`
+	Licence      Licence `json:"licence"`
+}
+
+type LicenceChangeResponse struct {
+	Message     string                 `json:"message"`
+	Transaction blockchain.Transaction `json:"transaction"`
+}
+
+// LicenceResponse is the licence of a model on the chain, nil if it has
+// none, and a change of it waiting for the next block
+type LicenceResponse struct {
+	Model   string   `json:"model"` // hash of the block the model was mined in
+	Licence *Licence `json:"licence"`
+	Pending *Licence `json:"pending,omitempty"`
+}
+
+// ModelUsageResponse counts the images generated from a model. Images
+// counts the usage records on the chain and Pending those in the mempool.
+type ModelUsageResponse struct {
+	Model   string       `json:"model"`
+	Images  int          `json:"images"`
+	Pending int          `json:"pending"`
+	Usage   []ModelUsage `json:"usage"`
+}
+
+type ModelsResponse struct {
+	Models []Model `json:"models"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type OrganizationsResponse struct {
+	Organizations []OrganizationWithRole `json:"organizations"`
+}
+
+type AddMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"omitempty,oneof=owner admin member"` // defaults to member
+}
+
+type MembersResponse struct {
+	Organization string       `json:"organization"`
+	Members      []Membership `json:"members"`
+}
+
+type AuditEventsResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessResponse reports "ok" or the error of each check
+type ReadinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
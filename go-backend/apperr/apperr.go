@@ -0,0 +1,110 @@
+// Package apperr is the error model of the HTTP API. Handlers and
+// middleware report failures with Abort; middleware.Errors renders them as
+// api.ErrorResponse with a stable code, so clients never have to parse the
+// message and internal errors never reach them.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/gin-gonic/gin"
+)
+
+// Code identifies a class of failure. Codes are part of the API: add new
+// ones rather than changing existing ones.
+type Code string
+
+const (
+	CodeInvalidInput    Code = "invalid_input"
+	CodeUnauthorized    Code = "unauthorized"
+	CodeForbidden       Code = "forbidden"
+	CodeNotFound        Code = "not_found"
+	CodeConflict        Code = "conflict"
+	CodePayloadTooLarge Code = "payload_too_large"
+	CodeRateLimited     Code = "rate_limited"
+	CodeAccountLocked   Code = "account_locked"
+	CodeUpstream        Code = "upstream_error" // the AI service or the anchor node failed
+	CodeInternal        Code = "internal"
+)
+
+var statuses = map[Code]int{
+	CodeInvalidInput:    http.StatusBadRequest,
+	CodeUnauthorized:    http.StatusUnauthorized,
+	CodeForbidden:       http.StatusForbidden,
+	CodeNotFound:        http.StatusNotFound,
+	CodeConflict:        http.StatusConflict,
+	CodePayloadTooLarge: http.StatusRequestEntityTooLarge,
+	CodeRateLimited:     http.StatusTooManyRequests,
+	CodeAccountLocked:   http.StatusTooManyRequests,
+	CodeUpstream:        http.StatusBadGateway,
+	CodeInternal:        http.StatusInternalServerError,
+}
+
+// Status is the HTTP status the code is answered with
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is a failure that can be shown to the client
+type Error struct {
+	Code    Code
+	Message string           // safe to send to the client
+	Fields  []api.FieldError // per-field validation failures
+	Err     error            // the cause; logged, never sent
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches the internal cause err to a client-facing error
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Invalid reports a request that failed validation
+func Invalid(message string, fields ...api.FieldError) *Error {
+	return &Error{Code: CodeInvalidInput, Message: message, Fields: fields}
+}
+
+// Field is a shorthand for a validation failure of one field
+func Field(name, message string) api.FieldError {
+	return api.FieldError{Field: name, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Abort records err for middleware.Errors and stops the handler chain
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// Response returns the status and body for err. Errors that are not an
+// *Error become an opaque internal error.
+func Response(err error, requestID string) (int, api.ErrorResponse) {
+	var appErr *Error
+	if !errors.As(err, &appErr) {
+		appErr = New(CodeInternal, "Internal server error")
+	}
+	return appErr.Code.Status(), api.ErrorResponse{
+		Error:     appErr.Message,
+		Code:      string(appErr.Code),
+		Fields:    appErr.Fields,
+		RequestID: requestID,
+	}
+}
@@ -0,0 +1,98 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// namePattern is the charset of names that end up in storage paths
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// SafeName reports whether name is safe to use as a path element: letters,
+// digits, '-' and '_' only, so never "..", ".blobs" or "a/b"
+func SafeName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Report validation failures under the JSON names clients send, and add
+// the "safename" tag checking SafeName
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("safename", func(fl validator.FieldLevel) bool {
+			return SafeName(fl.Field().String())
+		})
+	}
+}
+
+// BindJSON decodes the JSON body into obj and validates its binding tags.
+// The error is an *Error listing the offending fields.
+func BindJSON(c *gin.Context, obj any) error {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		return FromBindError(err)
+	}
+	return nil
+}
+
+// FromBindError converts a binding or validation error into an *Error
+func FromBindError(err error) *Error {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		appErr := Invalid("Invalid request")
+		for _, fe := range invalid {
+			appErr.Fields = append(appErr.Fields, Field(fe.Field(), fieldMessage(fe)))
+		}
+		return appErr
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return Invalid("Invalid request", Field(typeErr.Field, "must be a "+typeErr.Type.String()))
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return New(CodePayloadTooLarge, "Request body too large")
+	}
+	return Wrap(CodeInvalidInput, "Malformed request body", err)
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "email":
+		return "must be an email address"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "excludes":
+		return "must not contain " + strconv.Quote(fe.Param())
+	case "safename":
+		return "must contain only letters, digits, '-' and '_'"
+	}
+	return "is invalid"
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Record appends an audit event for the current request. The actor is the
+// authenticated user unless actor is given (failed logins have no session).
+// A failure to write the trail is logged but does not fail the request.
+func Record(c *gin.Context, repo *database.Repository, action, actor, target string, success bool, details string) {
+	if actor == "" {
+		actor = c.GetString("username")
+	}
+
+	event := models.AuditEvent{
+		Action:    action,
+		Actor:     actor,
+		Target:    target,
+		Success:   success,
+		ClientIP:  c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	logger.Info("audit", "action", action, "actor", actor, "target", target, "success", success)
+	if err := repo.CreateAuditEvent(&event); err != nil {
+		logger.Error("failed to write audit event", "action", action, "error", err)
+	}
+}
+
+// SystemActor is the actor of events the node triggers on its own
+const SystemActor = "system"
+
+// RecordSystem appends an audit event for an action the node took outside
+// any request, such as evicting a dead peer
+func RecordSystem(ctx context.Context, repo *database.Repository, action, target string, success bool, details string) {
+	event := models.AuditEvent{
+		Action:    action,
+		Actor:     SystemActor,
+		Target:    target,
+		Success:   success,
+		RequestID: logging.RequestID(ctx),
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Info("audit", "action", action, "actor", SystemActor, "target", target, "success", success)
+	if err := repo.CreateAuditEvent(&event); err != nil {
+		logger.Error("failed to write audit event", "action", action, "error", err)
+	}
+}
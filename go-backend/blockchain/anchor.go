@@ -0,0 +1,27 @@
+package blockchain
+
+import "encoding/binary"
+
+// anchorMagic starts every anchor payload
+const anchorMagic = "CPEA"
+
+// AnchorRoot is the Merkle root, in the scheme of TxRoot, of the hashes of
+// blocks, in chain order
+func AnchorRoot(blocks []Block) [32]byte {
+	leaves := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		leaves[i] = []byte(block.Hash)
+	}
+	return merkleRoot(leaves)
+}
+
+// AnchorPayload is what an anchor records in the UTXO chain for the blocks
+// from through to with AnchorRoot root, 52 bytes in all:
+//
+//	"CPEA" | u64 from | u64 to | 32 bytes root
+func AnchorPayload(from, to int, root [32]byte) []byte {
+	buf := []byte(anchorMagic)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(from))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(to))
+	return append(buf, root[:]...)
+}
@@ -5,10 +5,25 @@ import (
 )
 
 type Block struct {
+	// Version selects how the block is hashed, see CalculateHash. It is
+	// omitted for version 0 so those blocks keep their original encoding.
+	Version      int           `json:"version,omitempty"`
 	Index        int           `json:"index"`
 	Timestamp    time.Time     `json:"timestamp"`
 	Transactions []Transaction `json:"transactions"`
 	PrevHash     string        `json:"prev_hash"`
 	Hash         string        `json:"hash"`
-	Proof        string        `json:"proof"` // later tied to AI training output
+	Proof        string        `json:"proof"` // the sample hash of TrainingProof, when there is one
+	// TrainingProof is set on blocks mined from a training run since
+	// version 2
+	TrainingProof *TrainingProof `json:"training_proof,omitempty"`
+	// Validator is the hex ed25519 public key of the proof-of-authority
+	// validator that produced the block, since version 3. Signature is its
+	// signature of Hash and, unlike Validator, not covered by the hash.
+	Validator string `json:"validator,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// AnchorTxID is the hex ID of the UTXO chain transaction that anchors
+	// the block, see package anchor. It is local to this node: not hashed
+	// and not taken from peers.
+	AnchorTxID string `json:"anchor_txid,omitempty"`
 }
@@ -1,11 +1,53 @@
 package blockchain
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
 
-var Blockchain []Block
+var ErrInvalidBlock = errors.New("block does not extend the chain")
 
-func InitGenesisBlock() Block {
-	genesis := Block{
+// ErrDuplicateReference means a transaction reuses the reference of one
+// already on the chain, so its event would be credited twice
+var ErrDuplicateReference = errors.New("reference already on the chain")
+
+// Consensus decides which blocks may extend a chain beyond the hash and
+// link rules of IsBlockValid
+type Consensus interface {
+	// CheckBlock reports why block may not follow chain, which ends in the
+	// block's parent
+	CheckBlock(chain []Block, block Block) error
+	// CheckChain reports why chain, from the genesis block on, is not one
+	// the consensus could have produced
+	CheckChain(chain []Block) error
+}
+
+// RewardRule decides which rewards a block may hold, see package rewards
+type RewardRule interface {
+	// CheckRewards reports why a reward of block, which follows chain, is
+	// not one the rule pays
+	CheckRewards(chain []Block, block Block) error
+}
+
+// Chain is a node's copy of the blockchain. It is safe for concurrent use.
+type Chain struct {
+	mu        sync.RWMutex
+	blocks    []Block
+	consensus Consensus  // nil accepts every valid block
+	rewards   RewardRule // nil accepts every reward
+	// references holds the Reference of every transaction on the chain
+	references map[string]bool
+}
+
+// NewChain returns a chain holding only the genesis block
+func NewChain() *Chain {
+	return &Chain{blocks: []Block{NewGenesisBlock()}, references: map[string]bool{}}
+}
+
+func NewGenesisBlock() Block {
+	return Block{
 		Index:        0,
 		Timestamp:    time.Now(),
 		Transactions: nil,
@@ -13,18 +55,203 @@ func InitGenesisBlock() Block {
 		Hash:         "genesis_hash",
 		Proof:        "GENESIS",
 	}
-	Blockchain = append(Blockchain, genesis)
-	return genesis
 }
 
-func AddBlock(newBlock Block) {
-	Blockchain = append(Blockchain, newBlock)
+// Blocks returns a copy of the chain
+func (c *Chain) Blocks() []Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Block(nil), c.blocks...)
+}
+
+func (c *Chain) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.blocks)
+}
+
+func (c *Chain) LastBlock() Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// BlockAt returns the block at index, if the chain is that long
+func (c *Chain) BlockAt(index int) (Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if index < 0 || index >= len(c.blocks) {
+		return Block{}, false
+	}
+	return c.blocks[index], true
+}
+
+// BlockByHash returns the block with hash, if it is on the chain
+func (c *Chain) BlockByHash(hash string) (Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, block := range c.blocks {
+		if block.Hash == hash {
+			return block, true
+		}
+	}
+	return Block{}, false
+}
+
+// SetConsensus makes the chain accept only blocks rule allows. Set it
+// before the chain grows past the genesis block.
+func (c *Chain) SetConsensus(rule Consensus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consensus = rule
+}
+
+// SetRewards makes the chain accept only the rewards rule pays. Like the
+// consensus, set it before the chain grows past the genesis block.
+func (c *Chain) SetRewards(rule RewardRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rewards = rule
+}
+
+// AddBlock appends newBlock if it is valid on top of the current last block.
+// The check and the append happen under one lock, so of two blocks mined on
+// the same parent only the first is accepted. A block the consensus, the
+// rewards rule or CheckLicences rejects fails with its reason wrapped in
+// ErrInvalidBlock. The anchor of a block,
+// being local, is dropped.
+func (c *Chain) AddBlock(newBlock Block) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	newBlock.AnchorTxID = ""
+	if !IsBlockValid(newBlock, c.blocks[len(c.blocks)-1]) {
+		return ErrInvalidBlock
+	}
+	if c.consensus != nil {
+		if err := c.consensus.CheckBlock(c.blocks, newBlock); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+		}
+	}
+	if c.rewards != nil {
+		if err := c.rewards.CheckRewards(c.blocks, newBlock); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+		}
+	}
+	if err := newBlock.CheckLicences(c.blocks); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+	if err := addReferences(c.references, newBlock); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
+	}
+	c.blocks = append(c.blocks, newBlock)
+	return nil
+}
+
+// addReferences adds the references of block to seen, unless one of them
+// is already there. On failure seen is unchanged.
+func addReferences(seen map[string]bool, block Block) error {
+	var added []string
+	for _, tx := range block.Transactions {
+		if tx.Reference == "" {
+			continue
+		}
+		if seen[tx.Reference] {
+			for _, ref := range added {
+				delete(seen, ref)
+			}
+			return fmt.Errorf("%w: %s", ErrDuplicateReference, tx.Reference)
+		}
+		seen[tx.Reference] = true
+		added = append(added, tx.Reference)
+	}
+	return nil
+}
+
+// Credited reports whether a transaction with reference is on the chain
+func (c *Chain) Credited(reference string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.references[reference]
+}
+
+// Replace swaps in chain if it is valid and longer than ours. Blocks we
+// already had keep their anchor.
+func (c *Chain) Replace(chain []Block) bool {
+	if !IsChainValid(chain) {
+		return false
+	}
+	references := map[string]bool{}
+	for i, block := range chain {
+		if addReferences(references, block) != nil || block.CheckLicences(chain[:i]) != nil {
+			return false
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(chain) <= len(c.blocks) {
+		return false
+	}
+	if c.consensus != nil && c.consensus.CheckChain(chain) != nil {
+		return false
+	}
+	if c.rewards != nil {
+		for i := 1; i < len(chain); i++ {
+			if c.rewards.CheckRewards(chain[:i], chain[i]) != nil {
+				return false
+			}
+		}
+	}
+	blocks := append([]Block(nil), chain...)
+	for i := range blocks {
+		blocks[i].AnchorTxID = ""
+		if i < len(c.blocks) && c.blocks[i].Hash == blocks[i].Hash {
+			blocks[i].AnchorTxID = c.blocks[i].AnchorTxID
+		}
+	}
+	c.blocks = blocks
+	c.references = references
+	return true
+}
+
+// Restore puts back blocks, as returned by Blocks, after a Replace that
+// must be undone, such as that of an import that failed to commit. Unlike
+// Replace it checks nothing.
+func (c *Chain) Restore(blocks []Block) {
+	references := map[string]bool{}
+	for _, block := range blocks {
+		addReferences(references, block)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks = append([]Block(nil), blocks...)
+	c.references = references
+}
+
+// SetAnchor records txid as the anchor of the blocks from through to. It
+// reports false, changing nothing, unless those blocks are on the chain
+// and have AnchorRoot root, so an anchor of blocks replaced since is not
+// attached to their replacements.
+func (c *Chain) SetAnchor(from, to int, root [32]byte, txid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if from < 0 || from > to || to >= len(c.blocks) || AnchorRoot(c.blocks[from:to+1]) != root {
+		return false
+	}
+	for i := from; i <= to; i++ {
+		c.blocks[i].AnchorTxID = txid
+	}
+	return true
 }
 
 func GenerateBlock(prevBlock Block, transactions []Transaction, proof string) Block {
 	newBlock := Block{
+		Version:      CurrentVersion,
 		Index:        prevBlock.Index + 1,
-		Timestamp:    time.Now(),
+		Timestamp:    time.Now().UTC().Truncate(TimestampPrecision),
 		Transactions: transactions,
 		PrevHash:     prevBlock.Hash,
 		Proof:        proof,
@@ -33,3 +260,12 @@ func GenerateBlock(prevBlock Block, transactions []Transaction, proof string) Bl
 	newBlock.Hash = CalculateHash(newBlock)
 	return newBlock
 }
+
+// GenerateTrainedBlock is GenerateBlock for a training run; the block's
+// proof is the sample hash of the training proof
+func GenerateTrainedBlock(prevBlock Block, transactions []Transaction, proof TrainingProof) Block {
+	newBlock := GenerateBlock(prevBlock, transactions, proof.SampleHash)
+	newBlock.TrainingProof = &proof
+	newBlock.Hash = CalculateHash(newBlock)
+	return newBlock
+}
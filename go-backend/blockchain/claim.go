@@ -0,0 +1,206 @@
+package blockchain
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Signing key types users can register. P-256 keys are the ones the
+// wallets of sample_blockchain hold.
+const (
+	KeyEd25519 = "ed25519"
+	KeyP256    = "p256"
+)
+
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+// p256KeySize is an uncompressed P-256 point without its 0x04 prefix,
+// X | Y, as sample_blockchain's wallet stores public keys
+const p256KeySize = 64
+
+// SigningKey is a user's public key. On the chain and in the API it is
+// written "<type>:<hex key>", see String.
+type SigningKey struct {
+	Type   string
+	Public []byte
+}
+
+// ParseSigningKey reads a key written by SigningKey.String. Ed25519 keys
+// are 32 bytes, P-256 keys the 64 bytes X | Y of a point on the curve.
+func ParseSigningKey(s string) (SigningKey, error) {
+	keyType, keyHex, _ := strings.Cut(s, ":")
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%w: %q is not <type>:<hex key>", ErrInvalidSigningKey, s)
+	}
+	return NewSigningKey(keyType, key)
+}
+
+// NewSigningKey checks that key is a public key of keyType
+func NewSigningKey(keyType string, key []byte) (SigningKey, error) {
+	switch keyType {
+	case KeyEd25519:
+		if len(key) != ed25519.PublicKeySize {
+			return SigningKey{}, fmt.Errorf("%w: ed25519 keys are %d bytes, got %d", ErrInvalidSigningKey, ed25519.PublicKeySize, len(key))
+		}
+	case KeyP256:
+		if len(key) != p256KeySize {
+			return SigningKey{}, fmt.Errorf("%w: p256 keys are the %d bytes X | Y, got %d", ErrInvalidSigningKey, p256KeySize, len(key))
+		}
+		if _, err := ecdh.P256().NewPublicKey(append([]byte{4}, key...)); err != nil {
+			return SigningKey{}, fmt.Errorf("%w: not a point on P-256", ErrInvalidSigningKey)
+		}
+	default:
+		return SigningKey{}, fmt.Errorf("%w: type must be %q or %q, got %q", ErrInvalidSigningKey, KeyEd25519, KeyP256, keyType)
+	}
+	return SigningKey{Type: keyType, Public: append([]byte(nil), key...)}, nil
+}
+
+func (k SigningKey) String() string {
+	return k.Type + ":" + hex.EncodeToString(k.Public)
+}
+
+// Verify reports whether sig is the key's signature of message. Ed25519
+// signs message itself; P-256 signs its SHA-256 and the signature is r | s,
+// 32 bytes each.
+func (k SigningKey) Verify(message, sig []byte) bool {
+	switch k.Type {
+	case KeyEd25519:
+		return len(k.Public) == ed25519.PublicKeySize && ed25519.Verify(k.Public, message, sig)
+	case KeyP256:
+		if len(k.Public) != p256KeySize || len(sig) != 64 {
+			return false
+		}
+		pub := ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(k.Public[:32]),
+			Y:     new(big.Int).SetBytes(k.Public[32:]),
+		}
+		digest := sha256.Sum256(message)
+		return ecdsa.Verify(&pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	default:
+		return false
+	}
+}
+
+// keyChangeDomain starts every encoded key change, so its signature cannot
+// pass for a claim signature or the other way round
+const keyChangeDomain = "cpe-signing-key/1"
+
+// KeyChange is what a user signs to register Key, written as
+// SigningKey.String does, in answer to the node's Challenge. The new key
+// signs it to show the user holds it, and the key it replaces, if any,
+// signs it to show the change is theirs.
+type KeyChange struct {
+	Username  string
+	Key       string
+	Challenge string
+}
+
+// Encode returns the signed bytes of the key change, in the scheme of
+// Header.Encode:
+//
+//	str "cpe-signing-key/1" | str username | str key | str challenge
+func (c KeyChange) Encode() []byte {
+	var buf []byte
+	buf = appendString(buf, keyChangeDomain)
+	buf = appendString(buf, c.Username)
+	buf = appendString(buf, c.Key)
+	return appendString(buf, c.Challenge)
+}
+
+// claimDomain starts every encoded claim, so a claim signature cannot be
+// passed off as a signature of anything else
+const claimDomain = "cpe-dataset-claim/1"
+
+// DatasetClaim is what a user signs, before uploading, to claim the images
+// of a training run as theirs. The client computes DatasetRoot itself from
+// the SHA-256 digests of the images.
+type DatasetClaim struct {
+	Sender       string
+	ModelName    string
+	Organization string
+	DatasetRoot  [32]byte
+}
+
+// Encode returns the signed bytes of the claim, in the scheme of
+// Header.Encode:
+//
+//	str "cpe-dataset-claim/1" | str sender | str model_name
+//	str organization | 32 bytes dataset_root
+func (c DatasetClaim) Encode() []byte {
+	var buf []byte
+	buf = appendString(buf, claimDomain)
+	buf = appendString(buf, c.Sender)
+	buf = appendString(buf, c.ModelName)
+	buf = appendString(buf, c.Organization)
+	return append(buf, c.DatasetRoot[:]...)
+}
+
+// Verify checks the hex signature sig of the claim by the key written as
+// SigningKey.String does
+func (c DatasetClaim) Verify(key, sig string) error {
+	k, err := ParseSigningKey(key)
+	if err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(sig)
+	if err != nil || !k.Verify(c.Encode(), raw) {
+		return fmt.Errorf("signature of %s does not verify for the dataset claim", c.Sender)
+	}
+	return nil
+}
+
+// ValidateClaims checks the signed transactions of the block. Those with
+// the same sender, organisation, key and signature claim the training run
+// of the block together: the dataset root of their image hashes must be
+// that of the training proof, and the signature must verify for the claim
+// on the proof's model.
+func (b Block) ValidateClaims() error {
+	type claimKey struct{ sender, organization, key, sig string }
+	claims := map[claimKey][]string{}
+	var order []claimKey
+	for _, tx := range b.Transactions {
+		if tx.SenderKey == "" && tx.Signature == "" {
+			continue
+		}
+		if tx.SenderKey == "" || tx.Signature == "" || tx.ImageHash == "" {
+			return fmt.Errorf("signed transaction of %s needs a key, a signature and an image hash", tx.Sender)
+		}
+		k := claimKey{tx.Sender, tx.Organization, tx.SenderKey, tx.Signature}
+		if _, ok := claims[k]; !ok {
+			order = append(order, k)
+		}
+		claims[k] = append(claims[k], tx.ImageHash)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	if b.TrainingProof == nil {
+		return errors.New("signed transactions in a block without a training proof")
+	}
+
+	for _, k := range order {
+		claim := DatasetClaim{
+			Sender:       k.sender,
+			ModelName:    b.TrainingProof.ModelName,
+			Organization: k.organization,
+			DatasetRoot:  DatasetRoot(claims[k]),
+		}
+		if hex.EncodeToString(claim.DatasetRoot[:]) != b.TrainingProof.DatasetRoot {
+			return fmt.Errorf("images signed by %s are not the training dataset", k.sender)
+		}
+		if err := claim.Verify(k.key, k.sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Block versions. Version 0 blocks are hashed over their Go JSON encoding,
+// which depends on field order, time formatting and float rendering; they
+// are still accepted so existing chains keep validating. Version 1 blocks
+// are hashed over the canonical header encoding below, version 2 adds the
+// root of the block's training proof to it and version 3 the validator.
+const (
+	VersionLegacyJSON    = 0
+	VersionCanonical     = 1
+	VersionTrainingProof = 2
+	VersionValidator     = 3
+
+	// CurrentVersion is the version of newly generated blocks
+	CurrentVersion = VersionValidator
+)
+
+// TimestampPrecision is the resolution of version 1 timestamps. It matches
+// what database timestamp columns keep, so a round trip through one does
+// not change the hash.
+const TimestampPrecision = time.Microsecond
+
+// Header is the part of a block covered by its hash. The transactions are
+// committed to by TxRoot, a Merkle root, and the training proof by
+// ProofRoot, so a header can be checked without them.
+type Header struct {
+	Version   int
+	Index     int
+	Timestamp time.Time
+	PrevHash  string
+	TxRoot    [32]byte
+	Proof     string
+	ProofRoot [32]byte // version 2 and later
+	Validator string   // version 3 and later
+}
+
+func (b Block) Header() Header {
+	return Header{
+		Version:   b.Version,
+		Index:     b.Index,
+		Timestamp: b.Timestamp,
+		PrevHash:  b.PrevHash,
+		TxRoot:    TxRoot(b.Transactions),
+		Proof:     b.Proof,
+		ProofRoot: ProofRoot(b.TrainingProof),
+		Validator: b.Validator,
+	}
+}
+
+// Encode returns the canonical version 1 encoding of the header. Integers
+// are big-endian; a string is its UTF-8 bytes prefixed by their length as
+// a u32.
+//
+//	u32 version | u64 index | i64 timestamp in µs since the Unix epoch
+//	str prev_hash | 32 bytes tx_root | str proof
+//
+// From version 2 on, 32 bytes of proof_root follow, and from version 3 on
+// str validator after them.
+func (h Header) Encode() []byte {
+	var buf []byte
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.Version))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.Index))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.Timestamp.UnixMicro()))
+	buf = appendString(buf, h.PrevHash)
+	buf = append(buf, h.TxRoot[:]...)
+	buf = appendString(buf, h.Proof)
+	if h.Version >= VersionTrainingProof {
+		buf = append(buf, h.ProofRoot[:]...)
+	}
+	if h.Version >= VersionValidator {
+		buf = appendString(buf, h.Validator)
+	}
+	return buf
+}
+
+// EncodeTransaction returns the canonical encoding of a transaction. The
+// amount is its IEEE 754 binary64 bits, with -0 written as 0.
+//
+//	str sender | str receiver | u64 amount | str image_hash | str organization
+//
+// A transaction with a validator key, that is a validator set change,
+// appends str validator_key, a signed one str validator_key |
+// str sender_key | str signature, one with a reference, a reward, those
+// three and str reference, and a licence or usage record those four and
+//
+//	str model | str licence_id | u8 commercial | u8 attribution
+//
+// with a nil licence written as "" | 0 | 0. Others keep the encoding they
+// had before.
+func EncodeTransaction(tx Transaction) []byte {
+	amount := tx.Amount
+	if amount == 0 {
+		amount = 0 // drop the sign of -0
+	}
+
+	var buf []byte
+	buf = appendString(buf, tx.Sender)
+	buf = appendString(buf, tx.Receiver)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(amount))
+	buf = appendString(buf, tx.ImageHash)
+	buf = appendString(buf, tx.Organization)
+	signed := tx.SenderKey != "" || tx.Signature != ""
+	modelRecord := tx.Model != "" || tx.Licence != nil
+	referenced := tx.Reference != "" || modelRecord
+	if tx.ValidatorKey != "" || signed || referenced {
+		buf = appendString(buf, tx.ValidatorKey)
+	}
+	if signed || referenced {
+		buf = appendString(buf, tx.SenderKey)
+		buf = appendString(buf, tx.Signature)
+	}
+	if referenced {
+		buf = appendString(buf, tx.Reference)
+	}
+	if modelRecord {
+		var licence Licence
+		if tx.Licence != nil {
+			licence = *tx.Licence
+		}
+		buf = appendString(buf, tx.Model)
+		buf = appendString(buf, licence.ID)
+		buf = append(buf, boolByte(licence.Commercial), boolByte(licence.Attribution))
+	}
+	return buf
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+// Merkle tree node prefixes, so a leaf can never pass for an inner node
+const (
+	merkleLeaf  = 0x00
+	merkleInner = 0x01
+)
+
+// TxRoot is the Merkle root of the transactions: leaves are
+// SHA-256(0x00 | tx), inner nodes SHA-256(0x01 | left | right), and a node
+// without a sibling moves up a level unchanged. No transactions give 32
+// zero bytes.
+func TxRoot(txs []Transaction) [32]byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		leaves[i] = EncodeTransaction(tx)
+	}
+	return merkleRoot(leaves)
+}
+
+func merkleRoot(leaves [][]byte) [32]byte {
+	if len(leaves) == 0 {
+		return [32]byte{}
+	}
+
+	level := make([][32]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = sha256.Sum256(append([]byte{merkleLeaf}, leaf...))
+	}
+	for len(level) > 1 {
+		next := level[:0:0]
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node := append([]byte{merkleInner}, level[i][:]...)
+			next = append(next, sha256.Sum256(append(node, level[i+1][:]...)))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// CalculateHash returns the hash of the block for its version, or "" for a
+// version this node does not know; IsBlockValid rejects those
+func CalculateHash(block Block) string {
+	switch block.Version {
+	case VersionLegacyJSON:
+		return legacyHash(block)
+	case VersionCanonical, VersionTrainingProof, VersionValidator:
+		hash := sha256.Sum256(block.Header().Encode())
+		return hex.EncodeToString(hash[:])
+	default:
+		return ""
+	}
+}
+
+// legacyHash hashes the JSON encoding of the block with Hash and the
+// local AnchorTxID emptied
+func legacyHash(block Block) string {
+	temp := block
+	temp.Hash = ""
+	temp.AnchorTxID = ""
+
+	blockBytes, _ := json.Marshal(temp)
+	hash := sha256.Sum256(blockBytes)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// TestBlockHashVectors checks the canonical encoding against the vectors
+// other implementations are tested with
+func TestBlockHashVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/block_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []struct {
+		Name      string `json:"name"`
+		Block     Block  `json:"block"`
+		HeaderHex string `json:"header_hex"`
+		TxRoot    string `json:"tx_root"`
+		ProofRoot string `json:"proof_root"` // version 2 vectors
+		Hash      string `json:"hash"`
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			header := v.Block.Header()
+			if got := hex.EncodeToString(header.Encode()); got != v.HeaderHex {
+				t.Errorf("header encoding\n got %s\nwant %s", got, v.HeaderHex)
+			}
+			if got := hex.EncodeToString(header.TxRoot[:]); got != v.TxRoot {
+				t.Errorf("tx root %s, want %s", got, v.TxRoot)
+			}
+			if v.ProofRoot != "" {
+				if got := hex.EncodeToString(header.ProofRoot[:]); got != v.ProofRoot {
+					t.Errorf("proof root %s, want %s", got, v.ProofRoot)
+				}
+			}
+			if got := CalculateHash(v.Block); got != v.Hash || v.Block.Hash != v.Hash {
+				t.Errorf("hash %s, block says %s, want %s", got, v.Block.Hash, v.Hash)
+			}
+			if err := v.Block.ValidateClaims(); err != nil {
+				t.Errorf("dataset claims: %v", err)
+			}
+			if err := v.Block.ValidateModelRecords(); err != nil {
+				t.Errorf("model records: %v", err)
+			}
+			if v.Block.Signature != "" {
+				key, _ := hex.DecodeString(v.Block.Validator)
+				hash, _ := hex.DecodeString(v.Hash)
+				sig, _ := hex.DecodeString(v.Block.Signature)
+				if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, hash, sig) {
+					t.Error("signature does not verify")
+				}
+			}
+		})
+	}
+}
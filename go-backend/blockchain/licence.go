@@ -0,0 +1,176 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Receivers of model records. A licence record sets the terms of the model
+// mined in the block Model from the block holding it on; a usage record
+// is one image Sender generated from the model, under the terms in force
+// then, a nil Licence if there were none.
+const (
+	ReceiverLicence = "model:licence"
+	ReceiverUsage   = "model:usage"
+)
+
+// Licence is the terms a model's owner grants for the images generated
+// from it
+type Licence struct {
+	ID          string `json:"id"` // SPDX-like identifier, such as CC-BY-NC-4.0
+	Commercial  bool   `json:"commercial"`
+	Attribution bool   `json:"attribution"`
+}
+
+// licenceIDPattern is the charset of SPDX identifiers and LicenseRef-s
+var licenceIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]{0,63}$`)
+
+func (l Licence) Validate() error {
+	if !licenceIDPattern.MatchString(l.ID) {
+		return fmt.Errorf("licence id %q is not an SPDX-like identifier such as CC-BY-4.0", l.ID)
+	}
+	return nil
+}
+
+// IsLicence reports whether tx sets the licence of a model
+func (tx Transaction) IsLicence() bool {
+	return tx.Receiver == ReceiverLicence
+}
+
+// IsUsage reports whether tx records an image generated from a model
+func (tx Transaction) IsUsage() bool {
+	return tx.Receiver == ReceiverUsage
+}
+
+// ErrNotModelOwner means the sender of a licence record does not own the
+// model it sets the terms of
+var ErrNotModelOwner = errors.New("licence sender does not own the model")
+
+// ValidateModelRecord checks that a licence or usage record names a model
+// and that a licence carries valid terms, and that other transactions
+// carry neither. That the sender of a licence owns the model takes the
+// chain, see CheckLicence.
+func (tx Transaction) ValidateModelRecord() error {
+	if !tx.IsLicence() && !tx.IsUsage() {
+		if tx.Model != "" || tx.Licence != nil {
+			return fmt.Errorf("transaction of %s to %s carries model terms", tx.Sender, tx.Receiver)
+		}
+		return nil
+	}
+	if tx.Model == "" {
+		return fmt.Errorf("%s record of %s names no model", tx.Receiver, tx.Sender)
+	}
+	if tx.IsLicence() {
+		if tx.Licence == nil {
+			return errors.New("licence record without terms")
+		}
+		return tx.Licence.Validate()
+	}
+	return nil
+}
+
+// ValidateModelRecords checks the licence and usage records of the block
+func (b Block) ValidateModelRecords() error {
+	for _, tx := range b.Transactions {
+		if err := tx.ValidateModelRecord(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLicence reports why tx, if it is a licence record, may not follow
+// chain: the model must have been mined on chain with a training proof
+// whose owner is the sender or, for an organisation's model, the
+// organisation the record names. Who administers an organisation only its
+// nodes know; they check it before taking the record.
+func CheckLicence(chain []Block, tx Transaction) error {
+	if !tx.IsLicence() {
+		return nil
+	}
+	owner := tx.Sender
+	if tx.Organization != "" {
+		owner = "@" + tx.Organization
+	}
+	for _, block := range chain {
+		if block.Hash == tx.Model {
+			if block.TrainingProof != nil && block.TrainingProof.Owner == owner {
+				return nil
+			}
+			break
+		}
+	}
+	return fmt.Errorf("%w: %s for %s", ErrNotModelOwner, owner, tx.Model)
+}
+
+// CheckLicences is CheckLicence for every transaction of the block, which
+// follows chain
+func (b Block) CheckLicences(chain []Block) error {
+	for _, tx := range b.Transactions {
+		if err := CheckLicence(chain, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsageReference names the usage record of one generation from the model
+// mined in the block model; key tells generations apart, such as the hash
+// of the generated image
+func UsageReference(model, key string) string {
+	return "usage:" + model + ":" + key
+}
+
+// LicenceOf returns the terms the last licence record on chain set for the
+// model mined in the block model, nil if it has none
+func LicenceOf(chain []Block, model string) *Licence {
+	var licence *Licence
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			if tx.IsLicence() && tx.Model == model {
+				licence = tx.Licence
+			}
+		}
+	}
+	return licence
+}
+
+// Usage counts the images a user generated from a model under one licence
+type Usage struct {
+	User    string `json:"user"`
+	Licence string `json:"licence"` // licence ID, empty for images generated before the model had one
+	Images  int    `json:"images"`
+}
+
+// ModelUsage sums the usage records on chain of the model mined in the
+// block model, by user and licence
+func ModelUsage(chain []Block, model string) []Usage {
+	type key struct{ user, licence string }
+	counts := map[key]int{}
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			if !tx.IsUsage() || tx.Model != model {
+				continue
+			}
+			k := key{user: tx.Sender}
+			if tx.Licence != nil {
+				k.licence = tx.Licence.ID
+			}
+			counts[k]++
+		}
+	}
+
+	usage := make([]Usage, 0, len(counts))
+	for k, images := range counts {
+		usage = append(usage, Usage{User: k.user, Licence: k.licence, Images: images})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].User != usage[j].User {
+			return usage[i].User < usage[j].User
+		}
+		return usage[i].Licence < usage[j].Licence
+	})
+	return usage
+}
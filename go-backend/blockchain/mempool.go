@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Mempool holds transactions waiting to be included in the next block
+type Mempool struct {
+	mu  sync.Mutex
+	txs []Transaction
+}
+
+func NewMempool() *Mempool {
+	return &Mempool{}
+}
+
+// Add queues txs. Validator set changes are dropped: only the validator
+// that proposed one may include it in a block, so it never waits here. So
+// is a transaction whose reference is already pending.
+func (m *Mempool) Add(txs ...Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tx := range txs {
+		if !tx.IsValidatorChange() && !m.pendingReference(tx.Reference) {
+			m.txs = append(m.txs, tx)
+		}
+	}
+}
+
+func (m *Mempool) pendingReference(reference string) bool {
+	if reference == "" {
+		return false
+	}
+	for _, tx := range m.txs {
+		if tx.Reference == reference {
+			return true
+		}
+	}
+	return false
+}
+
+// Drain removes and returns every pending transaction
+func (m *Mempool) Drain() []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txs := m.txs
+	m.txs = nil
+	return txs
+}
+
+// Restore puts transactions back in front, used when a block was not accepted
+func (m *Mempool) Restore(txs []Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txs = append(append([]Transaction(nil), txs...), m.txs...)
+}
+
+// Remove drops one pending copy of each of txs, used when a block from a
+// peer already includes them. Transactions are compared by their encoding,
+// so a decoded copy matches although its Licence points elsewhere.
+func (m *Mempool) Remove(txs ...Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tx := range txs {
+		for i, pending := range m.txs {
+			if bytes.Equal(EncodeTransaction(pending), EncodeTransaction(tx)) {
+				m.txs = append(m.txs[:i], m.txs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Pending returns a copy of the pending transactions
+func (m *Mempool) Pending() []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Transaction(nil), m.txs...)
+}
+
+func (m *Mempool) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.txs)
+}
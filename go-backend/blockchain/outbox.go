@@ -0,0 +1,279 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
+
+// ErrPeerDiverged is returned when a peer at the parent of our next block
+// refuses it: its chain has forked from ours
+var ErrPeerDiverged = errors.New("peer refused the next block, its chain has diverged from ours")
+
+const (
+	// broadcastTimeout bounds a single delivery to a peer
+	broadcastTimeout = 10 * time.Second
+
+	// Failed deliveries are retried after retryBase, doubling up to retryMax
+	retryBase = 500 * time.Millisecond
+	retryMax  = 30 * time.Second
+
+	// maxQueuedTransactions bounds the transactions waiting for one peer;
+	// the oldest are dropped first
+	maxQueuedTransactions = 1000
+)
+
+type queuedTransaction struct {
+	tx        Transaction
+	requestID string
+}
+
+// outbox is the outbound replication queue of one peer. Blocks are not
+// queued one by one: the outbox tracks the height the peer acknowledged and
+// sends it our blocks after that in order, so a peer that was down or
+// missed blocks is caught up by the same loop that sends new ones.
+// Transactions are queued and go first.
+type outbox struct {
+	peers  *Peers
+	peer   string
+	wakeC  chan struct{}
+	ctx    context.Context // cancelled by Remove and Peers.Shutdown
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	txs       []queuedTransaction
+	acked     int    // the peer's tip from its last answer, -1 until the first
+	requestID string // of the latest block broadcast, for the peer's logs
+	sending   bool
+	failures  int
+	lastError string
+	lastAckAt time.Time
+	nextRetry time.Time
+}
+
+func newOutbox(p *Peers, peer string) *outbox {
+	o := &outbox{peers: p, peer: peer, wakeC: make(chan struct{}, 1), acked: -1}
+	o.ctx, o.cancel = context.WithCancel(p.closing)
+	return o
+}
+
+func (o *outbox) wake() {
+	select {
+	case o.wakeC <- struct{}{}:
+	default:
+	}
+}
+
+func (o *outbox) stop() {
+	o.cancel()
+}
+
+func (o *outbox) notifyBlock(requestID string) {
+	o.mu.Lock()
+	o.requestID = requestID
+	o.mu.Unlock()
+	o.wake()
+}
+
+func (o *outbox) queueTransaction(tx Transaction, requestID string) {
+	o.mu.Lock()
+	if len(o.txs) >= maxQueuedTransactions {
+		logging.FromContext(o.ctx).Warn("replication queue full, dropping the oldest transaction", "peer", o.peer)
+		o.txs = o.txs[1:]
+	}
+	o.txs = append(o.txs, queuedTransaction{tx: tx, requestID: requestID})
+	o.mu.Unlock()
+	o.wake()
+}
+
+func (o *outbox) run() {
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-o.wakeC:
+		}
+		o.drain()
+	}
+}
+
+// drain delivers until nothing is left, backing off after each failure
+func (o *outbox) drain() {
+	for o.ctx.Err() == nil {
+		var err error
+		if tx, ok := o.nextTransaction(); ok {
+			err = o.sendTransaction(tx)
+		} else if block, requestID, ok := o.nextBlock(); ok {
+			err = o.sendBlock(block, requestID)
+		} else {
+			return
+		}
+
+		if err == nil {
+			continue
+		}
+		timer := time.NewTimer(o.failed(err))
+		select {
+		case <-o.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (o *outbox) nextTransaction() (queuedTransaction, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.txs) == 0 {
+		return queuedTransaction{}, false
+	}
+	tx := o.txs[0]
+	o.txs = o.txs[1:]
+	o.sending = true
+	return tx, true
+}
+
+// nextBlock returns the block after the one the peer acknowledged, or our
+// tip while we do not know the peer's height yet
+func (o *outbox) nextBlock() (Block, string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	tip := o.peers.chain.LastBlock().Index
+	if !o.blocksPending(tip) {
+		return Block{}, "", false
+	}
+	index := o.acked + 1
+	if o.acked < 0 {
+		index = tip
+	}
+	block, ok := o.peers.chain.BlockAt(index)
+	if !ok {
+		return Block{}, "", false
+	}
+	o.sending = true
+	return block, o.requestID, true
+}
+
+func (o *outbox) blocksPending(tip int) bool {
+	return tip > 0 && o.acked < tip
+}
+
+func (o *outbox) sendContext(requestID string) (context.Context, context.CancelFunc) {
+	ctx := o.ctx
+	if requestID != "" {
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+	return context.WithTimeout(ctx, broadcastTimeout)
+}
+
+func (o *outbox) sendTransaction(item queuedTransaction) error {
+	ctx, cancel := o.sendContext(item.requestID)
+	defer cancel()
+	err := o.peers.transport.RelayTransaction(ctx, o.peer, item.tx)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sending = false
+	if err != nil {
+		// Back to the front to keep the order, unless the queue filled up
+		if len(o.txs) < maxQueuedTransactions {
+			o.txs = append([]queuedTransaction{item}, o.txs...)
+		}
+		return err
+	}
+	o.succeeded()
+	return nil
+}
+
+func (o *outbox) sendBlock(block Block, requestID string) error {
+	ctx, cancel := o.sendContext(requestID)
+	defer cancel()
+	ack, err := o.peers.transport.AnnounceBlock(ctx, o.peer, block)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sending = false
+	if err != nil {
+		return err
+	}
+	o.acked = ack.Height
+	o.lastAckAt = time.Now()
+	if !ack.Accepted && ack.Height == block.Index-1 {
+		return ErrPeerDiverged
+	}
+	if !ack.Accepted && ack.Height < block.Index {
+		logging.FromContext(ctx).Info("peer is behind, sending the missing blocks",
+			"peer", o.peer, "height", ack.Height, "tip", o.peers.chain.LastBlock().Index)
+	}
+	o.succeeded()
+	return nil
+}
+
+// succeeded resets the backoff; o.mu is held
+func (o *outbox) succeeded() {
+	o.failures = 0
+	o.lastError = ""
+	o.nextRetry = time.Time{}
+}
+
+// failed records a failed delivery and returns how long to wait before the
+// next attempt
+func (o *outbox) failed(err error) time.Duration {
+	o.mu.Lock()
+	o.failures++
+	o.lastError = err.Error()
+	delay := retryBase
+	for i := 1; i < o.failures && delay < retryMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retryMax)
+	o.nextRetry = time.Now().Add(delay)
+	failures := o.failures
+	o.mu.Unlock()
+
+	logging.FromContext(o.ctx).Warn("replication to peer failed",
+		"peer", o.peer, "failures", failures, "retry_in", delay.String(), "error", err)
+	if o.peers.OnBroadcastFailure != nil {
+		o.peers.OnBroadcastFailure(o.peer, err)
+	}
+	return delay
+}
+
+// settled reports whether the outbox has nothing in flight and nothing left
+// for a healthy peer
+func (o *outbox) settled(tip int) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.sending {
+		return false
+	}
+	return o.failures > 0 || (len(o.txs) == 0 && !o.blocksPending(tip))
+}
+
+func (o *outbox) status(tip int) ReplicationStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status := ReplicationStatus{
+		Peer:                o.peer,
+		AckedHeight:         o.acked,
+		Lag:                 max(tip-max(o.acked, 0), 0),
+		PendingTransactions: len(o.txs),
+		Failures:            o.failures,
+		LastError:           o.lastError,
+	}
+	if !o.lastAckAt.IsZero() {
+		lastAck := o.lastAckAt
+		status.LastAckAt = &lastAck
+	}
+	if !o.nextRetry.IsZero() {
+		nextRetry := o.nextRetry
+		status.NextRetryAt = &nextRetry
+	}
+	return status
+}
@@ -1,16 +1,15 @@
 package blockchain
 
 import (
-	"encoding/json"
-	"log"
-	"net/http"
+	"context"
+	"errors"
 	"net/url"
 	"strings"
 	"sync"
-)
+	"time"
 
-var peers = []string{} // List of peer nodes
-var peersMutex = &sync.Mutex{}
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
 
 func IsChainValid(chain []Block) bool {
 	for i := 1; i < len(chain); i++ {
@@ -21,46 +20,191 @@ func IsChainValid(chain []Block) bool {
 	return true
 }
 
-// AddPeer adds a new peer to the list
-func AddPeer(peer string) {
-	peersMutex.Lock()
-	defer peersMutex.Unlock()
+var ErrInvalidPeer = errors.New("invalid peer address")
+
+// NormalizePeer accepts "host:port" or "http(s)://host:port[/]" and returns
+// the base URL used to reach the peer.
+func NormalizePeer(peer string) (string, error) {
+	peer = strings.TrimSpace(peer)
+	if !strings.Contains(peer, "://") {
+		peer = "http://" + peer
+	}
+
+	u, err := url.Parse(peer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrInvalidPeer
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// Ack is a peer's answer to an announced block
+type Ack struct {
+	Accepted bool
+	Height   int // index of the peer's last block after handling ours
+}
+
+// Transport delivers replication traffic to one peer, identified by its
+// base URL. The replication package implements it over gRPC.
+type Transport interface {
+	AnnounceBlock(ctx context.Context, peer string, block Block) (Ack, error)
+	RelayTransaction(ctx context.Context, peer string, tx Transaction) error
+}
+
+// Peers is the set of nodes this node replicates blocks and transactions to.
+// Each peer has an outbound queue drained by its own goroutine, see outbox.
+type Peers struct {
+	mu        sync.Mutex
+	peers     []string
+	outboxes  map[string]*outbox
+	chain     *Chain
+	transport Transport
+
+	// closing stops the outboxes; workers tracks their goroutines
+	workers sync.WaitGroup
+	closing context.Context
+	close   context.CancelFunc
+
+	// OnBroadcastFailure, if set, is called when a peer could not be reached
+	// or refused a block it should have been able to apply
+	OnBroadcastFailure func(peer string, err error)
+}
+
+// NewPeers replicates chain to the peers through transport
+func NewPeers(chain *Chain, transport Transport) *Peers {
+	p := &Peers{outboxes: make(map[string]*outbox), chain: chain, transport: transport}
+	p.closing, p.close = context.WithCancel(context.Background())
+	return p
+}
 
-	// Validate the peer URL
-	if _, err := url.ParseRequestURI(peer); err != nil {
-		return // Invalid URL, do not add
+// Add adds a new peer to the list. Its outbox starts by finding out how far
+// behind the peer is and sending it the blocks it misses.
+func (p *Peers) Add(peer string) error {
+	normalized, err := NormalizePeer(peer)
+	if err != nil {
+		return err
 	}
 
-	for _, p := range peers {
-		if p == peer {
-			return // Peer already exists
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.outboxes[normalized]; ok {
+		return nil // Peer already exists
+	}
+	o := newOutbox(p, normalized)
+	p.peers = append(p.peers, normalized)
+	p.outboxes[normalized] = o
+
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		o.run()
+	}()
+	o.wake()
+	return nil
+}
+
+// Remove drops a peer given by its normalised base URL and discards what
+// was queued for it
+func (p *Peers) Remove(peer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o, ok := p.outboxes[peer]
+	if !ok {
+		return
+	}
+	o.stop()
+	delete(p.outboxes, peer)
+	for i, existing := range p.peers {
+		if existing == peer {
+			p.peers = append(p.peers[:i], p.peers[i+1:]...)
+			break
 		}
 	}
-	peers = append(peers, peer)
-}
-
-// GetPeers returns the list of peers
-func GetPeers() []string {
-	peersMutex.Lock()
-	defer peersMutex.Unlock()
-	return peers
-}
-
-// BroadcastBlock sends the new block to all peers
-func BroadcastBlock(block Block) {
-	peersMutex.Lock()
-	defer peersMutex.Unlock()
-
-	for _, peer := range peers {
-		go func(peer string) {
-			data, _ := json.Marshal(block)
-			log.Printf("Broadcasting block to peer: %s", peer) // Add this log
-			resp, err := http.Post("http://"+peer+"/api/receive-block", "application/json", strings.NewReader(string(data)))
-			if err != nil {
-				log.Printf("Failed to send block to peer %s: %v", peer, err)
-				return
-			}
-			defer resp.Body.Close()
-		}(peer)
+}
+
+// List returns a copy of the peer base URLs
+func (p *Peers) List() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.peers...)
+}
+
+func (p *Peers) each(fn func(o *outbox)) {
+	p.mu.Lock()
+	outboxes := make([]*outbox, 0, len(p.peers))
+	for _, peer := range p.peers {
+		outboxes = append(outboxes, p.outboxes[peer])
+	}
+	p.mu.Unlock()
+
+	for _, o := range outboxes {
+		fn(o)
+	}
+}
+
+// BroadcastBlock queues the chain's new tip for every peer. Delivery is
+// retried with backoff until the peer acknowledges it; the request ID of
+// ctx is passed on so the peers' logs can be correlated.
+func (p *Peers) BroadcastBlock(ctx context.Context, block Block) {
+	logging.FromContext(ctx).Info("broadcasting block", "index", block.Index, "hash", block.Hash)
+	requestID := logging.RequestID(ctx)
+	p.each(func(o *outbox) { o.notifyBlock(requestID) })
+}
+
+// BroadcastTransaction relays a transaction added on this node to all peers
+func (p *Peers) BroadcastTransaction(ctx context.Context, tx Transaction) {
+	requestID := logging.RequestID(ctx)
+	p.each(func(o *outbox) { o.queueTransaction(tx, requestID) })
+}
+
+// ReplicationStatus is how far replication to one peer has got
+type ReplicationStatus struct {
+	Peer                string     `json:"peer"`
+	AckedHeight         int        `json:"acked_height"` // the peer's last known tip, -1 until it first answered
+	Lag                 int        `json:"lag"`          // blocks of ours the peer has not acknowledged
+	PendingTransactions int        `json:"pending_transactions"`
+	Failures            int        `json:"failures"` // consecutive failed deliveries
+	LastError           string     `json:"last_error,omitempty"`
+	LastAckAt           *time.Time `json:"last_ack_at,omitempty"`
+	NextRetryAt         *time.Time `json:"next_retry_at,omitempty"`
+}
+
+// Status reports replication progress for every peer
+func (p *Peers) Status() []ReplicationStatus {
+	tip := p.chain.LastBlock().Index
+	var statuses []ReplicationStatus
+	p.each(func(o *outbox) { statuses = append(statuses, o.status(tip)) })
+	return statuses
+}
+
+// shutdownPoll is how often Shutdown checks whether the outboxes are done
+const shutdownPoll = 10 * time.Millisecond
+
+// Shutdown waits until every peer has received what was queued for it, or
+// is failing and waiting to retry, or ctx is done. It then stops the
+// outboxes, cancelling deliveries still running, and returns ctx.Err() if
+// it had to cancel any. Deliveries queued after Shutdown are dropped.
+func (p *Peers) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+
+	var err error
+	for err == nil && !p.settled() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
 	}
+	p.close()
+	p.workers.Wait()
+	return err
+}
+
+// settled reports whether no outbox has a delivery in flight or a healthy
+// peer still waiting for something
+func (p *Peers) settled() bool {
+	tip := p.chain.LastBlock().Index
+	settled := true
+	p.each(func(o *outbox) { settled = settled && o.settled(tip) })
+	return settled
 }
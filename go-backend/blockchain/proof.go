@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// TrainingProof describes the training run behind a block precisely enough
+// to check it later: the AI service can reload the weights and regenerate
+// the sample from the seed, and the dataset root commits to the images the
+// model was trained on.
+type TrainingProof struct {
+	// Owner and ModelName locate the weights in the AI service: the
+	// username, or "@" and the organisation name, and the model name
+	Owner     string `json:"owner"`
+	ModelName string `json:"model_name"`
+
+	WeightsHash     string          `json:"weights_hash"` // SHA-256 of the saved generator weights
+	DatasetRoot     string          `json:"dataset_root"` // see DatasetRoot
+	Seed            int64           `json:"seed"`         // seeds the latent vector of the sample
+	Hyperparameters Hyperparameters `json:"hyperparameters"`
+	SampleHash      string          `json:"sample_hash"` // SHA-256 of the sample generated from Seed
+}
+
+// Hyperparameters are the settings the model was trained with
+type Hyperparameters struct {
+	Epochs       int     `json:"epochs"`
+	LatentDim    int     `json:"latent_dim"`
+	BatchSize    int     `json:"batch_size"`
+	LearningRate float64 `json:"lr"`
+	NCritic      int     `json:"n_critic"`
+	LambdaGP     float64 `json:"lambda_gp"`
+}
+
+// Encode returns the canonical encoding of the proof, in the conventions of
+// Header.Encode. Floats are their IEEE 754 binary64 bits.
+//
+//	str owner | str model_name | str weights_hash | str dataset_root
+//	i64 seed | u32 epochs | u32 latent_dim | u32 batch_size | f64 lr
+//	u32 n_critic | f64 lambda_gp | str sample_hash
+func (p TrainingProof) Encode() []byte {
+	hp := p.Hyperparameters
+
+	var buf []byte
+	buf = appendString(buf, p.Owner)
+	buf = appendString(buf, p.ModelName)
+	buf = appendString(buf, p.WeightsHash)
+	buf = appendString(buf, p.DatasetRoot)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(p.Seed))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(hp.Epochs))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(hp.LatentDim))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(hp.BatchSize))
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(hp.LearningRate))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(hp.NCritic))
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(hp.LambdaGP))
+	return appendString(buf, p.SampleHash)
+}
+
+// ProofRoot is the SHA-256 of the encoded training proof, or 32 zero bytes
+// for a block without one
+func ProofRoot(p *TrainingProof) [32]byte {
+	if p == nil {
+		return [32]byte{}
+	}
+	return sha256.Sum256(p.Encode())
+}
+
+// DatasetRoot is the Merkle root, in the scheme of TxRoot, of the SHA-256
+// hex digests of the training images. The digests are sorted first, so the
+// root does not depend on upload order.
+func DatasetRoot(imageHashes []string) [32]byte {
+	sorted := append([]string(nil), imageHashes...)
+	sort.Strings(sorted)
+
+	leaves := make([][]byte, len(sorted))
+	for i, hash := range sorted {
+		leaves[i] = []byte(hash)
+	}
+	return merkleRoot(leaves)
+}
+
+// ValidateProof checks that the block's training proof, if any, is covered
+// by its hash and agrees with Proof
+func (b Block) ValidateProof() error {
+	if b.TrainingProof == nil {
+		return nil
+	}
+	if b.Version < VersionTrainingProof {
+		return errors.New("training proof on a block version that does not hash it")
+	}
+	if b.Proof != b.TrainingProof.SampleHash {
+		return errors.New("proof is not the sample hash of the training proof")
+	}
+	return nil
+}
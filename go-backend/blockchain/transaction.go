@@ -1,8 +1,56 @@
 package blockchain
 
 type Transaction struct {
-	Sender    string  `json:"sender"`
-	Receiver  string  `json:"receiver"`
-	Amount    float64 `json:"amount"`
+	Sender    string  `json:"sender" binding:"required"`
+	Receiver  string  `json:"receiver" binding:"required"`
+	Amount    float64 `json:"amount" binding:"gte=0"`
 	ImageHash string  `json:"image_hash"` // New field for storing image hash
+	// Organization is set when Sender acted on behalf of an organisation
+	Organization string `json:"organization,omitempty"`
+	// ValidatorKey is the hex public key a validator set change adds or
+	// removes, see IsValidatorChange
+	ValidatorKey string `json:"validator_key,omitempty"`
+	// SenderKey is the signing key Sender registered, as ParseSigningKey
+	// reads it, and Signature its hex signature of the dataset claim the
+	// transaction is part of; see Block.ValidateClaims. Both are empty for
+	// transactions the sender did not sign.
+	SenderKey string `json:"sender_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// Reference names the event a reward pays for, see IsReward. No two
+	// transactions on a chain share one, so an event is credited once.
+	Reference string `json:"reference,omitempty"`
+	// Model is the hash of the block a model was mined in and Licence the
+	// terms of the model, on licence and usage records; see IsLicence
+	Model   string   `json:"model,omitempty"`
+	Licence *Licence `json:"licence,omitempty"`
+}
+
+// Receivers of validator set changes. Such a transaction is sent by the
+// validator producing the block that holds it and takes effect from the
+// next block.
+const (
+	ReceiverAddValidator    = "validator_set:add"
+	ReceiverRemoveValidator = "validator_set:remove"
+)
+
+// IsValidatorChange reports whether tx changes the validator set
+func (tx Transaction) IsValidatorChange() bool {
+	return tx.Receiver == ReceiverAddValidator || tx.Receiver == ReceiverRemoveValidator
+}
+
+// SenderRewards is the sender of rewards, credits minted for the owners of
+// training images; see package rewards
+const SenderRewards = "rewards"
+
+// IsReward reports whether tx is a reward
+func (tx Transaction) IsReward() bool {
+	return tx.Sender == SenderRewards
+}
+
+// IsTransfer reports whether tx is a plain transfer between users: no
+// reward, validator change, licence or usage record, image or signed claim
+func (tx Transaction) IsTransfer() bool {
+	return !tx.IsReward() && !tx.IsValidatorChange() && !tx.IsLicence() && !tx.IsUsage() &&
+		tx.Reference == "" && tx.Model == "" && tx.Licence == nil &&
+		tx.ImageHash == "" && tx.SenderKey == "" && tx.Signature == ""
 }
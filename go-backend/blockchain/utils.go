@@ -1,21 +1,5 @@
 package blockchain
 
-import (
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
-)
-
-func CalculateHash(block Block) string {
-	// Clone block with Hash emptied
-	temp := block
-	temp.Hash = ""
-
-	blockBytes, _ := json.Marshal(temp)
-	hash := sha256.Sum256(blockBytes)
-	return hex.EncodeToString(hash[:])
-}
-
 func IsBlockValid(newBlock, prevBlock Block) bool {
 	// 1. Check index
 	if newBlock.Index != prevBlock.Index+1 {
@@ -27,11 +11,34 @@ func IsBlockValid(newBlock, prevBlock Block) bool {
 		return false
 	}
 
-	// 3. Recalculate the hash and compare
+	// 3. Versions only move forward, up to the newest this node knows
+	if newBlock.Version < prevBlock.Version || newBlock.Version > CurrentVersion {
+		return false
+	}
+
+	// 4. Recalculate the hash and compare
 	expectedHash := CalculateHash(newBlock)
 	if newBlock.Hash != expectedHash {
 		return false
 	}
 
+	// 5. The training proof and the validator must be covered by that hash
+	if newBlock.ValidateProof() != nil {
+		return false
+	}
+	if newBlock.Validator != "" && newBlock.Version < VersionValidator {
+		return false
+	}
+
+	// 6. Signed dataset claims must verify
+	if newBlock.ValidateClaims() != nil {
+		return false
+	}
+
+	// 7. Licence and usage records must be well formed
+	if newBlock.ValidateModelRecords() != nil {
+		return false
+	}
+
 	return true
 }
@@ -0,0 +1,227 @@
+// Package bundle reads and writes chain archives: the blocks of a node and
+// the models recorded against them, closed by a manifest that commits to
+// everything before it. Bundles are JSON Lines or a CBOR sequence of the
+// same records and can be verified without a database.
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Version is the bundle format written by Write
+const Version = 1
+
+// Format is the encoding of a bundle
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCBOR  Format = "cbor"
+)
+
+// maxRecord bounds one JSON Lines record; blocks hold one transaction per
+// training image
+const maxRecord = 64 << 20
+
+// Manifest is the last record of a bundle. RecordsSHA256 is the SHA-256 of
+// the encoded records before it, so changing, adding or dropping a record
+// is detected. Validators is the genesis validator set of a
+// proof-of-authority chain, for Verify to check the block seals against.
+type Manifest struct {
+	Version       int       `json:"version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Blocks        int       `json:"blocks"`
+	Models        int       `json:"models"`
+	TipHash       string    `json:"tip_hash"`
+	RecordsSHA256 string    `json:"records_sha256"`
+	Validators    []string  `json:"validators,omitempty"`
+}
+
+// Bundle is a decoded archive
+type Bundle struct {
+	Manifest Manifest
+	Blocks   []blockchain.Block
+	Models   []models.Model
+
+	// recordsSHA256 is the digest of the records as read, for Verify
+	recordsSHA256 string
+}
+
+// Record types
+const (
+	recordBlock    = "block"
+	recordModel    = "model"
+	recordManifest = "manifest"
+)
+
+// record is one line of JSON Lines or one item of the CBOR sequence
+type record struct {
+	Type     string            `json:"type"`
+	Block    *blockchain.Block `json:"block,omitempty"`
+	Model    *models.Model     `json:"model,omitempty"`
+	Manifest *Manifest         `json:"manifest,omitempty"`
+}
+
+// Timestamps are kept as RFC 3339 text with nanoseconds: block hashes cover
+// the exact text, so CBOR's default of whole Unix seconds would break them
+var cborEncoding, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+var cborDecoding, _ = cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+
+var ErrUnsupportedFormat = errors.New("unsupported bundle format")
+
+func encodeRecord(format Format, r record) ([]byte, error) {
+	switch format {
+	case FormatJSONL:
+		data, err := json.Marshal(r)
+		return append(data, '\n'), err
+	case FormatCBOR:
+		return cborEncoding.Marshal(r)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// Write encodes blocks and models as a bundle and returns its manifest.
+// validators is the genesis validator set of a proof-of-authority chain,
+// nil for proof of work.
+func Write(w io.Writer, format Format, blocks []blockchain.Block, modelList []models.Model, validators []string) (Manifest, error) {
+	digest := sha256.New()
+	out := io.MultiWriter(w, digest)
+	write := func(r record) error {
+		data, err := encodeRecord(format, r)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	for i := range blocks {
+		if err := write(record{Type: recordBlock, Block: &blocks[i]}); err != nil {
+			return Manifest{}, err
+		}
+	}
+	for i := range modelList {
+		if err := write(record{Type: recordModel, Model: &modelList[i]}); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	manifest := Manifest{
+		Version:       Version,
+		CreatedAt:     time.Now().UTC(),
+		Blocks:        len(blocks),
+		Models:        len(modelList),
+		RecordsSHA256: hex.EncodeToString(digest.Sum(nil)),
+		Validators:    validators,
+	}
+	if len(blocks) > 0 {
+		manifest.TipHash = blocks[len(blocks)-1].Hash
+	}
+	data, err := encodeRecord(format, record{Type: recordManifest, Manifest: &manifest})
+	if err != nil {
+		return Manifest{}, err
+	}
+	_, err = w.Write(data)
+	return manifest, err
+}
+
+// Read decodes a bundle of either format, telling them apart by the first
+// byte. It fails on malformed records but leaves checking the content to
+// Verify.
+func Read(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("empty bundle: %w", err)
+	}
+
+	next := nextCBOR(br)
+	format := FormatCBOR
+	if first[0] == '{' {
+		next, format = nextJSONL(br), FormatJSONL
+	}
+
+	b := &Bundle{}
+	digest := sha256.New()
+	for n := 1; ; n++ {
+		raw, rec, err := next()
+		if err == io.EOF {
+			return nil, errors.New("bundle has no manifest, it may be truncated")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s record %d: %w", format, n, err)
+		}
+
+		switch rec.Type {
+		case recordBlock:
+			if rec.Block == nil {
+				return nil, fmt.Errorf("record %d: block record without a block", n)
+			}
+			b.Blocks = append(b.Blocks, *rec.Block)
+		case recordModel:
+			if rec.Model == nil {
+				return nil, fmt.Errorf("record %d: model record without a model", n)
+			}
+			b.Models = append(b.Models, *rec.Model)
+		case recordManifest:
+			if rec.Manifest == nil {
+				return nil, fmt.Errorf("record %d: manifest record without a manifest", n)
+			}
+			if _, _, err := next(); err != io.EOF {
+				return nil, fmt.Errorf("record %d: data after the manifest", n+1)
+			}
+			b.Manifest = *rec.Manifest
+			b.recordsSHA256 = hex.EncodeToString(digest.Sum(nil))
+			return b, nil
+		default:
+			return nil, fmt.Errorf("record %d: unknown record type %q", n, rec.Type)
+		}
+		digest.Write(raw)
+	}
+}
+
+type nextFunc func() (raw []byte, rec record, err error)
+
+func nextJSONL(br *bufio.Reader) nextFunc {
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxRecord)
+	return func() ([]byte, record, error) {
+		var rec record
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, rec, err
+			}
+			return nil, rec, io.EOF
+		}
+		raw := append(bytes.Clone(scanner.Bytes()), '\n')
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		return raw, rec, decoder.Decode(&rec)
+	}
+}
+
+func nextCBOR(br *bufio.Reader) nextFunc {
+	decoder := cbor.NewDecoder(br)
+	return func() ([]byte, record, error) {
+		var rec record
+		var raw cbor.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, rec, err
+		}
+		return raw, rec, cborDecoding.Unmarshal(raw, &rec)
+	}
+}
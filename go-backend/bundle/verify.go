@@ -0,0 +1,121 @@
+package bundle
+
+import (
+	"fmt"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+)
+
+// genesisHash is the fixed hash of every node's genesis block
+const genesisHash = "genesis_hash"
+
+// Problem is one failed check of Verify
+type Problem struct {
+	Where string // "manifest", "block 3", "model 7"...
+	What  string
+}
+
+func (p Problem) String() string {
+	return p.Where + ": " + p.What
+}
+
+// Verify checks a bundle on its own: the manifest against the records, the
+// version, hash, training proof, dataset claims and model records of every
+// block and its link to the previous one, and that every model points at a
+// block of the chain. When the manifest lists validators, every block must
+// be sealed by one of the set in effect, replaying the set's changes block
+// by block; without them no block may carry a seal. The manifest's set is
+// only as trustworthy as the bundle: an importing node checks the chain
+// against its own. Verify returns every problem found, none for a sound
+// bundle.
+func Verify(b *Bundle) []Problem {
+	var problems []Problem
+	report := func(where, format string, args ...any) {
+		problems = append(problems, Problem{Where: where, What: fmt.Sprintf(format, args...)})
+	}
+
+	m := b.Manifest
+	if m.Version != Version {
+		report("manifest", "unsupported version %d, this tool reads version %d", m.Version, Version)
+	}
+	if m.RecordsSHA256 != b.recordsSHA256 {
+		report("manifest", "records hash %s does not match the records (%s)", m.RecordsSHA256, b.recordsSHA256)
+	}
+	if m.Blocks != len(b.Blocks) {
+		report("manifest", "lists %d blocks, the bundle has %d", m.Blocks, len(b.Blocks))
+	}
+	if m.Models != len(b.Models) {
+		report("manifest", "lists %d models, the bundle has %d", m.Models, len(b.Models))
+	}
+	if len(b.Blocks) == 0 {
+		report("manifest", "bundle holds no blocks")
+		return problems
+	}
+	if tip := b.Blocks[len(b.Blocks)-1].Hash; m.TipHash != tip {
+		report("manifest", "tip hash %s, the last block is %s", m.TipHash, tip)
+	}
+
+	if genesis := b.Blocks[0]; genesis.Index != 0 || genesis.Hash != genesisHash {
+		report("block 0", "not a genesis block (index %d, hash %s)", genesis.Index, genesis.Hash)
+	}
+	genesis := make([]string, len(m.Validators))
+	for i, validator := range m.Validators {
+		var err error
+		if genesis[i], err = consensus.NormalizeKey(validator); err != nil {
+			report("manifest", "validator %d: %v", i, err)
+		}
+	}
+	validators := consensus.NewSet(genesis)
+
+	hashes := map[string]int{b.Blocks[0].Hash: 0}
+	for i := 1; i < len(b.Blocks); i++ {
+		block, prev := b.Blocks[i], b.Blocks[i-1]
+		where := fmt.Sprintf("block %d", i)
+		if block.Index != prev.Index+1 {
+			report(where, "index %d follows %d", block.Index, prev.Index)
+		}
+		if block.PrevHash != prev.Hash {
+			report(where, "previous hash %s, the block before is %s", block.PrevHash, prev.Hash)
+		}
+		if block.Version < prev.Version || block.Version > blockchain.CurrentVersion {
+			report(where, "version %d after version %d, this tool knows up to %d", block.Version, prev.Version, blockchain.CurrentVersion)
+		} else if want := blockchain.CalculateHash(block); block.Hash != want {
+			report(where, "hash %s, its content hashes to %s", block.Hash, want)
+		}
+		if err := block.ValidateProof(); err != nil {
+			report(where, "%v", err)
+		}
+		if err := block.ValidateClaims(); err != nil {
+			report(where, "%v", err)
+		}
+		if err := block.ValidateModelRecords(); err != nil {
+			report(where, "%v", err)
+		}
+		if err := block.CheckLicences(b.Blocks[:i]); err != nil {
+			report(where, "%v", err)
+		}
+		switch {
+		case len(m.Validators) > 0:
+			if err := consensus.CheckSeal(validators.Validators, block); err != nil {
+				report(where, "%v", err)
+			}
+			next, err := consensus.ApplyChanges(validators, block)
+			if err != nil {
+				report(where, "%v", err)
+			} else {
+				validators = next
+			}
+		case block.Validator != "" || block.Signature != "":
+			report(where, "sealed by %q, but the manifest lists no validators", block.Validator)
+		}
+		hashes[block.Hash] = i
+	}
+
+	for _, model := range b.Models {
+		if _, ok := hashes[model.Hash]; !ok {
+			report(fmt.Sprintf("model %d", model.ID), "%q refers to block %s, which is not in the chain", model.Name, model.Hash)
+		}
+	}
+	return problems
+}
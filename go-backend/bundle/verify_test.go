@@ -0,0 +1,137 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+)
+
+// readBack writes blocks as a bundle and reads it again, as Verify only
+// checks bundles that were read
+func readBack(t *testing.T, blocks []blockchain.Block, validators []string) *Bundle {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := Write(&buf, FormatJSONL, blocks, nil, validators); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// seal appends a block holding txs to blocks, sealed with key
+func seal(t *testing.T, blocks []blockchain.Block, key ed25519.PrivateKey, txs ...blockchain.Transaction) []blockchain.Block {
+	t.Helper()
+
+	authority, err := consensus.New([]string{consensus.PublicKeyHex(key)}, time.Second, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := blockchain.GenerateBlock(blocks[len(blocks)-1], txs, "")
+	if err := authority.Seal(&block); err != nil {
+		t.Fatal(err)
+	}
+	return append(blocks, block)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	blocks := []blockchain.Block{blockchain.NewGenesisBlock()}
+	for i := 0; i < 3; i++ {
+		tx := blockchain.Transaction{Sender: "alice", Receiver: "blockchain", ImageHash: fmt.Sprintf("alice-%d", i)}
+		blocks = append(blocks, blockchain.GenerateBlock(blocks[i], []blockchain.Transaction{tx}, "proof"))
+	}
+
+	var buf bytes.Buffer
+	if _, err := Write(&buf, FormatJSONL, blocks, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	original := buf.String()
+
+	// Rewriting a block's proof breaks its hash and the manifest
+	tampered := strings.Replace(original, `"proof":"proof"`, `"proof":"forged"`, 1)
+	b, err := Read(strings.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := Verify(b)
+	if len(problems) != 2 || problems[0].Where != "manifest" || problems[1].Where != "block 1" {
+		t.Fatalf("problems %v, want the manifest hash and block 1", problems)
+	}
+
+	// Dropping a record also fails, even with a matching manifest count
+	lines := strings.SplitAfter(original, "\n")
+	dropped := strings.Join(append(lines[:2:2], lines[3:]...), "")
+	if b, err = Read(strings.NewReader(dropped)); err != nil {
+		t.Fatal(err)
+	}
+	if problems := Verify(b); len(problems) == 0 {
+		t.Fatal("bundle with a dropped block verified")
+	}
+
+	// A bundle cut short has no manifest
+	if _, err := Read(strings.NewReader(strings.Join(lines[:3], ""))); err == nil {
+		t.Fatal("truncated bundle read without error")
+	}
+}
+
+func TestVerifyChecksSeals(t *testing.T) {
+	alice, bob, mallory := newKey(t), newKey(t), newKey(t)
+	genesis := []string{consensus.PublicKeyHex(alice)}
+
+	// Alice adds Bob, who may then seal blocks
+	blocks := []blockchain.Block{blockchain.NewGenesisBlock()}
+	blocks = seal(t, blocks, alice, blockchain.Transaction{
+		Sender:       consensus.PublicKeyHex(alice),
+		Receiver:     blockchain.ReceiverAddValidator,
+		ValidatorKey: consensus.PublicKeyHex(bob),
+	})
+	blocks = seal(t, blocks, bob)
+	if problems := Verify(readBack(t, blocks, genesis)); len(problems) > 0 {
+		t.Fatalf("sealed chain has problems: %v", problems)
+	}
+
+	// Bob was not a validator before Alice's block
+	if problems := Verify(readBack(t, blocks, []string{consensus.PublicKeyHex(bob)})); len(problems) == 0 {
+		t.Fatal("block sealed before its validator was added verified")
+	}
+
+	// Without a validator set the seals cannot be checked
+	if problems := Verify(readBack(t, blocks, nil)); len(problems) != 2 {
+		t.Fatalf("problems %v, want both sealed blocks", problems)
+	}
+
+	// Mallory is never a validator
+	forged := seal(t, blocks, mallory)
+	problems := Verify(readBack(t, forged, genesis))
+	if len(problems) != 1 || problems[0].Where != "block 3" {
+		t.Fatalf("problems %v, want block 3", problems)
+	}
+
+	// A signature that is not the validator's over the hash
+	forged = seal(t, blocks, bob)
+	forged[3].Signature = strings.Repeat("0", len(forged[3].Signature))
+	problems = Verify(readBack(t, forged, genesis))
+	if len(problems) != 1 || problems[0].Where != "block 3" {
+		t.Fatalf("problems %v, want block 3", problems)
+	}
+}
@@ -0,0 +1,142 @@
+// Package client is a typed Go client for the go-backend HTTP API. The
+// methods in client_gen.go are generated from api/openapi.json; this file
+// holds the transport they share.
+package client
+
+//go:generate go run ../cmd/apigen -o client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+)
+
+// Client calls one node. Token, when set, is sent as a bearer token; Login
+// does not set it.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is a non-2xx response
+type Error struct {
+	StatusCode int
+	Message    string // the error field of the body, or the body itself
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Form is a multipart request body
+type Form struct {
+	Fields map[string]string
+	Files  []FormFile
+}
+
+type FormFile struct {
+	Field    string // form field, e.g. "images"
+	Filename string
+	Content  []byte
+}
+
+// requestBody encodes itself and returns its content type
+type requestBody interface {
+	encode() (io.Reader, string, error)
+}
+
+type jsonRequest struct{ v any }
+
+func jsonBody(v any) requestBody { return jsonRequest{v} }
+
+func (j jsonRequest) encode() (io.Reader, string, error) {
+	data, err := json.Marshal(j.v)
+	return bytes.NewReader(data), "application/json", err
+}
+
+func (f Form) encode() (io.Reader, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, file := range f.Files {
+		part, err := w.CreateFormFile(file.Field, file.Filename)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(file.Content)
+	}
+	for k, v := range f.Fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// do sends the request and decodes a 2xx response into out, which is a
+// *[]byte for raw responses
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body requestBody, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		var err error
+		if reader, contentType, err = body.encode(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr api.ErrorResponse
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
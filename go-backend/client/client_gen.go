@@ -0,0 +1,486 @@
+// Code generated by apigen from api/openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+)
+
+// AddPeer calls POST /api/add-peer: Replicate blocks to another node
+func (c *Client) AddPeer(ctx context.Context, body api.AddPeerRequest) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/add-peer", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAuditEventsParams holds the query parameters of ListAuditEvents
+type ListAuditEventsParams struct {
+	Action string // Only this action
+	Actor  string // Only this actor
+	Before int    // Only events with a smaller ID, for paging
+	Limit  int    // At most this many events (default 100, max 1000)
+}
+
+func (p ListAuditEventsParams) values() url.Values {
+	q := url.Values{}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	if p.Actor != "" {
+		q.Set("actor", p.Actor)
+	}
+	if p.Before != 0 {
+		q.Set("before", strconv.Itoa(p.Before))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// ListAuditEvents calls GET /api/admin/audit: Read the audit trail, newest first
+func (c *Client) ListAuditEvents(ctx context.Context, params ListAuditEventsParams) (*api.AuditEventsResponse, error) {
+	var out api.AuditEventsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/admin/audit", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ChangeValidator calls POST /api/admin/validators: Propose a validator set change; it applies once a majority of the validators proposed it
+func (c *Client) ChangeValidator(ctx context.Context, body api.ValidatorChangeRequest) (*api.ValidatorChangeResponse, error) {
+	var out api.ValidatorChangeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/admin/validators", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetBlockAnchor calls GET /api/blocks/{index}/anchor: Show where a block is anchored in the sample_blockchain UTXO chain
+func (c *Client) GetBlockAnchor(ctx context.Context, index string) (*api.BlockAnchorResponse, error) {
+	var out api.BlockAnchorResponse
+	if err := c.do(ctx, http.MethodGet, "/api/blocks/"+url.PathEscape(index)+"/anchor", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VerifyProof calls POST /api/blocks/{index}/verify-proof: Check a block's training proof by regenerating its sample
+func (c *Client) VerifyProof(ctx context.Context, index string) (*api.VerifyProofResponse, error) {
+	var out api.VerifyProofResponse
+	if err := c.do(ctx, http.MethodPost, "/api/blocks/"+url.PathEscape(index)+"/verify-proof", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetChain calls GET /api/chain: Return this node's chain
+func (c *Client) GetChain(ctx context.Context) (*api.ChainResponse, error) {
+	var out api.ChainResponse
+	if err := c.do(ctx, http.MethodGet, "/api/chain", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CheckImage calls POST /api/check-image: Look up whether an image was used for training
+func (c *Client) CheckImage(ctx context.Context, form Form) (*api.CheckImageResponse, error) {
+	var out api.CheckImageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/check-image", nil, form, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GenerateImageParams holds the query parameters of GenerateImage
+type GenerateImageParams struct {
+	ModelName    string // Model to generate with
+	Username     string // Owner of a personal model
+	Organization string // Owner of an organisation model
+}
+
+func (p GenerateImageParams) values() url.Values {
+	q := url.Values{}
+	if p.ModelName != "" {
+		q.Set("model_name", p.ModelName)
+	}
+	if p.Username != "" {
+		q.Set("username", p.Username)
+	}
+	if p.Organization != "" {
+		q.Set("organization", p.Organization)
+	}
+	return q
+}
+
+// GenerateImage calls GET /api/generate-image: Generate an image with a trained model and add it to the model's gallery
+func (c *Client) GenerateImage(ctx context.Context, params GenerateImageParams) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/api/generate-image", params.values(), nil, &out)
+	return out, err
+}
+
+// GetGeneratedImage calls GET /api/generated-images/{hash}: Find the model and block that produced a generated image
+func (c *Client) GetGeneratedImage(ctx context.Context, hash string) (*api.ImageProvenanceResponse, error) {
+	var out api.ImageProvenanceResponse
+	if err := c.do(ctx, http.MethodGet, "/api/generated-images/"+url.PathEscape(hash), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetGeneratedImageContent calls GET /api/generated-images/{hash}/content: Download a generated image
+func (c *Client) GetGeneratedImageContent(ctx context.Context, hash string) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/api/generated-images/"+url.PathEscape(hash)+"/content", nil, nil, &out)
+	return out, err
+}
+
+// InspectImage calls POST /api/inspect-image: Read and check the signed provenance manifest in the metadata of a PNG or JPEG, and look up the block it names
+func (c *Client) InspectImage(ctx context.Context, form Form) (*api.InspectImageResponse, error) {
+	var out api.InspectImageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/inspect-image", nil, form, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Me calls GET /api/me: Describe the caller
+func (c *Client) Me(ctx context.Context) (*api.MeResponse, error) {
+	var out api.MeResponse
+	if err := c.do(ctx, http.MethodGet, "/api/me", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetSigningKey calls PUT /api/me/signing-key: Register or replace the caller's public key for dataset claims; the new key, and the current one if any, sign the caller's challenge
+func (c *Client) SetSigningKey(ctx context.Context, body api.SigningKeyRequest) (*api.SigningKeyResponse, error) {
+	var out api.SigningKeyResponse
+	if err := c.do(ctx, http.MethodPut, "/api/me/signing-key", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SigningKeyChallenge calls GET /api/me/signing-key/challenge: Issue the challenge the caller's next signing key change must sign
+func (c *Client) SigningKeyChallenge(ctx context.Context) (*api.SigningKeyChallenge, error) {
+	var out api.SigningKeyChallenge
+	if err := c.do(ctx, http.MethodGet, "/api/me/signing-key/challenge", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MineBlock calls POST /api/mine: Train a model on the uploaded images and mine a block recording them
+func (c *Client) MineBlock(ctx context.Context, form Form) (*api.Block, error) {
+	var out api.Block
+	if err := c.do(ctx, http.MethodPost, "/api/mine", nil, form, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetModel calls POST /api/model: Return a model and up to four of its training images
+func (c *Client) GetModel(ctx context.Context, body api.ModelRequest) (*api.ModelResponse, error) {
+	var out api.ModelResponse
+	if err := c.do(ctx, http.MethodPost, "/api/model", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListGeneratedImagesParams holds the query parameters of ListGeneratedImages
+type ListGeneratedImagesParams struct {
+	ModelName    string // Model to list
+	Username     string // Owner of a personal model
+	Organization string // Owner of an organisation model
+	Before       int    // Only images with a smaller ID, for paging
+	Limit        int    // At most this many images (default 20, max 100)
+}
+
+func (p ListGeneratedImagesParams) values() url.Values {
+	q := url.Values{}
+	if p.ModelName != "" {
+		q.Set("model_name", p.ModelName)
+	}
+	if p.Username != "" {
+		q.Set("username", p.Username)
+	}
+	if p.Organization != "" {
+		q.Set("organization", p.Organization)
+	}
+	if p.Before != 0 {
+		q.Set("before", strconv.Itoa(p.Before))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// ListGeneratedImages calls GET /api/model/images: List the images generated from a model, newest first
+func (c *Client) ListGeneratedImages(ctx context.Context, params ListGeneratedImagesParams) (*api.GalleryResponse, error) {
+	var out api.GalleryResponse
+	if err := c.do(ctx, http.MethodGet, "/api/model/images", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetLicenceParams holds the query parameters of GetLicence
+type GetLicenceParams struct {
+	ModelName    string // Model to look up
+	Username     string // Owner of a personal model
+	Organization string // Owner of an organisation model
+}
+
+func (p GetLicenceParams) values() url.Values {
+	q := url.Values{}
+	if p.ModelName != "" {
+		q.Set("model_name", p.ModelName)
+	}
+	if p.Username != "" {
+		q.Set("username", p.Username)
+	}
+	if p.Organization != "" {
+		q.Set("organization", p.Organization)
+	}
+	return q
+}
+
+// GetLicence calls GET /api/model/licence: Get the licence of a model
+func (c *Client) GetLicence(ctx context.Context, params GetLicenceParams) (*api.LicenceResponse, error) {
+	var out api.LicenceResponse
+	if err := c.do(ctx, http.MethodGet, "/api/model/licence", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetLicence calls PUT /api/model/licence: Set the licence of a model; it takes effect in the next block
+func (c *Client) SetLicence(ctx context.Context, body api.LicenceRequest) (*api.LicenceChangeResponse, error) {
+	var out api.LicenceChangeResponse
+	if err := c.do(ctx, http.MethodPut, "/api/model/licence", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetModelUsageParams holds the query parameters of GetModelUsage
+type GetModelUsageParams struct {
+	ModelName    string // A model of the caller
+	Organization string // Organisation owning the model, which the caller administers
+}
+
+func (p GetModelUsageParams) values() url.Values {
+	q := url.Values{}
+	if p.ModelName != "" {
+		q.Set("model_name", p.ModelName)
+	}
+	if p.Organization != "" {
+		q.Set("organization", p.Organization)
+	}
+	return q
+}
+
+// GetModelUsage calls GET /api/model/usage: Count the images generated from a model of the caller, by user and licence
+func (c *Client) GetModelUsage(ctx context.Context, params GetModelUsageParams) (*api.ModelUsageResponse, error) {
+	var out api.ModelUsageResponse
+	if err := c.do(ctx, http.MethodGet, "/api/model/usage", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListOrganizations calls GET /api/orgs: List the caller's organisations with their role
+func (c *Client) ListOrganizations(ctx context.Context) (*api.OrganizationsResponse, error) {
+	var out api.OrganizationsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/orgs", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateOrganization calls POST /api/orgs: Create an organisation owned by the caller
+func (c *Client) CreateOrganization(ctx context.Context, body api.CreateOrganizationRequest) (*api.Organization, error) {
+	var out api.Organization
+	if err := c.do(ctx, http.MethodPost, "/api/orgs", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListMembers calls GET /api/orgs/{org}/members: List an organisation's members
+func (c *Client) ListMembers(ctx context.Context, org string) (*api.MembersResponse, error) {
+	var out api.MembersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/orgs/"+url.PathEscape(org)+"/members", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddMember calls POST /api/orgs/{org}/members: Add a member or change their role
+func (c *Client) AddMember(ctx context.Context, org string, body api.AddMemberRequest) (*api.Membership, error) {
+	var out api.Membership
+	if err := c.do(ctx, http.MethodPost, "/api/orgs/"+url.PathEscape(org)+"/members", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveMember calls DELETE /api/orgs/{org}/members/{username}: Remove a member
+func (c *Client) RemoveMember(ctx context.Context, org string, username string) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/orgs/"+url.PathEscape(org)+"/members/"+url.PathEscape(username), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPeers calls GET /api/peers: List peers with their health and replication lag
+func (c *Client) ListPeers(ctx context.Context) (*api.PeersResponse, error) {
+	var out api.PeersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/peers", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemovePeer calls DELETE /api/peers/{id}: Stop replicating with a peer
+func (c *Client) RemovePeer(ctx context.Context, id string) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/peers/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReceiveBlock calls POST /api/receive-block: Accept a block broadcast by a peer
+func (c *Client) ReceiveBlock(ctx context.Context, body api.Block) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/receive-block", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddTransaction calls POST /api/transaction: Queue a transaction for the next block
+func (c *Client) AddTransaction(ctx context.Context, body api.Transaction) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/transaction", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetBalance calls GET /api/users/{username}/balance: Get a user's credits from rewards and transfers
+func (c *Client) GetBalance(ctx context.Context, username string) (*api.BalanceResponse, error) {
+	var out api.BalanceResponse
+	if err := c.do(ctx, http.MethodGet, "/api/users/"+url.PathEscape(username)+"/balance", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSigningKey calls GET /api/users/{username}/signing-key: Get the key a user signs dataset claims with
+func (c *Client) GetSigningKey(ctx context.Context, username string) (*api.SigningKeyResponse, error) {
+	var out api.SigningKeyResponse
+	if err := c.do(ctx, http.MethodGet, "/api/users/"+url.PathEscape(username)+"/signing-key", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStatement calls GET /api/users/{username}/statement: List the credits and debits of a user on the chain
+func (c *Client) GetStatement(ctx context.Context, username string) (*api.StatementResponse, error) {
+	var out api.StatementResponse
+	if err := c.do(ctx, http.MethodGet, "/api/users/"+url.PathEscape(username)+"/statement", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetValidators calls GET /api/validators: Show the consensus mode and the current validator set
+func (c *Client) GetValidators(ctx context.Context) (*api.ValidatorsResponse, error) {
+	var out api.ValidatorsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/validators", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VerifyImage calls POST /api/verify-image: Read the watermark of a generated image, perhaps since recompressed or resized, and find the block it names
+func (c *Client) VerifyImage(ctx context.Context, form Form) (*api.VerifyImageResponse, error) {
+	var out api.VerifyImageResponse
+	if err := c.do(ctx, http.MethodPost, "/api/verify-image", nil, form, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Login calls POST /auth/login: Exchange credentials for a session token
+func (c *Client) Login(ctx context.Context, body api.LoginRequest) (*api.LoginResponse, error) {
+	var out api.LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/login", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Register calls POST /auth/register: Create a user account
+func (c *Client) Register(ctx context.Context, body api.RegisterRequest) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/register", nil, jsonBody(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Healthz calls GET /healthz: Liveness probe
+func (c *Client) Healthz(ctx context.Context) (*api.HealthResponse, error) {
+	var out api.HealthResponse
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Metrics calls GET /metrics: Prometheus metrics
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/metrics", nil, nil, &out)
+	return out, err
+}
+
+// ListModels calls GET /models: List every trained model
+func (c *Client) ListModels(ctx context.Context) (*api.ModelsResponse, error) {
+	var out api.ModelsResponse
+	if err := c.do(ctx, http.MethodGet, "/models", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OpenAPI calls GET /openapi.json: This document
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &out)
+	return out, err
+}
+
+// Readyz calls GET /readyz: Readiness probe
+func (c *Client) Readyz(ctx context.Context) (*api.ReadinessResponse, error) {
+	var out api.ReadinessResponse
+	if err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
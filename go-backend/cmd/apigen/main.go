@@ -0,0 +1,27 @@
+// Command apigen regenerates client/client_gen.go from api/openapi.json.
+// Run it through go generate in package client.
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/internal/apigen"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
+
+func main() {
+	out := flag.String("o", "client_gen.go", "Output file")
+	flag.Parse()
+
+	src, err := apigen.Generate(api.Spec)
+	if err != nil {
+		logging.Fatal("failed to generate client", "error", err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		logging.Fatal("failed to write client", "error", err)
+	}
+	slog.Info("generated client", "file", *out)
+}
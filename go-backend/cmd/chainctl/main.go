@@ -0,0 +1,156 @@
+// Command chainctl archives the provenance ledger of a node and checks
+// archives offline.
+//
+//	chainctl export [-format jsonl|cbor] [-o FILE] [-config FILE]
+//	chainctl import [-config FILE] BUNDLE
+//	chainctl verify BUNDLE
+//
+// export and import work on the node's data directory and database, with
+// the same configuration as the node. The chain is read from the state the
+// node saves on shutdown, so stop the node first. verify needs neither.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/bundle"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+)
+
+const usage = `usage: chainctl <export|import|verify> [flags]
+
+  export [-format jsonl|cbor] [-o FILE] [-config FILE]
+          write the chain and models of a stopped node as a bundle
+  import [-config FILE] BUNDLE
+          load a verified bundle into an empty node
+  verify BUNDLE
+          check a bundle's manifest, block hashes, links and models`
+
+func main() {
+	logging.Setup(os.Stderr, slog.LevelInfo)
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	fs := flag.NewFlagSet("chainctl "+command, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Path to the node's YAML or TOML config file")
+	switch command {
+	case "export":
+		format := fs.String("format", string(bundle.FormatJSONL), "Bundle format: jsonl or cbor")
+		out := fs.String("o", "", "Output file, standard output if empty")
+		fs.Parse(args)
+		runExport(openNode(*configFile), bundle.Format(*format), *out)
+	case "import":
+		fs.Parse(args)
+		b := readVerified(bundleArg(fs))
+		runImport(openNode(*configFile), b)
+	case "verify":
+		fs.Parse(args)
+		b := readVerified(bundleArg(fs))
+		fmt.Printf("ok: %d blocks, %d models, tip %s\n", len(b.Blocks), len(b.Models), b.Manifest.TipHash)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func bundleArg(fs *flag.FlagSet) string {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Arg(0)
+}
+
+// openNode loads the node's configuration, database and saved state. Like
+// the node itself it checks chains against the configured consensus and
+// rewards policy, so it imports no chain the node would refuse to start on.
+func openNode(configFile string) *handlers.Handler {
+	var args []string
+	if configFile != "" {
+		args = []string{"-config", configFile}
+	}
+	cfg, err := config.Load(args)
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		logging.Fatal("failed to open database", "error", err)
+	}
+
+	node := handlers.New(database.NewRepository(db), nil, cfg.DataDir)
+	authority, err := consensus.FromConfig(cfg.Consensus)
+	if err != nil {
+		logging.Fatal("invalid consensus configuration", "error", err)
+	}
+	if authority != nil {
+		node.UseAuthority(authority)
+	}
+	node.UseRewards(rewards.FromConfig(cfg.Rewards))
+	if err := node.LoadState(); err != nil {
+		logging.Fatal("failed to read node state", "error", err)
+	}
+	return node
+}
+
+func runExport(node *handlers.Handler, format bundle.Format, out string) {
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			logging.Fatal("failed to create bundle", "error", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	manifest, err := node.ExportBundle(w, format)
+	if err != nil {
+		logging.Fatal("export failed", "error", err)
+	}
+	slog.Info("exported chain", "blocks", manifest.Blocks, "models", manifest.Models,
+		"tip", manifest.TipHash, "records_sha256", manifest.RecordsSHA256)
+}
+
+func runImport(node *handlers.Handler, b *bundle.Bundle) {
+	if err := node.ImportBundle(b); err != nil {
+		logging.Fatal("import failed", "error", err)
+	}
+	slog.Info("imported chain", "blocks", len(b.Blocks), "models", len(b.Models), "tip", b.Manifest.TipHash)
+}
+
+// readVerified reads a bundle and exits listing every problem if it does
+// not verify
+func readVerified(path string) *bundle.Bundle {
+	f, err := os.Open(path)
+	if err != nil {
+		logging.Fatal("failed to open bundle", "error", err)
+	}
+	defer f.Close()
+
+	b, err := bundle.Read(f)
+	if err != nil {
+		logging.Fatal("failed to read bundle", "error", err)
+	}
+	if problems := bundle.Verify(b); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		logging.Fatal("bundle does not verify", "problems", len(problems))
+	}
+	return b
+}
@@ -1,13 +1,513 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
 	"github.com/joho/godotenv"
-	"log"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultJWTSecret is only acceptable outside production
+const DefaultJWTSecret = "default_secret_key"
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite". SQLite suits tests and single-node
+	// installs; DSN is then a file path or ":memory:".
+	Driver   string `yaml:"driver" toml:"driver"`
+	DSN      string `yaml:"dsn" toml:"dsn"` // overrides the connection fields below when set
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+}
+
+// ConnString returns the DSN if set, otherwise builds one for the driver
+func (d DatabaseConfig) ConnString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	if d.Driver == DriverSQLite {
+		return "go-backend.db"
+	}
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		d.Host, d.User, d.Password, d.Name, d.Port, d.SSLMode,
+	)
+}
+
+// Consensus modes
+const (
+	ConsensusOpen = "open" // any node may mine and any valid block is accepted
+	ConsensusPoA  = "poa"  // validators take turns, see package consensus
 )
 
+type ConsensusConfig struct {
+	Mode string `yaml:"mode" toml:"mode"`
+	// Validators are the hex ed25519 public keys of the genesis validator
+	// set, in slot order. Later changes are made on-chain.
+	Validators []string `yaml:"validators" toml:"validators"`
+	// ValidatorKeyFile holds this node's key, written by "go-backend
+	// keygen". Nodes without one follow the chain but produce no blocks.
+	ValidatorKeyFile string   `yaml:"validator_key_file" toml:"validator_key_file"`
+	SlotDuration     Duration `yaml:"slot_duration" toml:"slot_duration"`
+}
+
+// Reward policies, how a reward is split between the owners of the images
+// it pays for
+const (
+	RewardPerImage = "per_image" // in proportion to the images each owns
+	RewardEqual    = "equal"     // evenly between the owners
+)
+
+type RewardsConfig struct {
+	Policy string `yaml:"policy" toml:"policy"`
+	// Train is credited for each training run and Generate for each image
+	// generated from a model, split between the owners of the training
+	// images. Zero disables that reward.
+	Train    float64 `yaml:"train" toml:"train"`
+	Generate float64 `yaml:"generate" toml:"generate"`
+}
+
+type AnchorConfig struct {
+	// Node is the host:port of a mining sample_blockchain node that records
+	// the anchors; empty disables anchoring
+	Node string `yaml:"node" toml:"node"`
+	// Interval is how often the blocks added since the last anchor are
+	// anchored
+	Interval Duration `yaml:"interval" toml:"interval"`
+}
+
+// RateLimit is a token bucket refilled at Rate requests a second, holding
+// up to Burst. A zero Rate or Burst disables it.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+// GroupLimits are the rate limits of one route group, per client IP and
+// per logged-in user
+type GroupLimits struct {
+	PerIP   RateLimit `yaml:"per_ip" toml:"per_ip"`
+	PerUser RateLimit `yaml:"per_user" toml:"per_user"`
+}
+
+// RateLimitsConfig holds the limits of each route group. Auth has no user
+// yet and Peer is node-to-node traffic, so only their PerIP applies.
+type RateLimitsConfig struct {
+	Auth        GroupLimits `yaml:"auth" toml:"auth"`
+	API         GroupLimits `yaml:"api" toml:"api"`
+	Mine        GroupLimits `yaml:"mine" toml:"mine"`
+	CheckImage  GroupLimits `yaml:"check_image" toml:"check_image"` // check-, verify- and inspect-image
+	VerifyProof GroupLimits `yaml:"verify_proof" toml:"verify_proof"`
+	Peer        GroupLimits `yaml:"peer" toml:"peer"`
+}
+
+// Duration is a time.Duration written as "30s" or "1m" in config files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config holds every setting of the backend. Values are resolved in order
+// defaults < config file < environment < command-line flags.
+type Config struct {
+	Env          string   `yaml:"env" toml:"env"`
+	Port         string   `yaml:"port" toml:"port"`
+	JWTSecret    string   `yaml:"jwt_secret" toml:"jwt_secret"`
+	AIServiceURL string   `yaml:"ai_service_url" toml:"ai_service_url"`
+	DataDir      string   `yaml:"data_dir" toml:"data_dir"`       // uploaded training images
+	LogLevel     string   `yaml:"log_level" toml:"log_level"`     // debug, info, warn or error
+	AdminUsers   []string `yaml:"admin_users" toml:"admin_users"` // may read the audit trail
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies whose
+	// X-Forwarded-For header gives the client IP. Empty trusts none, so
+	// rate limits, lockouts and the audit trail use the peer address.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	// NodeKeyFile holds the key that signs the provenance manifests of
+	// generated images, written by "go-backend keygen". Empty keeps one in
+	// DataDir, created on first start.
+	NodeKeyFile string `yaml:"node_key_file" toml:"node_key_file"`
+	// TrustedNodeKeys are the hex ed25519 public keys of the other nodes
+	// whose provenance manifests this node accepts. Its own key and those
+	// of the validators are always trusted.
+	TrustedNodeKeys []string `yaml:"trusted_node_keys" toml:"trusted_node_keys"`
+	// ShutdownTimeout bounds how long in-flight requests and block
+	// broadcasts may drain on SIGINT/SIGTERM
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// BootstrapPeers are added on every start and never evicted. Other
+	// peers are evicted after PeerMaxFailures failed health checks in a row.
+	BootstrapPeers    []string         `yaml:"bootstrap_peers" toml:"bootstrap_peers"`
+	PeerCheckInterval Duration         `yaml:"peer_check_interval" toml:"peer_check_interval"`
+	PeerMaxFailures   int              `yaml:"peer_max_failures" toml:"peer_max_failures"`
+	Database          DatabaseConfig   `yaml:"database" toml:"database"`
+	Consensus         ConsensusConfig  `yaml:"consensus" toml:"consensus"`
+	Anchor            AnchorConfig     `yaml:"anchor" toml:"anchor"`
+	Rewards           RewardsConfig    `yaml:"rewards" toml:"rewards"`
+	RateLimits        RateLimitsConfig `yaml:"rate_limits" toml:"rate_limits"`
+}
+
+// Default returns the development defaults
+func Default() Config {
+	return Config{
+		Env:               EnvDevelopment,
+		Port:              "8080",
+		AIServiceURL:      "http://localhost:5000",
+		DataDir:           "./user_data",
+		LogLevel:          "info",
+		ShutdownTimeout:   Duration(30 * time.Second),
+		PeerCheckInterval: Duration(30 * time.Second),
+		PeerMaxFailures:   5,
+		Database: DatabaseConfig{
+			Driver:   DriverPostgres,
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "postgres",
+			Password: "password",
+			Name:     "ai_blockchain",
+			SSLMode:  "disable",
+		},
+		Consensus: ConsensusConfig{
+			Mode:         ConsensusOpen,
+			SlotDuration: Duration(5 * time.Second),
+		},
+		Anchor: AnchorConfig{
+			Interval: Duration(10 * time.Minute),
+		},
+		Rewards: RewardsConfig{
+			Policy: RewardPerImage,
+		},
+		// Auth is strict because every login costs a bcrypt comparison;
+		// mine and the image checks are expensive because of uploads and,
+		// for mine, a full training run; verify-proof loads a model in the
+		// AI service.
+		RateLimits: RateLimitsConfig{
+			Auth: GroupLimits{
+				PerIP: RateLimit{Rate: 5.0 / 60, Burst: 10},
+			},
+			API: GroupLimits{
+				PerIP:   RateLimit{Rate: 20, Burst: 40},
+				PerUser: RateLimit{Rate: 10, Burst: 20},
+			},
+			Mine: GroupLimits{
+				PerIP:   RateLimit{Rate: 1.0 / 60, Burst: 3},
+				PerUser: RateLimit{Rate: 1.0 / 60, Burst: 3},
+			},
+			CheckImage: GroupLimits{
+				PerIP:   RateLimit{Rate: 0.5, Burst: 10},
+				PerUser: RateLimit{Rate: 0.5, Burst: 10},
+			},
+			VerifyProof: GroupLimits{
+				PerIP:   RateLimit{Rate: 0.2, Burst: 5},
+				PerUser: RateLimit{Rate: 0.2, Burst: 5},
+			},
+			Peer: GroupLimits{
+				PerIP: RateLimit{Rate: 5, Burst: 20},
+			},
+		},
+	}
+}
+
+// LoadEnv loads a .env file into the process environment if there is one
 func LoadEnv() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("⚠️  No .env file found, using system environment variables")
+		slog.Info("no .env file found, using system environment variables")
+	}
+}
+
+// Load builds the configuration from defaults, the optional config file
+// (-config flag or CONFIG_FILE), the environment and args, then validates it.
+func Load(args []string) (*Config, error) {
+	LoadEnv()
+
+	fs := flag.NewFlagSet("go-backend", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Path to a YAML or TOML config file")
+	port := fs.String("port", "", "Port to run the server on")
+	env := fs.String("env", "", "Environment: development or production")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	applyEnv(&cfg)
+
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if *env != "" {
+		cfg.Env = *env
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) {
+	setFromEnv(&cfg.Env, "APP_ENV")
+	setFromEnv(&cfg.Port, "PORT")
+	setFromEnv(&cfg.JWTSecret, "JWT_SECRET")
+	setFromEnv(&cfg.AIServiceURL, "AI_SERVICE_URL")
+	setFromEnv(&cfg.DataDir, "DATA_DIR")
+	setFromEnv(&cfg.LogLevel, "LOG_LEVEL")
+	setFromEnv(&cfg.NodeKeyFile, "NODE_KEY_FILE")
+	if keys := os.Getenv("TRUSTED_NODE_KEYS"); keys != "" {
+		cfg.TrustedNodeKeys = strings.Split(keys, ",")
+	}
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		// An invalid value leaves zero, which Validate reports
+		cfg.ShutdownTimeout = 0
+		cfg.ShutdownTimeout.UnmarshalText([]byte(timeout))
+	}
+	if admins := os.Getenv("ADMIN_USERS"); admins != "" {
+		cfg.AdminUsers = strings.Split(admins, ",")
+	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.TrustedProxies = strings.Split(proxies, ",")
+	}
+	if peers := os.Getenv("BOOTSTRAP_PEERS"); peers != "" {
+		cfg.BootstrapPeers = strings.Split(peers, ",")
+	}
+	if interval := os.Getenv("PEER_CHECK_INTERVAL"); interval != "" {
+		cfg.PeerCheckInterval = 0
+		cfg.PeerCheckInterval.UnmarshalText([]byte(interval))
+	}
+	if failures := os.Getenv("PEER_MAX_FAILURES"); failures != "" {
+		// An invalid value leaves zero, which Validate reports
+		cfg.PeerMaxFailures, _ = strconv.Atoi(failures)
+	}
+	setFromEnv(&cfg.Database.Driver, "DB_DRIVER")
+	setFromEnv(&cfg.Database.DSN, "DB_DSN")
+	setFromEnv(&cfg.Database.Host, "DB_HOST")
+	setFromEnv(&cfg.Database.Port, "DB_PORT")
+	setFromEnv(&cfg.Database.User, "DB_USER")
+	setFromEnv(&cfg.Database.Password, "DB_PASSWORD")
+	setFromEnv(&cfg.Database.Name, "DB_NAME")
+	setFromEnv(&cfg.Database.SSLMode, "DB_SSLMODE")
+	setFromEnv(&cfg.Consensus.Mode, "CONSENSUS_MODE")
+	if validators := os.Getenv("CONSENSUS_VALIDATORS"); validators != "" {
+		cfg.Consensus.Validators = strings.Split(validators, ",")
+	}
+	setFromEnv(&cfg.Consensus.ValidatorKeyFile, "VALIDATOR_KEY_FILE")
+	if slot := os.Getenv("SLOT_DURATION"); slot != "" {
+		cfg.Consensus.SlotDuration = 0
+		cfg.Consensus.SlotDuration.UnmarshalText([]byte(slot))
+	}
+	setFromEnv(&cfg.Rewards.Policy, "REWARD_POLICY")
+	if train := os.Getenv("REWARD_TRAIN"); train != "" {
+		// An invalid value leaves -1, which Validate reports
+		cfg.Rewards.Train = parseAmount(train)
+	}
+	if generate := os.Getenv("REWARD_GENERATE"); generate != "" {
+		cfg.Rewards.Generate = parseAmount(generate)
+	}
+	setFromEnv(&cfg.Anchor.Node, "ANCHOR_NODE")
+	if interval := os.Getenv("ANCHOR_INTERVAL"); interval != "" {
+		cfg.Anchor.Interval = 0
+		cfg.Anchor.Interval.UnmarshalText([]byte(interval))
+	}
+}
+
+// parseAmount reads a reward amount, -1 if it is not a number
+func parseAmount(s string) float64 {
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return -1
+	}
+	return amount
+}
+
+func setFromEnv(dst *string, key string) {
+	if val := os.Getenv(key); val != "" {
+		*dst = val
+	}
+}
+
+// Validate checks the configuration and fills in the development JWT secret.
+// It refuses to run production with the default or an empty secret.
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction:
+	default:
+		errs = append(errs, fmt.Errorf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+
+	if c.JWTSecret == "" || c.JWTSecret == DefaultJWTSecret {
+		if c.Env == EnvProduction {
+			errs = append(errs, errors.New("JWT_SECRET must be set to a non-default value in production"))
+		} else {
+			slog.Warn("JWT_SECRET not set, using the development default")
+			c.JWTSecret = DefaultJWTSecret
+		}
+	}
+
+	if c.Port == "" {
+		errs = append(errs, errors.New("port is required"))
+	}
+	if c.AIServiceURL == "" {
+		errs = append(errs, errors.New("ai_service_url is required"))
+	}
+	c.AIServiceURL = strings.TrimRight(c.AIServiceURL, "/")
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data_dir is required"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be a positive duration such as \"30s\""))
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+	for i, admin := range c.AdminUsers {
+		c.AdminUsers[i] = strings.TrimSpace(admin)
+	}
+	for i, proxy := range c.TrustedProxies {
+		c.TrustedProxies[i] = strings.TrimSpace(proxy)
+		_, _, cidrErr := net.ParseCIDR(c.TrustedProxies[i])
+		if cidrErr != nil && net.ParseIP(c.TrustedProxies[i]) == nil {
+			errs = append(errs, fmt.Errorf("trusted_proxies: %q is not an IP or CIDR", proxy))
+		}
+	}
+	for i, peer := range c.BootstrapPeers {
+		normalized, err := blockchain.NormalizePeer(peer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap_peers: %q: %w", peer, err))
+			continue
+		}
+		c.BootstrapPeers[i] = normalized
+	}
+	if c.PeerCheckInterval <= 0 {
+		errs = append(errs, errors.New("peer_check_interval must be a positive duration such as \"30s\""))
+	}
+	if c.PeerMaxFailures < 1 {
+		errs = append(errs, errors.New("peer_max_failures must be at least 1"))
+	}
+
+	switch c.Database.Driver {
+	case DriverPostgres:
+		if c.Database.DSN == "" && (c.Database.Host == "" || c.Database.Name == "") {
+			errs = append(errs, errors.New("database host and name are required"))
+		}
+	case DriverSQLite:
+	default:
+		errs = append(errs, fmt.Errorf("database driver must be %q or %q, got %q", DriverPostgres, DriverSQLite, c.Database.Driver))
+	}
+
+	switch c.Consensus.Mode {
+	case ConsensusOpen:
+	case ConsensusPoA:
+		if len(c.Consensus.Validators) == 0 {
+			errs = append(errs, errors.New("consensus: poa mode needs at least one validator"))
+		}
+		for i, validator := range c.Consensus.Validators {
+			c.Consensus.Validators[i] = strings.ToLower(strings.TrimSpace(validator))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("consensus mode must be %q or %q, got %q", ConsensusOpen, ConsensusPoA, c.Consensus.Mode))
+	}
+	for i, key := range c.TrustedNodeKeys {
+		c.TrustedNodeKeys[i] = strings.ToLower(strings.TrimSpace(key))
+		if raw, err := hex.DecodeString(c.TrustedNodeKeys[i]); err != nil || len(raw) != ed25519.PublicKeySize {
+			errs = append(errs, fmt.Errorf("trusted_node_keys: %q is not a hex ed25519 public key", key))
+		}
+	}
+	if c.Consensus.SlotDuration < Duration(time.Millisecond) {
+		errs = append(errs, errors.New("consensus slot_duration must be a positive duration such as \"5s\""))
+	}
+	switch c.Rewards.Policy {
+	case RewardPerImage, RewardEqual:
+	default:
+		errs = append(errs, fmt.Errorf("rewards policy must be %q or %q, got %q", RewardPerImage, RewardEqual, c.Rewards.Policy))
+	}
+	if c.Rewards.Train < 0 || c.Rewards.Generate < 0 {
+		errs = append(errs, errors.New("rewards train and generate must be non-negative amounts"))
+	}
+	for name, group := range map[string]GroupLimits{
+		"auth": c.RateLimits.Auth, "api": c.RateLimits.API, "mine": c.RateLimits.Mine,
+		"check_image": c.RateLimits.CheckImage, "verify_proof": c.RateLimits.VerifyProof, "peer": c.RateLimits.Peer,
+	} {
+		for _, l := range []RateLimit{group.PerIP, group.PerUser} {
+			if l.Rate < 0 || l.Burst < 0 {
+				errs = append(errs, fmt.Errorf("rate_limits %s: rate and burst must not be negative", name))
+				break
+			}
+		}
+	}
+	if c.Anchor.Node != "" && c.Anchor.Interval <= 0 {
+		errs = append(errs, errors.New("anchor interval must be a positive duration such as \"10m\""))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel parses LogLevel
+func (c *Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return level, fmt.Errorf("log_level: %w", err)
 	}
+	return level, nil
 }
@@ -0,0 +1,355 @@
+// Package consensus implements proof-of-authority. A validator set, fixed
+// for the genesis block in the config and changed by transactions on the
+// chain, takes turns producing blocks: time is cut into slots and slot n
+// belongs to validator n mod len(set). Every block names its validator, is
+// signed by it and falls in one of its slots, after its parent's slot. A
+// change to the set is a proposal until a majority of the set has made it
+// in blocks of their own.
+package consensus
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+)
+
+// MaxClockDrift is how far ahead of our clock a block may be dated
+const MaxClockDrift = time.Second
+
+var (
+	ErrNotValidator    = errors.New("this node is not a validator")
+	ErrNotScheduled    = errors.New("block is not in a slot of its validator")
+	ErrUnknownSigner   = errors.New("block is sealed by a key outside the validator set")
+	ErrBadSignature    = errors.New("block signature does not verify")
+	ErrInvalidChange   = errors.New("invalid validator set change")
+	ErrUnsignedVersion = errors.New("block version predates validators")
+)
+
+// Authority checks blocks against the proof-of-authority rules and, on a
+// validator, seals the blocks the node produces. It is safe for concurrent
+// use.
+type Authority struct {
+	genesis      []string // validator set of the genesis block
+	slotDuration time.Duration
+	key          ed25519.PrivateKey // nil on nodes that only check blocks
+	self         string             // hex public key of key
+
+	mu      sync.Mutex
+	pending []blockchain.Transaction // changes proposed through this node
+}
+
+// New returns an authority for the genesis validator set, given as hex
+// public keys. key is this node's validator key, or nil.
+func New(genesis []string, slotDuration time.Duration, key ed25519.PrivateKey) (*Authority, error) {
+	if len(genesis) == 0 {
+		return nil, errors.New("the validator set is empty")
+	}
+	if slotDuration < time.Millisecond {
+		return nil, errors.New("slots must last at least a millisecond")
+	}
+	set := make([]string, len(genesis))
+	for i, validator := range genesis {
+		var err error
+		if set[i], err = NormalizeKey(validator); err != nil {
+			return nil, err
+		}
+		if slices.Contains(set[:i], set[i]) {
+			return nil, fmt.Errorf("validator %s is listed twice", validator)
+		}
+	}
+
+	a := &Authority{genesis: set, slotDuration: slotDuration, key: key}
+	if key != nil {
+		a.self = PublicKeyHex(key)
+	}
+	return a, nil
+}
+
+// FromConfig returns the authority of cfg, or nil when cfg is not in
+// proof-of-authority mode
+func FromConfig(cfg config.ConsensusConfig) (*Authority, error) {
+	if cfg.Mode != config.ConsensusPoA {
+		return nil, nil
+	}
+	var key ed25519.PrivateKey
+	if cfg.ValidatorKeyFile != "" {
+		var err error
+		if key, err = LoadKey(cfg.ValidatorKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	return New(cfg.Validators, time.Duration(cfg.SlotDuration), key)
+}
+
+// Genesis returns the validator set of the genesis block
+func (a *Authority) Genesis() []string {
+	return slices.Clone(a.genesis)
+}
+
+// Self is this node's validator public key, "" if it has none
+func (a *Authority) Self() string {
+	return a.self
+}
+
+func (a *Authority) SlotDuration() time.Duration {
+	return a.slotDuration
+}
+
+// Slot is the number of the slot t falls in, counted from the Unix epoch
+func (a *Authority) Slot(t time.Time) int64 {
+	return t.UnixMicro() / a.slotDuration.Microseconds()
+}
+
+func (a *Authority) slotStart(slot int64) time.Time {
+	return time.UnixMicro(slot * a.slotDuration.Microseconds())
+}
+
+// Validators returns the validator set in effect after chain, which must
+// have been checked already
+func (a *Authority) Validators(chain []blockchain.Block) []string {
+	return a.replay(chain).Validators
+}
+
+// Proposals returns the changes proposed on chain that still lack a
+// majority, ordered by action and key
+func (a *Authority) Proposals(chain []blockchain.Block) []Proposal {
+	return a.replay(chain).Proposals()
+}
+
+// replay returns the set after chain, which must have been checked already
+func (a *Authority) replay(chain []blockchain.Block) Set {
+	set := NewSet(a.genesis)
+	for _, block := range chain {
+		if next, err := ApplyChanges(set, block); err == nil {
+			set = next
+		}
+	}
+	return set
+}
+
+// Scheduled returns the validator owning slot in set
+func Scheduled(set []string, slot int64) string {
+	return set[slot%int64(len(set))]
+}
+
+// CheckBlock implements blockchain.Consensus
+func (a *Authority) CheckBlock(chain []blockchain.Block, block blockchain.Block) error {
+	return a.check(a.replay(chain), chain[len(chain)-1], block)
+}
+
+// CheckChain implements blockchain.Consensus
+func (a *Authority) CheckChain(chain []blockchain.Block) error {
+	set := NewSet(a.genesis)
+	for i := 1; i < len(chain); i++ {
+		if err := a.check(set, chain[i-1], chain[i]); err != nil {
+			return fmt.Errorf("block %d: %w", chain[i].Index, err)
+		}
+		set, _ = ApplyChanges(set, chain[i])
+	}
+	return nil
+}
+
+// check applies the rules to block following parent, with set the validator
+// set in effect after parent
+func (a *Authority) check(set Set, parent, block blockchain.Block) error {
+	if block.Version < blockchain.VersionValidator {
+		return fmt.Errorf("%w: version %d", ErrUnsignedVersion, block.Version)
+	}
+
+	// The genesis block is dated when each node starts, so only later
+	// parents order slots
+	slot := a.Slot(block.Timestamp)
+	if parent.Index > 0 && slot <= a.Slot(parent.Timestamp) {
+		return fmt.Errorf("%w: slot %d does not follow its parent's slot", ErrNotScheduled, slot)
+	}
+	if block.Timestamp.After(time.Now().Add(MaxClockDrift)) {
+		return fmt.Errorf("%w: slot %d has not started", ErrNotScheduled, slot)
+	}
+	if want := Scheduled(set.Validators, slot); block.Validator != want {
+		return fmt.Errorf("%w: slot %d belongs to %s, not %q", ErrNotScheduled, slot, want, block.Validator)
+	}
+	if err := CheckSeal(set.Validators, block); err != nil {
+		return err
+	}
+
+	_, err := ApplyChanges(set, block)
+	return err
+}
+
+// CheckSeal checks that block is signed over its hash by its validator, a
+// member of set. Unlike CheckBlock it needs no slot duration, so it leaves
+// out the schedule.
+func CheckSeal(set []string, block blockchain.Block) error {
+	if block.Version < blockchain.VersionValidator {
+		return fmt.Errorf("%w: version %d", ErrUnsignedVersion, block.Version)
+	}
+	if !slices.Contains(set, block.Validator) {
+		return fmt.Errorf("%w: %q", ErrUnknownSigner, block.Validator)
+	}
+
+	key, err := ParsePublicKey(block.Validator)
+	if err != nil {
+		return err
+	}
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil {
+		return ErrBadSignature
+	}
+	sig, err := hex.DecodeString(block.Signature)
+	if err != nil || !ed25519.Verify(key, hash, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// ApplyChanges returns set after the validator set changes of block. Each
+// is a proposal of the block's validator; a change applies once a majority
+// of the validators in the set has proposed it, and no validator may
+// propose one twice. Keys are compared as NormalizeKey writes them.
+func ApplyChanges(set Set, block blockchain.Block) (Set, error) {
+	next := set.clone()
+	for _, tx := range block.Transactions {
+		if !tx.IsValidatorChange() {
+			if tx.ValidatorKey != "" {
+				return Set{}, fmt.Errorf("%w: validator key on a transaction to %q", ErrInvalidChange, tx.Receiver)
+			}
+			continue
+		}
+		if tx.Sender != block.Validator {
+			return Set{}, fmt.Errorf("%w: proposed by %q, not by the block's validator", ErrInvalidChange, tx.Sender)
+		}
+		key, err := NormalizeKey(tx.ValidatorKey)
+		if err != nil {
+			return Set{}, fmt.Errorf("%w: %w", ErrInvalidChange, err)
+		}
+
+		i := slices.Index(next.Validators, key)
+		switch {
+		case tx.Receiver == blockchain.ReceiverAddValidator && i >= 0:
+			return Set{}, fmt.Errorf("%w: %s is already a validator", ErrInvalidChange, key)
+		case tx.Receiver == blockchain.ReceiverRemoveValidator && i < 0:
+			return Set{}, fmt.Errorf("%w: %s is not a validator", ErrInvalidChange, key)
+		}
+
+		c := change{receiver: tx.Receiver, key: key}
+		proposers := next.proposals[c]
+		if slices.Contains(proposers, tx.Sender) {
+			return Set{}, fmt.Errorf("%w: %s already proposed this change", ErrInvalidChange, tx.Sender)
+		}
+		proposers = append(slices.Clone(proposers), tx.Sender)
+		if !next.majority(proposers) {
+			next.proposals[c] = proposers
+			continue
+		}
+
+		delete(next.proposals, c)
+		if tx.Receiver == blockchain.ReceiverAddValidator {
+			next.Validators = append(next.Validators, key)
+		} else {
+			next.Validators = slices.Delete(next.Validators, i, i+1)
+		}
+	}
+	if len(next.Validators) == 0 {
+		return Set{}, fmt.Errorf("%w: the validator set would be empty", ErrInvalidChange)
+	}
+	return next, nil
+}
+
+// NextSlot returns when the first slot of this node at or after after
+// starts, skipping slots up to the tip's. The start may be in the past if
+// that slot is still running.
+func (a *Authority) NextSlot(chain []blockchain.Block, after time.Time) (time.Time, error) {
+	set := a.Validators(chain)
+	i := slices.Index(set, a.self)
+	if a.key == nil || i < 0 {
+		return time.Time{}, ErrNotValidator
+	}
+
+	slot := a.Slot(after)
+	if tip := chain[len(chain)-1]; tip.Index > 0 {
+		slot = max(slot, a.Slot(tip.Timestamp)+1)
+	}
+	n := int64(len(set))
+	slot += (int64(i) - slot%n + n) % n
+	return a.slotStart(slot), nil
+}
+
+// Seal makes block one of this node's: it names the validator, rehashes
+// the block and signs the hash
+func (a *Authority) Seal(block *blockchain.Block) error {
+	if a.key == nil {
+		return ErrNotValidator
+	}
+	block.Validator = a.self
+	block.Hash = blockchain.CalculateHash(*block)
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil {
+		return err
+	}
+	block.Signature = hex.EncodeToString(ed25519.Sign(a.key, hash))
+	return nil
+}
+
+// Propose queues this node's proposal of a validator set change for the
+// next block it produces; the change applies once a majority of the set
+// proposed it. receiver is blockchain.ReceiverAddValidator or
+// ReceiverRemoveValidator; the proposal must apply to the set after chain
+// and the changes already queued.
+func (a *Authority) Propose(chain []blockchain.Block, receiver, validator string) (blockchain.Transaction, error) {
+	tx := blockchain.Transaction{Sender: a.self, Receiver: receiver, ValidatorKey: validator}
+	if !tx.IsValidatorChange() {
+		return tx, fmt.Errorf("%w: unknown action %q", ErrInvalidChange, receiver)
+	}
+	key, err := NormalizeKey(validator)
+	if err != nil {
+		return tx, fmt.Errorf("%w: %w", ErrInvalidChange, err)
+	}
+	tx.ValidatorKey = key
+	if a.key == nil || !slices.Contains(a.Validators(chain), a.self) {
+		return tx, ErrNotValidator
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	queued := blockchain.Block{Validator: a.self, Transactions: append(slices.Clone(a.pending), tx)}
+	if _, err := ApplyChanges(a.replay(chain), queued); err != nil {
+		return tx, err
+	}
+	a.pending = queued.Transactions
+	return tx, nil
+}
+
+// PendingChanges returns the queued changes that still apply after chain,
+// in order, and forgets those that no longer do
+func (a *Authority) PendingChanges(chain []blockchain.Block) []blockchain.Transaction {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	set := a.replay(chain)
+	var valid []blockchain.Transaction
+	for _, tx := range a.pending {
+		block := blockchain.Block{Validator: a.self, Transactions: []blockchain.Transaction{tx}}
+		if next, err := ApplyChanges(set, block); err == nil {
+			set = next
+			valid = append(valid, tx)
+		}
+	}
+	a.pending = valid
+	return slices.Clone(valid)
+}
+
+// Committed forgets queued changes that made it into a block
+func (a *Authority) Committed(txs []blockchain.Transaction) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = slices.DeleteFunc(a.pending, func(tx blockchain.Transaction) bool {
+		return slices.Contains(txs, tx)
+	})
+}
@@ -0,0 +1,70 @@
+package consensus
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+// Set is the validator set in effect at a point of the chain, with the
+// changes proposed to it that still lack a majority
+type Set struct {
+	Validators []string // in slot order
+
+	proposals map[change][]string // proposers of each pending change
+}
+
+// change is a validator set change: the action, as a transaction receiver,
+// and the key
+type change struct {
+	receiver string
+	key      string
+}
+
+// Proposal is a change to the validator set awaiting a majority
+type Proposal struct {
+	Action    string // "add" or "remove"
+	PublicKey string
+	Proposers []string
+}
+
+// NewSet returns validators, which must be normalised, with no proposals
+func NewSet(validators []string) Set {
+	return Set{Validators: slices.Clone(validators), proposals: map[change][]string{}}
+}
+
+func (s Set) clone() Set {
+	next := NewSet(s.Validators)
+	maps.Copy(next.proposals, s.proposals)
+	return next
+}
+
+// majority reports whether more than half of the validators are among
+// proposers; proposers since removed from the set do not count
+func (s Set) majority(proposers []string) bool {
+	n := 0
+	for _, proposer := range proposers {
+		if slices.Contains(s.Validators, proposer) {
+			n++
+		}
+	}
+	return 2*n > len(s.Validators)
+}
+
+// Proposals returns the pending changes ordered by action and key
+func (s Set) Proposals() []Proposal {
+	proposals := make([]Proposal, 0, len(s.proposals))
+	for c, proposers := range s.proposals {
+		action := "add"
+		if c.receiver == blockchain.ReceiverRemoveValidator {
+			action = "remove"
+		}
+		proposals = append(proposals, Proposal{Action: action, PublicKey: c.key, Proposers: slices.Clone(proposers)})
+	}
+	slices.SortFunc(proposals, func(a, b Proposal) int {
+		return cmp.Or(cmp.Compare(a.Action, b.Action), cmp.Compare(a.PublicKey, b.PublicKey))
+	})
+	return proposals
+}
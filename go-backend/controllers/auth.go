@@ -1,25 +1,177 @@
 package controllers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
 	"github.com/gin-gonic/gin"
 )
 
-func Me(c *gin.Context) {
+func (a *AuthController) Me(c *gin.Context) {
+	// Numeric claims decode as float64
 	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user_id not found in context"})
+	id, ok := userID.(float64)
+	if !exists || !ok {
+		apperr.Abort(c, apperr.New(apperr.CodeUnauthorized, "user_id not found in context"))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.MeResponse{
+		UserID:   uint(id),
+		Username: c.GetString("username"),
+		Email:    c.GetString("email"),
+	})
+}
+
+// keyChallengeTTL is how long a signing key challenge may be answered
+const keyChallengeTTL = 5 * time.Minute
+
+// keyChallenges holds the open signing key challenge of each user
+type keyChallenges struct {
+	mu   sync.Mutex
+	open map[string]api.SigningKeyChallenge // by username
+}
+
+// issue replaces the open challenge of username with a new one
+func (k *keyChallenges) issue(username string) (api.SigningKeyChallenge, error) {
+	var nonce [32]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return api.SigningKeyChallenge{}, err
+	}
+	challenge := api.SigningKeyChallenge{
+		Challenge: hex.EncodeToString(nonce[:]),
+		ExpiresAt: time.Now().Add(keyChallengeTTL).UTC(),
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.open == nil {
+		k.open = map[string]api.SigningKeyChallenge{}
+	}
+	for name, c := range k.open {
+		if time.Now().After(c.ExpiresAt) {
+			delete(k.open, name)
+		}
+	}
+	k.open[username] = challenge
+	return challenge, nil
+}
+
+// valid reports whether challenge is the open, unexpired challenge of
+// username
+func (k *keyChallenges) valid(username, challenge string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	open, ok := k.open[username]
+	return ok && open.Challenge == challenge && time.Now().Before(open.ExpiresAt)
+}
+
+// close ends the challenge of username once it was answered, so the same
+// signatures cannot change the key again
+func (k *keyChallenges) close(username string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.open, username)
+}
+
+// SigningKeyChallenge issues the challenge the caller's next SetSigningKey
+// must sign, replacing any earlier one
+func (a *AuthController) SigningKeyChallenge(c *gin.Context) {
+	challenge, err := a.challenges.issue(c.GetString("username"))
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to issue a challenge", err))
+		return
+	}
+	c.JSON(http.StatusOK, challenge)
+}
+
+// SetSigningKey registers or replaces the caller's public key for dataset
+// claims. Once set, every upload of theirs must be signed with it; blocks
+// already mined keep the key they were signed with. The new key must sign
+// the caller's challenge, and so must the key it replaces: a stolen
+// session token alone cannot swap in someone else's key.
+func (a *AuthController) SetSigningKey(c *gin.Context) {
+	var input api.SigningKeyRequest
+	if err := apperr.BindJSON(c, &input); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+	raw, err := hex.DecodeString(input.PublicKey)
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid signing key", apperr.Field("public_key", "must be hex")))
+		return
+	}
+	key, err := blockchain.NewSigningKey(input.Type, raw)
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid signing key", apperr.Field("public_key", err.Error())))
 		return
 	}
 
 	username := c.GetString("username")
+	user, err := a.Repo.FindUserByUsername(username)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load user", err))
+		return
+	}
+	if !a.challenges.valid(username, input.Challenge) {
+		apperr.Abort(c, apperr.Invalid("Invalid signing key", apperr.Field("challenge", "is not open; request one at /api/me/signing-key/challenge")))
+		return
+	}
+	change := blockchain.KeyChange{Username: username, Key: key.String(), Challenge: input.Challenge}
+	if !signedBy(key, change, input.Signature) {
+		apperr.Abort(c, apperr.Invalid("Invalid signing key", apperr.Field("signature", "does not verify for the key change with the new key")))
+		return
+	}
+	if user.SigningKey != "" {
+		current, err := blockchain.ParseSigningKey(user.SigningKey)
+		if err != nil {
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read the current signing key", err))
+			return
+		}
+		if !signedBy(current, change, input.CurrentSignature) {
+			apperr.Abort(c, apperr.Invalid("Invalid signing key", apperr.Field("current_signature", "does not verify for the key change with your current key")))
+			return
+		}
+	}
+	a.challenges.close(username)
 
-	email := c.GetString("email")
+	if err := a.Repo.SetSigningKey(username, key.String()); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to save signing key", err))
+		return
+	}
+	audit.Record(c, a.Repo, models.AuditSigningKey, "", username, true, key.String())
 
-	c.JSON(http.StatusOK, gin.H{
-		"user_id":  userID,
-		"username": username,
-		"email":    email,
-	})
+	c.JSON(http.StatusOK, api.SigningKeyResponse{Username: username, SigningKey: key.String()})
+}
+
+// signedBy reports whether sig is key's hex signature of change
+func signedBy(key blockchain.SigningKey, change blockchain.KeyChange, sig string) bool {
+	raw, err := hex.DecodeString(sig)
+	return err == nil && key.Verify(change.Encode(), raw)
+}
+
+// GetSigningKey returns the key a user signs dataset claims with, so others
+// can tie signed transactions to them
+func (a *AuthController) GetSigningKey(c *gin.Context) {
+	user, err := a.Repo.FindUserByUsername(c.Param("username"))
+	if errors.Is(err, database.ErrNotFound) || (err == nil && user.SigningKey == "") {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "User has no signing key"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load user", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.SigningKeyResponse{Username: user.Username, SigningKey: user.SigningKey})
 }
@@ -1,23 +1,42 @@
 package controllers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
 	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/middleware"
 	"github.com/Kami0rn/ProjectCPE/go-backend/models"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+// AuthController serves registration and login and signs the session tokens
+type AuthController struct {
+	Repo      *database.Repository
+	JWTSecret string
+	Lockout   middleware.LockoutStore
+
+	challenges keyChallenges
+}
+
+func NewAuthController(repo *database.Repository, jwtSecret string) *AuthController {
+	return &AuthController{Repo: repo, JWTSecret: jwtSecret, Lockout: middleware.NewLoginLockout()}
+}
+
+// PasswordHashCost is the bcrypt cost for new password hashes. Tests lower it.
+var PasswordHashCost = 14
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
 	return string(bytes), err
 }
 
@@ -26,86 +45,120 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateToken(user models.User) (string, error) {
-	// ตรวจสอบว่าค่า JWT_SECRET มีหรือไม่
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		log.Println("Warning: JWT_SECRET environment variable not set, using default")
-		secretKey = "default_secret_key" // ค่า default (ใช้เฉพาะการพัฒนา)
+func GenerateToken(user models.User, secretKey string) (string, error) {
+	email := ""
+	if user.Email != nil {
+		email = *user.Email
 	}
-
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
-		"email":    user.Email, // Include email in the token claims
+		"email":    email, // Include email in the token claims
 		"exp":      time.Now().Add(time.Hour * 72).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(secretKey))
 	if err != nil {
-		log.Println("Error signing token:", err)
 		return "", err
 	}
-	log.Println("Generated token:", tokenString)
-	log.Println("JWT_SECRET used for generation:", secretKey)
 	return tokenString, nil
 }
 
-func Register(c *gin.Context) {
-	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		Email    string `json:"email"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+func (a *AuthController) Register(c *gin.Context) {
+	var input api.RegisterRequest
+	if err := apperr.BindJSON(c, &input); err != nil {
+		apperr.Abort(c, err)
 		return
 	}
 
 	hash, err := HashPassword(input.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password hashing failed"})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Password hashing failed", err))
 		return
 	}
 
-	user := models.User{Username: input.Username, PasswordHash: hash, Email: input.Email}
-	if err := database.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username already exists"})
+	user := models.User{Username: input.Username, PasswordHash: hash}
+	if input.Email != "" {
+		user.Email = &input.Email
+	}
+	if err := a.Repo.CreateUser(&user); err != nil {
+		audit.Record(c, a.Repo, models.AuditRegister, input.Username, input.Username, false, "")
+		var duplicate *database.DuplicateError
+		if errors.As(err, &duplicate) && duplicate.Column == "email" {
+			apperr.Abort(c, &apperr.Error{Code: apperr.CodeConflict, Message: "Email already registered",
+				Fields: []api.FieldError{apperr.Field("email", "is already registered")}})
+		} else if errors.As(err, &duplicate) {
+			apperr.Abort(c, &apperr.Error{Code: apperr.CodeConflict, Message: "Username already exists",
+				Fields: []api.FieldError{apperr.Field("username", "is already taken")}})
+		} else {
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to create user", err))
+		}
 		return
 	}
+	audit.Record(c, a.Repo, models.AuditRegister, user.Username, user.Username, true, "")
 
-	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+	c.JSON(http.StatusOK, api.MessageResponse{Message: "User registered successfully"})
 }
 
-func Login(c *gin.Context) {
-	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+func (a *AuthController) Login(c *gin.Context) {
+	var input api.LoginRequest
+	if err := apperr.BindJSON(c, &input); err != nil {
+		apperr.Abort(c, err)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	// Refuse early while the account is locked so a locked account costs no bcrypt work
+	lockKey := middleware.LockoutKey(input.Username, c.ClientIP())
+	if locked, wait := a.Lockout.Locked(lockKey, time.Now()); locked {
+		audit.Record(c, a.Repo, models.AuditLoginFailed, input.Username, input.Username, false, "account locked")
+		accountLocked(c, wait)
 		return
 	}
 
-	var user models.User
-	if err := database.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	user, err := a.Repo.FindUserByUsername(input.Username)
+	if errors.Is(err, database.ErrNotFound) {
+		a.loginFailed(c, input.Username, lockKey, "unknown user")
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load user", err))
 		return
 	}
 
 	if !CheckPasswordHash(input.Password, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		a.loginFailed(c, input.Username, lockKey, "wrong password")
 		return
 	}
+	a.Lockout.Reset(lockKey)
 
-	token, err := GenerateToken(user)
+	token, err := GenerateToken(user, a.JWTSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Token generation failed", err))
+		return
+	}
+	audit.Record(c, a.Repo, models.AuditLogin, user.Username, user.Username, true, "")
+
+	c.JSON(http.StatusOK, api.LoginResponse{Token: token})
+}
+
+// loginFailed records a failed attempt and answers with 401, or 429 if this
+// attempt tipped the account into lockout.
+func (a *AuthController) loginFailed(c *gin.Context, username, lockKey, reason string) {
+	wait := a.Lockout.Fail(lockKey, time.Now())
+	if wait > 0 {
+		reason += ", account locked"
+	}
+	audit.Record(c, a.Repo, models.AuditLoginFailed, username, username, false, reason)
+
+	if wait > 0 {
+		accountLocked(c, wait)
 		return
 	}
+	apperr.Abort(c, apperr.New(apperr.CodeUnauthorized, "Invalid credentials"))
+}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+func accountLocked(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	apperr.Abort(c, apperr.New(apperr.CodeAccountLocked, "Too many failed login attempts, account temporarily locked"))
 }
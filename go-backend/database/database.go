@@ -0,0 +1,47 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Open connects to the database selected by cfg.Driver. The schema is managed
+// by the migrations in migrations/, see MigrateUp.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverPostgres:
+		dialector = postgres.Open(cfg.ConnString())
+	case config.DriverSQLite:
+		dialector = sqlite.Open(cfg.ConnString())
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	// TranslateError maps unique violations to ErrDuplicate for both drivers
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+
+	if cfg.Driver == config.DriverSQLite {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		// Every connection to ":memory:" is a separate database, and SQLite
+		// serialises writers anyway, so stick to a single connection
+		sqlDB.SetMaxOpenConns(1)
+		if err := db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("connected to database", "driver", cfg.Driver)
+	return db, nil
+}
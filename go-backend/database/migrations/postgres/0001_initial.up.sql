@@ -0,0 +1,58 @@
+-- Baseline schema. Matches what AutoMigrate created, so existing databases
+-- pick it up without changes (IF NOT EXISTS everywhere).
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    email TEXT UNIQUE
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    id BIGSERIAL PRIMARY KEY,
+    "index" BIGINT,
+    "timestamp" TIMESTAMPTZ,
+    prev_hash TEXT,
+    hash TEXT,
+    nonce TEXT,
+    model_hash TEXT,
+    trained_by TEXT
+);
+
+CREATE TABLE IF NOT EXISTS model_logs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    model_name TEXT,
+    epochs BIGINT,
+    accuracy DOUBLE PRECISION,
+    used_in_block BOOLEAN,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS models (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT,
+    created_by TEXT,
+    created_at TIMESTAMPTZ,
+    hash TEXT,
+    organization TEXT
+);
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_by TEXT,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL,
+    username TEXT NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_membership_org_user ON memberships (organization_id, username);
@@ -0,0 +1,7 @@
+ALTER TABLE memberships DROP CONSTRAINT IF EXISTS fk_memberships_organization;
+
+ALTER TABLE models ALTER COLUMN organization DROP NOT NULL;
+ALTER TABLE models ALTER COLUMN organization DROP DEFAULT;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,13 @@
+-- users embedded gorm.Model, which added a soft-delete column nothing uses.
+-- Soft-deleted users still held their unique username, so drop it.
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+
+-- Personal models have an empty organization rather than NULL
+UPDATE models SET organization = '' WHERE organization IS NULL;
+ALTER TABLE models ALTER COLUMN organization SET DEFAULT '';
+ALTER TABLE models ALTER COLUMN organization SET NOT NULL;
+
+ALTER TABLE memberships
+    ADD CONSTRAINT fk_memberships_organization
+    FOREIGN KEY (organization_id) REFERENCES organizations (id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+CREATE TABLE peers (
+    id BIGSERIAL PRIMARY KEY,
+    address TEXT NOT NULL,
+    bootstrap BOOLEAN NOT NULL DEFAULT FALSE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX idx_peers_address ON peers (address);
@@ -0,0 +1,9 @@
+CREATE TABLE anchors (
+    id BIGSERIAL PRIMARY KEY,
+    from_index BIGINT NOT NULL,
+    to_index BIGINT NOT NULL,
+    root TEXT NOT NULL,
+    tx_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_anchors_to_index ON anchors (to_index);
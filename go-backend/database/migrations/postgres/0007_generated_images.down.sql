@@ -0,0 +1 @@
+DROP TABLE IF EXISTS generated_images;
@@ -0,0 +1,13 @@
+CREATE TABLE generated_images (
+    id BIGSERIAL PRIMARY KEY,
+    hash TEXT NOT NULL,
+    model_id BIGINT NOT NULL,
+    block_hash TEXT NOT NULL,
+    seed BIGINT NOT NULL DEFAULT 0,
+    requested_by TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_generated_images_hash ON generated_images (hash);
+CREATE INDEX idx_generated_images_model_id ON generated_images (model_id);
@@ -0,0 +1,4 @@
+-- Back to '' for users without an email. The unique constraint lets only
+-- one user have '', so with two or more of them this fails and the
+-- migration stays applied rather than reporting a revert it did not make.
+UPDATE users SET email = '' WHERE email IS NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
+DROP TABLE IF EXISTS models;
+DROP TABLE IF EXISTS model_logs;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS users;
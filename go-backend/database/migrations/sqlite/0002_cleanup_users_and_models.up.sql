@@ -0,0 +1,2 @@
+-- SQLite support started after this cleanup, so 0001 already creates the
+-- clean schema. Kept so versions line up with the Postgres migrations.
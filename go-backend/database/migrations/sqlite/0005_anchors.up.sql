@@ -0,0 +1,9 @@
+CREATE TABLE anchors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_index INTEGER NOT NULL,
+    to_index INTEGER NOT NULL,
+    root TEXT NOT NULL,
+    tx_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_anchors_to_index ON anchors (to_index);
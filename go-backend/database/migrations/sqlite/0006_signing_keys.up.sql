@@ -0,0 +1,2 @@
+-- A user's public key for dataset claims, "<type>:<hex key>"
+ALTER TABLE users ADD COLUMN signing_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Users without an email had '' stored, which the unique constraint let only
+-- one of them have. NULL never collides.
+UPDATE users SET email = NULL WHERE email = '';
@@ -1,47 +0,0 @@
-package database
-
-import (
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/Kami0rn/ProjectCPE/go-backend/models"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-var DB *gorm.DB
-
-func ConnectDB() *gorm.DB {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		getEnv("DB_HOST", "localhost"),
-		getEnv("DB_USER", "postgres"),
-		getEnv("DB_PASSWORD", "password"),
-		getEnv("DB_NAME", "ai_blockchain"),
-		getEnv("DB_PORT", "5432"),
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("❌ Failed to connect to database:", err)
-	}
-
-	err = db.AutoMigrate(&models.User{}, &models.Block{}, &models.ModelLog{}, &models.Model{})
-	if err != nil {
-		log.Fatal("❌ Failed to migrate database:", err)
-	}
-
-	log.Println("✅ Connected to Database and AutoMigrated")
-	DB = db
-	return db
-}
-
-// helper to get env with fallback
-func getEnv(key, fallback string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		return fallback
-	}
-	return val
-}
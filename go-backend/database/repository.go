@@ -0,0 +1,278 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by lookups that match no row
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicate is returned when an insert violates a unique constraint
+var ErrDuplicate = gorm.ErrDuplicatedKey
+
+// DuplicateError is an ErrDuplicate naming the column that collided
+type DuplicateError struct {
+	Column string
+}
+
+func (e *DuplicateError) Error() string {
+	return "duplicate " + e.Column
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// Repository is the data access layer used by the handlers. It is built on
+// whatever *gorm.DB Open returned, so tests can hand in an in-memory SQLite.
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// DB exposes the underlying connection for migrations and health checks
+func (r *Repository) DB() *gorm.DB {
+	return r.db
+}
+
+// CreateUser inserts user, returning a *DuplicateError if the username or
+// email is taken
+func (r *Repository) CreateUser(user *models.User) error {
+	err := r.db.Create(user).Error
+	if !errors.Is(err, ErrDuplicate) {
+		return err
+	}
+	// The drivers do not say which constraint failed, so look
+	if _, err := r.FindUserByUsername(user.Username); errors.Is(err, ErrNotFound) && user.Email != nil {
+		return &DuplicateError{Column: "email"}
+	}
+	return &DuplicateError{Column: "username"}
+}
+
+func (r *Repository) FindUserByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+// SetSigningKey registers the public key username signs dataset claims with
+func (r *Repository) SetSigningKey(username, key string) error {
+	result := r.db.Model(&models.User{}).Where("username = ?", username).Update("signing_key", key)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r *Repository) CreateModel(model *models.Model) error {
+	return r.db.Create(model).Error
+}
+
+func (r *Repository) ListModels() ([]models.Model, error) {
+	var list []models.Model
+	err := r.db.Find(&list).Error
+	return list, err
+}
+
+// FindModel looks up a personal model when orgName is empty, otherwise the
+// organisation's model of that name
+func (r *Repository) FindModel(name, createdBy, orgName string) (models.Model, error) {
+	var model models.Model
+	query := r.db.Where("name = ? AND created_by = ? AND organization = ''", name, createdBy)
+	if orgName != "" {
+		query = r.db.Where("name = ? AND organization = ?", name, orgName)
+	}
+	err := query.First(&model).Error
+	return model, err
+}
+
+func (r *Repository) FindOrganization(name string) (models.Organization, error) {
+	var org models.Organization
+	err := r.db.Where("name = ?", name).First(&org).Error
+	return org, err
+}
+
+// CreateOrganization creates org and makes owner its first owner
+func (r *Repository) CreateOrganization(org *models.Organization, owner string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			OrganizationID: org.ID,
+			Username:       owner,
+			Role:           models.RoleOwner,
+			CreatedAt:      time.Now(),
+		}).Error
+	})
+}
+
+func (r *Repository) ListOrganizationsFor(username string) ([]models.OrganizationWithRole, error) {
+	var orgs []models.OrganizationWithRole
+	err := r.db.Table("organizations").
+		Select("organizations.*, memberships.role").
+		Joins("JOIN memberships ON memberships.organization_id = organizations.id").
+		Where("memberships.username = ?", username).
+		Scan(&orgs).Error
+	return orgs, err
+}
+
+func (r *Repository) FindMembership(orgID uint, username string) (models.Membership, error) {
+	var member models.Membership
+	err := r.db.Where("organization_id = ? AND username = ?", orgID, username).First(&member).Error
+	return member, err
+}
+
+func (r *Repository) ListMembers(orgID uint) ([]models.Membership, error) {
+	var members []models.Membership
+	err := r.db.Where("organization_id = ?", orgID).Find(&members).Error
+	return members, err
+}
+
+// SetMembership adds username to the organisation or updates their role
+func (r *Repository) SetMembership(orgID uint, username, role string) (models.Membership, error) {
+	member := models.Membership{OrganizationID: orgID, Username: username}
+	err := r.db.Where(member).
+		Assign(models.Membership{Role: role}).
+		Attrs(models.Membership{CreatedAt: time.Now()}).
+		FirstOrCreate(&member).Error
+	return member, err
+}
+
+func (r *Repository) CountOwners(orgID uint) (int64, error) {
+	var owners int64
+	err := r.db.Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ?", orgID, models.RoleOwner).
+		Count(&owners).Error
+	return owners, err
+}
+
+func (r *Repository) DeleteMembership(member *models.Membership) error {
+	return r.db.Delete(member).Error
+}
+
+func (r *Repository) CreateAuditEvent(event *models.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+// AuditFilter narrows ListAuditEvents; zero fields match everything
+type AuditFilter struct {
+	Action   string
+	Actor    string
+	BeforeID uint // page backwards from this ID
+	Limit    int
+}
+
+// ListAuditEvents returns matching events, newest first
+func (r *Repository) ListAuditEvents(filter AuditFilter) ([]models.AuditEvent, error) {
+	query := r.db.Order("id DESC").Limit(filter.Limit)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var events []models.AuditEvent
+	err := query.Find(&events).Error
+	return events, err
+}
+
+func (r *Repository) ListPeers() ([]models.Peer, error) {
+	var peers []models.Peer
+	err := r.db.Order("id").Find(&peers).Error
+	return peers, err
+}
+
+func (r *Repository) FindPeer(id uint) (models.Peer, error) {
+	var peer models.Peer
+	err := r.db.First(&peer, id).Error
+	return peer, err
+}
+
+// AddPeer stores a normalised peer address unless it is already known. A
+// known peer listed in the configuration becomes a bootstrap peer.
+func (r *Repository) AddPeer(address string, bootstrap bool) (models.Peer, error) {
+	peer := models.Peer{Address: address}
+	query := r.db.Where(peer).Attrs(models.Peer{CreatedAt: time.Now()})
+	if bootstrap {
+		query = query.Assign(models.Peer{Bootstrap: true})
+	}
+	err := query.FirstOrCreate(&peer).Error
+	return peer, err
+}
+
+// UpdatePeerHealth saves the outcome of a health check
+func (r *Repository) UpdatePeerHealth(peer *models.Peer) error {
+	return r.db.Model(peer).Select("failures", "last_error", "last_seen_at").Updates(peer).Error
+}
+
+func (r *Repository) DeletePeer(peer *models.Peer) error {
+	return r.db.Delete(peer).Error
+}
+
+func (r *Repository) CreateAnchor(anchor *models.Anchor) error {
+	return r.db.Create(anchor).Error
+}
+
+// ListAnchors returns every anchor, oldest first
+func (r *Repository) ListAnchors() ([]models.Anchor, error) {
+	var anchors []models.Anchor
+	err := r.db.Order("id").Find(&anchors).Error
+	return anchors, err
+}
+
+// FindAnchor returns the latest anchor made by the transaction txid that
+// covers the block at index
+func (r *Repository) FindAnchor(index int, txid string) (models.Anchor, error) {
+	var anchor models.Anchor
+	err := r.db.Where("tx_id = ? AND from_index <= ? AND to_index >= ?", txid, index, index).
+		Order("id DESC").First(&anchor).Error
+	return anchor, err
+}
+
+func (r *Repository) CreateGeneratedImage(image *models.GeneratedImage) error {
+	return r.db.Create(image).Error
+}
+
+// ListGeneratedImages returns up to limit images generated from the model,
+// newest first, paging backwards from beforeID when it is set
+func (r *Repository) ListGeneratedImages(modelID, beforeID uint, limit int) ([]models.GeneratedImage, error) {
+	query := r.db.Where("model_id = ?", modelID).Order("id DESC").Limit(limit)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var images []models.GeneratedImage
+	err := query.Find(&images).Error
+	return images, err
+}
+
+// FindGeneratedImage returns the first image generated with the hash
+func (r *Repository) FindGeneratedImage(hash string) (models.GeneratedImage, error) {
+	var image models.GeneratedImage
+	err := r.db.Where("hash = ?", hash).Order("id").First(&image).Error
+	return image, err
+}
+
+func (r *Repository) FindModelByID(id uint) (models.Model, error) {
+	var model models.Model
+	err := r.db.First(&model, id).Error
+	return model, err
+}
+
+// FindModelByHash returns the model mined in the block with hash
+func (r *Repository) FindModelByHash(hash string) (models.Model, error) {
+	var model models.Model
+	err := r.db.Where("hash = ?", hash).First(&model).Error
+	return model, err
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LoadAnchors attaches the stored anchors to the blocks they cover. Call
+// it after LoadState; anchors of blocks no longer on the chain are skipped.
+func (h *Handler) LoadAnchors() error {
+	anchors, err := h.Repo.ListAnchors()
+	if err != nil {
+		return err
+	}
+	for _, a := range anchors {
+		var root [32]byte
+		if n, err := hex.Decode(root[:], []byte(a.Root)); err != nil || n != len(root) {
+			continue
+		}
+		h.Chain.SetAnchor(a.FromIndex, a.ToIndex, root, a.TxID)
+	}
+	return nil
+}
+
+// anchorTimeout is how long an anchor may wait in the UTXO node's memory
+// pool before AnchorBlocks gives up on it and anchors its blocks again
+const anchorTimeout = time.Hour
+
+// RunAnchoring anchors the new blocks every interval until ctx is done;
+// see AnchorBlocks
+func (h *Handler) RunAnchoring(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := h.AnchorBlocks(ctx); err != nil {
+			logging.FromContext(ctx).Warn("failed to anchor blocks", "error", err)
+		}
+	}
+}
+
+// AnchorBlocks records the Merkle root of the hashes of the blocks after
+// the last anchored one, up to the tip, in the UTXO chain. The genesis
+// block is never anchored. When the last anchor is stale (see staleAnchor)
+// its blocks are anchored again along with the new ones. It returns the
+// new anchor, or nil if there was nothing to anchor.
+func (h *Handler) AnchorBlocks(ctx context.Context) (*models.Anchor, error) {
+	if h.Anchors == nil {
+		return nil, errors.New("anchoring is disabled")
+	}
+	h.anchoring.Lock()
+	defer h.anchoring.Unlock()
+
+	blocks := h.Chain.Blocks()
+	from := 1
+	for i := len(blocks) - 1; i > 0; i-- {
+		if blocks[i].AnchorTxID == "" {
+			continue
+		}
+		from = i + 1
+		last, stale, err := h.staleAnchor(ctx, i, blocks[i].AnchorTxID)
+		if err != nil {
+			return nil, err
+		}
+		if stale {
+			logging.FromContext(ctx).Warn("anchoring blocks again", "from", last.FromIndex, "to", last.ToIndex, "tx_id", last.TxID)
+			from = last.FromIndex
+		}
+		break
+	}
+	to := len(blocks) - 1
+	if from > to {
+		return nil, nil
+	}
+
+	root := blockchain.AnchorRoot(blocks[from : to+1])
+	txid, err := h.Anchors.Submit(ctx, blockchain.AnchorPayload(from, to, root))
+	if err != nil {
+		return nil, err
+	}
+	a := models.Anchor{
+		FromIndex: from,
+		ToIndex:   to,
+		Root:      hex.EncodeToString(root[:]),
+		TxID:      txid,
+		CreatedAt: time.Now(),
+	}
+	if err := h.Repo.CreateAnchor(&a); err != nil {
+		return nil, err
+	}
+	if !h.Chain.SetAnchor(from, to, root, txid) {
+		logging.FromContext(ctx).Warn("chain changed while anchoring", "from", from, "to", to, "tx_id", txid)
+	}
+	logging.FromContext(ctx).Info("anchored blocks", "from", from, "to", to, "tx_id", txid)
+	return &a, nil
+}
+
+// staleAnchor looks up the anchor txid of the block at index and reports
+// whether it is stale: the UTXO node does not know its transaction, the
+// transaction does not carry the anchor's payload, or it has waited to be
+// mined for longer than anchorTimeout
+func (h *Handler) staleAnchor(ctx context.Context, index int, txid string) (models.Anchor, bool, error) {
+	a, err := h.Repo.FindAnchor(index, txid)
+	if errors.Is(err, database.ErrNotFound) {
+		return a, false, nil
+	}
+	if err != nil {
+		return a, false, err
+	}
+	payload, err := anchorPayload(a)
+	if err != nil {
+		return a, true, nil
+	}
+	status, err := h.Anchors.Status(ctx, a.TxID)
+	if err != nil {
+		return a, false, err
+	}
+	switch {
+	case !status.Found && !status.Pending, !status.Holds(payload):
+		return a, true, nil
+	case status.Pending:
+		return a, time.Since(a.CreatedAt) > anchorTimeout, nil
+	}
+	return a, false, nil
+}
+
+// anchorPayload is the data the transaction of a must carry
+func anchorPayload(a models.Anchor) ([]byte, error) {
+	var root [32]byte
+	if n, err := hex.Decode(root[:], []byte(a.Root)); err != nil {
+		return nil, err
+	} else if n != len(root) {
+		return nil, fmt.Errorf("anchor root of %d bytes", n)
+	}
+	return blockchain.AnchorPayload(a.FromIndex, a.ToIndex, root), nil
+}
+
+// GetBlockAnchor shows the anchor of a block and how deep its transaction
+// is in the UTXO chain
+func (h *Handler) GetBlockAnchor(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		apperr.Abort(c, apperr.Invalid("Invalid block index", apperr.Field("index", "must be a non-negative integer")))
+		return
+	}
+
+	block, ok := h.Chain.BlockAt(index)
+	if !ok {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Block not found"))
+		return
+	}
+	if block.AnchorTxID == "" {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Block is not anchored yet"))
+		return
+	}
+	a, err := h.Repo.FindAnchor(index, block.AnchorTxID)
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Block is not anchored yet"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to look up the anchor", err))
+		return
+	}
+
+	if h.Anchors == nil {
+		apperr.Abort(c, apperr.New(apperr.CodeUpstream, "Anchoring is disabled on this node"))
+		return
+	}
+	payload, err := anchorPayload(a)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read the anchor", err))
+		return
+	}
+	status, err := h.Anchors.Status(c.Request.Context(), a.TxID)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeUpstream, "Failed to reach the anchor node", err))
+		return
+	}
+
+	resp := api.BlockAnchorResponse{
+		BlockIndex: block.Index,
+		BlockHash:  block.Hash,
+		FromIndex:  a.FromIndex,
+		ToIndex:    a.ToIndex,
+		Root:       a.Root,
+		TxID:       a.TxID,
+		Status:     api.AnchorUnknown,
+	}
+	// The node's word that it holds the transaction is not enough: its data
+	// output must carry the root
+	switch {
+	case (status.Found || status.Pending) && !status.Holds(payload):
+		resp.Status = api.AnchorMismatch
+	case status.Found:
+		resp.Status = api.AnchorConfirmed
+		resp.Confirmations = status.Confirmations()
+		resp.UTXOBlockHash = status.BlockHash
+		resp.UTXOHeight = status.Height
+	case status.Pending:
+		resp.Status = api.AnchorPending
+	}
+	c.JSON(http.StatusOK, resp)
+}
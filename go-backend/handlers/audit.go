@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEvents returns the audit trail newest first. Query parameters:
+// action, actor, before (an event ID, for paging) and limit (default 100, max 1000).
+func (h *Handler) ListAuditEvents(c *gin.Context) {
+	filter := database.AuditFilter{
+		Action: c.Query("action"),
+		Actor:  c.Query("actor"),
+		Limit:  100,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = min(limit, 1000)
+	}
+	if before, err := strconv.ParseUint(c.Query("before"), 10, 64); err == nil {
+		filter.BeforeID = uint(before)
+	}
+
+	events, err := h.Repo.ListAuditEvents(filter)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to retrieve audit events", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AuditEventsResponse{Events: events})
+}
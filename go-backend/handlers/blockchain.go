@@ -1,32 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"strings"
+	"path/filepath"
+	"slices"
 	"time"
 
-	"log"
-
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
 	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
 	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
 	"github.com/Kami0rn/ProjectCPE/go-backend/models"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
-var pendingTransactions []blockchain.Transaction
-
 // Get the current blockchain
-func GetChain(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"length": len(blockchain.Blockchain),
-		"chain":  blockchain.Blockchain,
-	})
+func (h *Handler) GetChain(c *gin.Context) {
+	chain := h.Chain.Blocks()
+	c.JSON(http.StatusOK, api.ChainResponse{Length: len(chain), Chain: chain})
 }
 
 func hashFile(filePath string) (string, error) {
@@ -36,267 +38,384 @@ func hashFile(filePath string) (string, error) {
 	}
 	defer file.Close()
 
+	return hashReader(file)
+}
+
+// hashUpload hashes an uploaded file without writing it to disk
+func hashUpload(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return hashReader(file)
+}
+
+func hashReader(r io.Reader) (string, error) {
 	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
+	if _, err := io.Copy(hasher, r); err != nil {
 		return "", err
 	}
 
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-// Add a transaction to the pending pool
-func AddTransaction(c *gin.Context) {
+// Add a transaction to the pending pool. The caller sends it; only rewards
+// move credits, so it carries no amount.
+func (h *Handler) AddTransaction(c *gin.Context) {
 	var tx blockchain.Transaction
-	if err := c.ShouldBindJSON(&tx); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction"})
+	if err := apperr.BindJSON(c, &tx); err != nil {
+		apperr.Abort(c, err)
 		return
 	}
-	pendingTransactions = append(pendingTransactions, tx)
-	c.JSON(http.StatusCreated, gin.H{"message": "Transaction added"})
-}
-
-// Mine a new block
-func MineBlock(c *gin.Context) {
-	// Extract the token from the Authorization header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+	tx.Sender = c.GetString("username")
+	if tx.Amount != 0 {
+		apperr.Abort(c, apperr.Invalid("Invalid transaction", apperr.Field("amount", "must be 0, credits are only moved by rewards")))
 		return
 	}
+	if tx.IsValidatorChange() {
+		apperr.Abort(c, apperr.Invalid("Invalid transaction", apperr.Field("receiver", "validator set changes go through /api/admin/validators")))
+		return
+	}
+	if tx.IsReward() || tx.Reference != "" {
+		apperr.Abort(c, apperr.Invalid("Invalid transaction", apperr.Field("sender", "rewards are credited by the nodes")))
+		return
+	}
+	if tx.IsLicence() || tx.IsUsage() || tx.Model != "" || tx.Licence != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid transaction", apperr.Field("receiver", "licences go through /api/model/licence and usage is recorded by the nodes")))
+		return
+	}
+	if tx.ImageHash != "" || tx.SenderKey != "" || tx.Signature != "" {
+		apperr.Abort(c, apperr.Invalid("Invalid transaction", apperr.Field("image_hash", "images are claimed by mining them")))
+		return
+	}
+	h.Mempool.Add(tx)
+	h.Peers.BroadcastTransaction(c.Request.Context(), tx)
+	c.JSON(http.StatusCreated, api.MessageResponse{Message: "Transaction added"})
+}
 
-	// Remove the "Bearer " prefix from the token string
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	tokenString = strings.TrimSpace(tokenString)
-
-	// Parse and validate the token
-	claims := jwt.MapClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+// Mine a new block
+func (h *Handler) MineBlock(c *gin.Context) {
+	// JWTAuthMiddleware has already validated the token
+	username := c.GetString("username")
+	if username == "" {
+		apperr.Abort(c, apperr.New(apperr.CodeUnauthorized, "Invalid token: username not found"))
 		return
 	}
 
-	// Extract the username from the token claims
-	username, ok := claims["username"].(string)
-	if !ok || username == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: username not found"})
+	// Under proof-of-authority only validators produce blocks, so refuse
+	// before training
+	if !h.isValidator() {
+		apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Node is not a validator"))
 		return
 	}
 
 	// Parse the multipart form data
 	form, err := c.MultipartForm()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form data"})
+		apperr.Abort(c, apperr.FromBindError(err))
 		return
 	}
 
-	// Retrieve all uploaded files
+	// Check the form fields, reporting every missing one at once
 	files := form.File["images"]
+	modelName := c.PostForm("model_name")
+	epochs := c.PostForm("epochs")
+	var missing []api.FieldError
 	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No images provided"})
-		return
+		missing = append(missing, apperr.Field("images", "at least one image is required"))
 	}
-
-	// Retrieve model_name
-	modelName := c.PostForm("model_name")
 	if modelName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Model name is required"})
+		missing = append(missing, apperr.Field("model_name", "is required"))
+	} else if !apperr.SafeName(modelName) || len(modelName) > 64 {
+		// It names a storage directory
+		missing = append(missing, apperr.Field("model_name", "must be at most 64 letters, digits, '-' or '_'"))
+	}
+	if epochs == "" {
+		missing = append(missing, apperr.Field("epochs", "is required"))
+	}
+	// Images are saved under their names before they are hashed, so two
+	// with the same name would train on one
+	seen := map[string]bool{}
+	for _, file := range files {
+		name := filepath.Base(file.Filename)
+		if seen[name] {
+			missing = append(missing, apperr.Field("images", fmt.Sprintf("two images are named %q", name)))
+			break
+		}
+		seen[name] = true
+	}
+	if len(missing) > 0 {
+		apperr.Abort(c, apperr.Invalid("Invalid request", missing...))
 		return
 	}
 
-	epochs := c.PostForm("epochs")
-	if epochs == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Epochs parameter is required"})
+	// Training on behalf of an organisation requires membership in it
+	orgName := c.PostForm("organization")
+	if orgName != "" {
+		if _, _, err := h.requireOrgRole(orgName, username, models.RoleMember); err != nil {
+			respondOrgError(c, err)
+			return
+		}
+	}
+	owner := storageOwner(username, orgName)
+
+	// The AI service keeps one set of weights per model name, which another
+	// run would overwrite
+	release, err := h.reserveModel(owner, username, orgName, modelName)
+	if err != nil {
+		apperr.Abort(c, err)
 		return
 	}
+	defer release()
 
-	// Save all uploaded files temporarily in the user's folder structure
-	basePath := "./user_data/" + username + "/" + modelName + "/uploaded_images"
+	// Save all uploaded files temporarily in the owner's folder structure
+	basePath := filepath.Join(h.DataDir, owner, modelName, "uploaded_images")
 	if err := os.MkdirAll(basePath, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create directories"})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to create directories", err))
 		return
 	}
 
 	tempFilePaths := []string{}
 	for _, file := range files {
-		tempFilePath := basePath + "/" + file.Filename
+		tempFilePath := filepath.Join(basePath, filepath.Base(file.Filename))
 		if err := c.SaveUploadedFile(file, tempFilePath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image file"})
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to save image file", err))
 			return
 		}
 		tempFilePaths = append(tempFilePaths, tempFilePath)
 	}
 
-	// Compute the hash of each uploaded image and add it as a transaction
+	// Compute the hash of each uploaded image; they go into the block only
+	// once training succeeded
+	var imageTxs []blockchain.Transaction
 	for _, filePath := range tempFilePaths {
 		hash, err := hashFile(filePath)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash image file"})
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to hash image file", err))
 			return
 		}
 
 		// Add the hash as a transaction
 		tx := blockchain.Transaction{
-			Sender:    username,
-			Receiver:  "blockchain",
-			Amount:    0, // No monetary value, just storing the hash
-			ImageHash: hash,
+			Sender:       username,
+			Receiver:     "blockchain",
+			Amount:       0, // No monetary value, just storing the hash
+			ImageHash:    hash,
+			Organization: orgName,
 		}
-		pendingTransactions = append(pendingTransactions, tx)
+		imageTxs = append(imageTxs, tx)
 	}
 
-	// Request AI proof from the Python module
-	aiProof, err := blockchain.RequestAIProof(tempFilePaths, epochs, username, modelName)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI proof"})
+	// A user with a signing key claims the images by signing the dataset
+	// claim client-side; the signature goes into each image transaction
+	if err := h.signImageTxs(c, imageTxs, modelName, orgName); err != nil {
+		apperr.Abort(c, err)
 		return
 	}
 
-	// Get the last block in the blockchain
-	lastBlock := blockchain.Blockchain[len(blockchain.Blockchain)-1]
-
-	// Create a new block
-	newBlock := blockchain.Block{
-		Index:        lastBlock.Index + 1,
-		Timestamp:    time.Now(),
-		Transactions: pendingTransactions,
-		PrevHash:     lastBlock.Hash,
-		Proof:        aiProof,
+	// Request the training proof from the Python module
+	trainStart := time.Now()
+	proof, err := h.AI.Train(c.Request.Context(), tempFilePaths, epochs, owner, modelName)
+	h.Metrics.TrainingDuration.Observe(time.Since(trainStart).Seconds())
+	if err != nil {
+		h.Metrics.AICallErrors.WithLabelValues("train").Inc()
+		audit.Record(c, h.Repo, models.AuditMine, "", modelName, false, "training failed")
+		apperr.Abort(c, apperr.Wrap(apperr.CodeUpstream, "Failed to get AI proof", err))
+		return
 	}
-	newBlock.Hash = blockchain.CalculateHash(newBlock)
 
-	// Validate the new block
-	if !blockchain.IsBlockValid(newBlock, lastBlock) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block"})
+	// The proof must commit to exactly the images we uploaded
+	imageHashes := make([]string, len(imageTxs))
+	for i, tx := range imageTxs {
+		imageHashes[i] = tx.ImageHash
+	}
+	if root := blockchain.DatasetRoot(imageHashes); proof.DatasetRoot != hex.EncodeToString(root[:]) {
+		audit.Record(c, h.Repo, models.AuditMine, "", modelName, false, "dataset root mismatch")
+		apperr.Abort(c, apperr.New(apperr.CodeUpstream, "AI service reported a different training dataset"))
+		return
+	}
+	// Rewards go to the owner the proof names
+	if proof.Owner != owner || proof.ModelName != modelName {
+		audit.Record(c, h.Repo, models.AuditMine, "", modelName, false, "proof owner mismatch")
+		apperr.Abort(c, apperr.New(apperr.CodeUpstream, "AI service reported a different model"))
 		return
 	}
 
-	// Add the new block to the blockchain
-	blockchain.AddBlock(newBlock)
-	pendingTransactions = nil
-
-	// Broadcast the new block to peers
-	blockchain.BroadcastBlock(newBlock)
+	// Add a block with the pending transactions, this upload and the
+	// rewards for it to the chain and broadcast it; under
+	// proof-of-authority this waits for the node's slot
+	rewardTxs := h.trainingRewards(proof, imageHashes)
+	newBlock, err := h.produceBlock(c.Request.Context(), slices.Concat(imageTxs, rewardTxs), &proof)
+	switch {
+	case errors.Is(err, consensus.ErrNotValidator):
+		apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Node is no longer a validator"))
+		return
+	case errors.Is(err, blockchain.ErrInvalidBlock):
+		apperr.Abort(c, apperr.New(apperr.CodeConflict, "Chain advanced while mining, retry"))
+		return
+	case err != nil:
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to produce block", err))
+		return
+	}
+	audit.Record(c, h.Repo, models.AuditMine, "", newBlock.Hash, true, fmt.Sprintf("block %d, model %s, organization %q", newBlock.Index, modelName, orgName))
 
 	// Save the model information in the database
 	model := models.Model{
-		Name:      modelName,
-		CreatedBy: username,
-		CreatedAt: time.Now(),
-		Hash:      newBlock.Hash,
+		Name:         modelName,
+		CreatedBy:    username,
+		CreatedAt:    time.Now(),
+		Hash:         newBlock.Hash,
+		Organization: orgName,
 	}
-	if err := database.DB.Create(&model).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save model to database"})
+	if err := h.Repo.CreateModel(&model); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to save model to database", err))
 		return
 	}
 
 	c.JSON(http.StatusCreated, newBlock)
 }
 
-func CheckImage(c *gin.Context) {
-	// Parse the uploaded image
-	file, err := c.FormFile("image")
+// reserveModel claims owner's modelName for a training run until release
+// is called. It fails if the model was mined already, by this node or on
+// the chain, or is being trained.
+func (h *Handler) reserveModel(owner, username, orgName, modelName string) (release func(), err error) {
+	exists := apperr.New(apperr.CodeConflict, "Model already exists, choose another name")
+	if _, err := h.Repo.FindModel(modelName, username, orgName); err == nil {
+		return nil, exists
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return nil, apperr.Wrap(apperr.CodeInternal, "Failed to load model", err)
+	}
+	for _, block := range h.Chain.Blocks() {
+		if proof := block.TrainingProof; proof != nil && proof.Owner == owner && proof.ModelName == modelName {
+			return nil, exists
+		}
+	}
+
+	key := owner + "/" + modelName
+	h.trainingMu.Lock()
+	defer h.trainingMu.Unlock()
+	if h.training[key] {
+		return nil, apperr.New(apperr.CodeConflict, "Model is being trained")
+	}
+	if h.training == nil {
+		h.training = map[string]bool{}
+	}
+	h.training[key] = true
+	return func() {
+		h.trainingMu.Lock()
+		defer h.trainingMu.Unlock()
+		delete(h.training, key)
+	}, nil
+}
+
+// signImageTxs checks the signature form field against the caller's
+// signing key and the dataset of txs and stores it in each transaction.
+// Users without a key upload unsigned, users with one must sign.
+func (h *Handler) signImageTxs(c *gin.Context, txs []blockchain.Transaction, modelName, orgName string) error {
+	username := c.GetString("username")
+	signature := c.PostForm("signature")
+	user, err := h.Repo.FindUserByUsername(username)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No image provided"})
-		return
+		return apperr.Wrap(apperr.CodeInternal, "Failed to load user", err)
+	}
+	switch {
+	case user.SigningKey == "" && signature == "":
+		return nil
+	case user.SigningKey == "":
+		return apperr.Invalid("Invalid request", apperr.Field("signature", "register a signing key at /api/me/signing-key first"))
+	case signature == "":
+		return apperr.Invalid("Invalid request", apperr.Field("signature", "is required once you registered a signing key"))
+	}
+
+	imageHashes := make([]string, len(txs))
+	for i, tx := range txs {
+		imageHashes[i] = tx.ImageHash
+	}
+	claim := blockchain.DatasetClaim{
+		Sender:       username,
+		ModelName:    modelName,
+		Organization: orgName,
+		DatasetRoot:  blockchain.DatasetRoot(imageHashes),
+	}
+	if err := claim.Verify(user.SigningKey, signature); err != nil {
+		audit.Record(c, h.Repo, models.AuditMine, "", modelName, false, "dataset claim signature does not verify")
+		return apperr.Invalid("Invalid request", apperr.Field("signature", "does not verify for the dataset claim with your signing key"))
 	}
 
-	// Save the uploaded image temporarily
-	tempFilePath := "./temp_uploaded_image"
-	if err := c.SaveUploadedFile(file, tempFilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+	for i := range txs {
+		txs[i].SenderKey = user.SigningKey
+		txs[i].Signature = signature
+	}
+	return nil
+}
+
+func (h *Handler) CheckImage(c *gin.Context) {
+	// Parse the uploaded image
+	file, err := c.FormFile("image")
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is required")))
 		return
 	}
-	defer os.Remove(tempFilePath) // Clean up the temporary file
 
 	// Compute the hash of the uploaded image
-	imageHash, err := hashFile(tempFilePath)
+	imageHash, err := hashUpload(file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute image hash"})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to compute image hash", err))
 		return
 	}
 
 	// Collect all matches for the image hash
-	matches := []gin.H{}
-	for _, block := range blockchain.Blockchain {
+	var resp api.CheckImageResponse
+	for _, block := range h.Chain.Blocks() {
 		for _, tx := range block.Transactions {
 			if tx.ImageHash == imageHash {
-				matches = append(matches, gin.H{
-					"block_index": block.Index,
-					"timestamp":   block.Timestamp,
+				resp.Matches = append(resp.Matches, api.ImageMatch{
+					BlockIndex: block.Index,
+					Timestamp:  block.Timestamp,
 				})
 			}
 		}
 	}
+	resp.Trained = len(resp.Matches) > 0
 
-	// Return the results
-	if len(matches) > 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"trained": true,
-			"matches": matches,
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"trained": false,
-		})
-	}
+	c.JSON(http.StatusOK, resp)
 }
 
-func ReceiveBlock(c *gin.Context) {
+func (h *Handler) ReceiveBlock(c *gin.Context) {
 	var block blockchain.Block
-	if err := c.ShouldBindJSON(&block); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block data"})
+	if err := apperr.BindJSON(c, &block); err != nil {
+		apperr.Abort(c, err)
 		return
 	}
 
-	// Validate the block
-	lastBlock := blockchain.Blockchain[len(blockchain.Blockchain)-1]
-	if !blockchain.IsBlockValid(block, lastBlock) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block"})
+	// Validate and add the block to the blockchain
+	if err := h.Replication.AcceptBlock(block); err != nil {
+		apperr.Abort(c, apperr.New(apperr.CodeInvalidInput, "Block does not extend the chain"))
 		return
 	}
-
-	// Add the block to the blockchain
-	blockchain.AddBlock(block)
-	c.JSON(http.StatusOK, gin.H{"message": "Block added successfully"})
+	c.JSON(http.StatusOK, api.MessageResponse{Message: "Block added successfully"})
 }
 
-func AddPeer(c *gin.Context) {
-	var request struct {
-		Peer string `json:"peer" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid peer data"})
+// SynchronizeBlockchain catches up with any peer whose chain is longer than
+// ours. Only one sync runs at a time; a call made while another is running
+// returns immediately.
+func (h *Handler) SynchronizeBlockchain(ctx context.Context) {
+	if !h.syncing.TryLock() {
 		return
 	}
+	defer h.syncing.Unlock()
 
-	blockchain.AddPeer(request.Peer)
-	c.JSON(http.StatusOK, gin.H{"message": "Peer added successfully"})
-}
-
-func SynchronizeBlockchain() {
-	peers := blockchain.GetPeers()
-	for _, peer := range peers {
-		resp, err := http.Get("http://" + peer + "/api/chain")
+	logger := logging.FromContext(ctx)
+	for _, peer := range h.Peers.List() {
+		adopted, err := h.PeerClient.Sync(ctx, peer, h.Chain, h.Mempool)
 		if err != nil {
-			log.Printf("Failed to fetch chain from peer %s: %v", peer, err)
+			logger.Warn("failed to sync with peer", "peer", peer, "error", err)
 			continue
 		}
-		defer resp.Body.Close()
-
-		var response struct {
-			Chain []blockchain.Block `json:"chain"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			log.Printf("Failed to decode chain from peer %s: %v", peer, err)
-			continue
-		}
-
-		// Replace the current blockchain if the peer's chain is longer
-		if len(response.Chain) > len(blockchain.Blockchain) {
-			blockchain.Blockchain = response.Chain
+		if adopted > 0 {
+			logger.Info("adopted blocks from peer", "peer", peer, "blocks", adopted, "height", h.Chain.LastBlock().Index)
 		}
 	}
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/bundle"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"gorm.io/gorm"
+)
+
+// ErrNodeNotEmpty is returned when importing into a node that has blocks
+// or models of its own
+var ErrNodeNotEmpty = errors.New("node already has blocks or models")
+
+// ExportBundle writes the node's chain and models as a bundle, with the
+// genesis validator set on a proof-of-authority node
+func (h *Handler) ExportBundle(w io.Writer, format bundle.Format) (bundle.Manifest, error) {
+	modelList, err := h.Repo.ListModels()
+	if err != nil {
+		return bundle.Manifest{}, err
+	}
+	var validators []string
+	if h.Authority != nil {
+		validators = h.Authority.Genesis()
+	}
+	return bundle.Write(w, format, h.Chain.Blocks(), modelList, validators)
+}
+
+// ImportBundle loads a verified bundle into a node that holds only the
+// genesis block and no models, then saves the node state. The chain, the
+// models and the state are taken together: if storing a model or saving the
+// state fails, no model is kept and the node's chain is put back.
+func (h *Handler) ImportBundle(b *bundle.Bundle) error {
+	existing, err := h.Repo.ListModels()
+	if err != nil {
+		return err
+	}
+	if h.Chain.Len() > 1 || len(existing) > 0 {
+		return ErrNodeNotEmpty
+	}
+	previous := h.Chain.Blocks()
+	if len(b.Blocks) > 1 && !h.Chain.Replace(b.Blocks) {
+		return errors.New("bundle chain does not validate")
+	}
+
+	err = h.Repo.DB().Transaction(func(tx *gorm.DB) error {
+		repo := database.NewRepository(tx)
+		for _, model := range b.Models {
+			model.ID = 0 // let the database assign IDs after its own sequence
+			if err := repo.CreateModel(&model); err != nil {
+				return err
+			}
+		}
+		return h.SaveState()
+	})
+	if err != nil {
+		h.Chain.Restore(previous)
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// UseAuthority switches the node to proof-of-authority. Call it before
+// LoadState so the restored chain is checked too.
+func (h *Handler) UseAuthority(a *consensus.Authority) {
+	h.Authority = a
+	h.Chain.SetConsensus(a)
+}
+
+// isValidator reports whether the node may produce blocks: always in open
+// mode, under proof-of-authority only while its key is in the set
+func (h *Handler) isValidator() bool {
+	if h.Authority == nil {
+		return true
+	}
+	self := h.Authority.Self()
+	return self != "" && slices.Contains(h.Authority.Validators(h.Chain.Blocks()), self)
+}
+
+// awaitSlot waits for this validator's next slot to start
+func (h *Handler) awaitSlot(ctx context.Context) error {
+	start, err := h.Authority.NextSlot(h.Chain.Blocks(), time.Now())
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// produceBlock adds a block holding the queued validator set changes, the
+// mempool and txs to the chain and broadcasts it. proof is nil for blocks
+// without a training run. Under proof-of-authority it first waits for this
+// node's slot and seals the block. If the chain does not take the block,
+// the mempool transactions go back to the mempool.
+func (h *Handler) produceBlock(ctx context.Context, txs []blockchain.Transaction, proof *blockchain.TrainingProof) (blockchain.Block, error) {
+	h.producing.Lock()
+	defer h.producing.Unlock()
+
+	if h.Authority != nil {
+		if err := h.awaitSlot(ctx); err != nil {
+			return blockchain.Block{}, err
+		}
+	}
+
+	var changes []blockchain.Transaction
+	if h.Authority != nil {
+		changes = h.Authority.PendingChanges(h.Chain.Blocks())
+	}
+	// Rewards a block from a peer credited meanwhile, or no longer pays,
+	// would make ours invalid, so they are dropped
+	pending := h.payable(h.uncredited(h.Mempool.Drain()))
+	transactions := slices.Concat(changes, pending, txs)
+
+	var block blockchain.Block
+	if proof != nil {
+		block = blockchain.GenerateTrainedBlock(h.Chain.LastBlock(), transactions, *proof)
+	} else {
+		block = blockchain.GenerateBlock(h.Chain.LastBlock(), transactions, "")
+	}
+	if h.Authority != nil {
+		if err := h.Authority.Seal(&block); err != nil {
+			h.Mempool.Restore(pending)
+			return blockchain.Block{}, err
+		}
+	}
+
+	// This fails if another block was added since we read the last one
+	if err := h.Chain.AddBlock(block); err != nil {
+		h.Mempool.Restore(pending)
+		return blockchain.Block{}, err
+	}
+	if h.Authority != nil {
+		h.Authority.Committed(changes)
+	}
+
+	h.Peers.BroadcastBlock(ctx, block)
+	return block, nil
+}
+
+// RunBlockProducer produces a block in this validator's slots whenever
+// transactions or validator set changes are waiting, so they are not held
+// back until the next mine. It returns when ctx is done, at once in open
+// mode.
+func (h *Handler) RunBlockProducer(ctx context.Context) {
+	if h.Authority == nil {
+		return
+	}
+	ticker := time.NewTicker(h.Authority.SlotDuration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !h.isValidator() || (h.Mempool.Len() == 0 && len(h.Authority.PendingChanges(h.Chain.Blocks())) == 0) {
+			continue
+		}
+		block, err := h.produceBlock(ctx, nil, nil)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("failed to produce block", "error", err)
+			}
+			continue
+		}
+		slog.Info("produced block", "index", block.Index, "transactions", len(block.Transactions))
+	}
+}
+
+// GetValidators describes the node's consensus and the current validator set
+func (h *Handler) GetValidators(c *gin.Context) {
+	if h.Authority == nil {
+		c.JSON(http.StatusOK, api.ValidatorsResponse{Mode: config.ConsensusOpen, Validators: []string{}})
+		return
+	}
+	chain := h.Chain.Blocks()
+	var proposals []api.ValidatorProposal
+	for _, p := range h.Authority.Proposals(chain) {
+		proposals = append(proposals, api.ValidatorProposal{Action: p.Action, PublicKey: p.PublicKey, Proposers: p.Proposers})
+	}
+	c.JSON(http.StatusOK, api.ValidatorsResponse{
+		Mode:           config.ConsensusPoA,
+		SlotDurationMS: h.Authority.SlotDuration().Milliseconds(),
+		Validators:     h.Authority.Validators(chain),
+		Proposals:      proposals,
+		Self:           h.Authority.Self(),
+	})
+}
+
+// ChangeValidator queues this node's proposal of a validator set change.
+// It goes on-chain in the next block this node produces; once a majority
+// of the validators proposed the change, it takes effect from the block
+// after.
+func (h *Handler) ChangeValidator(c *gin.Context) {
+	var request api.ValidatorChangeRequest
+	if err := apperr.BindJSON(c, &request); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+	if h.Authority == nil {
+		apperr.Abort(c, apperr.New(apperr.CodeConflict, "Node is not running proof-of-authority consensus"))
+		return
+	}
+
+	receiver := blockchain.ReceiverAddValidator
+	if request.Action == "remove" {
+		receiver = blockchain.ReceiverRemoveValidator
+	}
+	tx, err := h.Authority.Propose(h.Chain.Blocks(), receiver, request.PublicKey)
+	if err != nil {
+		audit.Record(c, h.Repo, models.AuditValidatorChange, "", request.PublicKey, false, err.Error())
+		switch {
+		case errors.Is(err, consensus.ErrNotValidator):
+			apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Only a validator can change the validator set"))
+		default:
+			apperr.Abort(c, apperr.Invalid("Invalid validator set change", apperr.Field("public_key", err.Error())))
+		}
+		return
+	}
+
+	audit.Record(c, h.Repo, models.AuditValidatorChange, "", request.PublicKey, true, request.Action)
+	c.JSON(http.StatusAccepted, api.ValidatorChangeResponse{Message: "Validator set change proposed", Transaction: tx})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/aiclient"
+	"github.com/Kami0rn/ProjectCPE/go-backend/anchor"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blobstore"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/metrics"
+	"github.com/Kami0rn/ProjectCPE/go-backend/replication"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+)
+
+// syncTimeout bounds a catch-up triggered by a peer announcing a block
+// beyond our tip
+const syncTimeout = time.Minute
+
+// Handler holds the dependencies shared by the HTTP handlers. Each Handler
+// is one node: it owns its copy of the chain, its mempool and its peers.
+type Handler struct {
+	Repo    *database.Repository
+	AI      *aiclient.Client
+	Chain   *blockchain.Chain
+	Mempool *blockchain.Mempool
+	Peers   *blockchain.Peers
+	Metrics *metrics.Metrics
+	DataDir string // root of the per-owner upload folders
+
+	// Blobs holds the generated images, under DataDir/.blobs
+	Blobs *blobstore.Store
+
+	// NodeKey signs the provenance manifests of generated images, which
+	// are served without one while it is nil. Set it with LoadNodeKey.
+	NodeKey ed25519.PrivateKey
+	// TrustedNodeKeys are the hex public keys of the other nodes whose
+	// manifests InspectImage accepts, besides NodeKey and the validators
+	TrustedNodeKeys []string
+
+	// Node-to-node traffic goes over gRPC: PeerClient reaches the peers,
+	// Replication serves them
+	PeerClient  *replication.Client
+	Replication *replication.Server
+
+	// Authority is the proof-of-authority consensus, nil in open mode. Set
+	// it with UseAuthority.
+	Authority *consensus.Authority
+
+	// Rewards is what training runs and generations credit the owners of
+	// the images; the zero policy credits nothing
+	Rewards rewards.Policy
+
+	// Anchors records block hashes in the sample_blockchain UTXO chain,
+	// nil when anchoring is disabled
+	Anchors *anchor.Client
+
+	syncing   sync.Mutex // held while SynchronizeBlockchain runs
+	producing sync.Mutex // held while produceBlock builds and adds a block
+	anchoring sync.Mutex // held while AnchorBlocks runs
+
+	trainingMu sync.Mutex
+	training   map[string]bool // owner/model of the training runs in progress
+}
+
+// New returns a node starting from the genesis block
+func New(repo *database.Repository, ai *aiclient.Client, dataDir string) *Handler {
+	h := &Handler{
+		Repo:    repo,
+		AI:      ai,
+		Chain:   blockchain.NewChain(),
+		Mempool: blockchain.NewMempool(),
+		DataDir: dataDir,
+		Blobs:   blobstore.New(filepath.Join(dataDir, ".blobs")),
+	}
+	h.PeerClient = replication.NewClient()
+	h.Peers = blockchain.NewPeers(h.Chain, h.PeerClient)
+	h.Replication = &replication.Server{
+		Chain:   h.Chain,
+		Mempool: h.Mempool,
+		OnBehind: func() {
+			ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+			defer cancel()
+			h.SynchronizeBlockchain(ctx)
+		},
+	}
+	h.Metrics = metrics.New(metrics.Gauges{
+		ChainHeight: h.Chain.Len,
+		MempoolSize: h.Mempool.Len,
+		PeerCount:   func() int { return len(h.Peers.List()) },
+		PeerLag: func() map[string]int {
+			lag := map[string]int{}
+			for _, status := range h.Peers.Status() {
+				lag[status.Peer] = status.Lag
+			}
+			return lag
+		},
+	})
+	h.Peers.OnBroadcastFailure = func(peer string, err error) {
+		h.Metrics.BroadcastFailures.Inc()
+	}
+	return h
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is up and serving requests
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, api.HealthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the node can do useful work: the database and the
+// AI service are reachable and the local chain is loaded and valid.
+func (h *Handler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := map[string]string{}
+	ready := true
+	fail := func(name string, err error) {
+		checks[name] = err.Error()
+		ready = false
+	}
+
+	if err := h.pingDB(ctx); err != nil {
+		fail("database", err)
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if err := h.AI.Ping(ctx); err != nil {
+		fail("ai_service", err)
+	} else {
+		checks["ai_service"] = "ok"
+	}
+
+	if chain := h.Chain.Blocks(); len(chain) == 0 || !blockchain.IsChainValid(chain) {
+		fail("chain", blockchain.ErrInvalidBlock)
+	} else {
+		checks["chain"] = "ok"
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, api.ReadinessResponse{Ready: ready, Checks: checks})
+}
+
+func (h *Handler) pingDB(ctx context.Context) error {
+	sqlDB, err := h.Repo.DB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -1,68 +1,191 @@
 package handlers
 
 import (
-	"bytes"
-	"fmt"
-	"io"
-	"mime/multipart"
+	"errors"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blobstore"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
 	"github.com/gin-gonic/gin"
 )
 
-// FetchGeneratedImage sends a request to the Python backend to generate an image
-func FetchGeneratedImage(username, modelName string) ([]byte, error) {
-	// Prepare the form data
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	_ = writer.WriteField("username", username)
-	_ = writer.WriteField("model_name", modelName)
-	writer.Close()
-
-	// Send the request to the Python backend
-	req, err := http.NewRequest("POST", "http://localhost:5000/generate", body)
+// GenerateImageHandler generates an image from a model, watermarks it with
+// the model's block hash, attaches a signed provenance manifest, keeps it
+// in the model's gallery and returns it.
+// X-Image-Hash names it for the gallery endpoints and X-Block-Hash is the
+// block hash embedded in it.
+func (h *Handler) GenerateImageHandler(c *gin.Context) {
+	username := c.Query("username")
+	modelName := c.Query("model_name")
+	orgName := c.Query("organization")
+
+	if modelName == "" || (username == "" && orgName == "") {
+		apperr.Abort(c, apperr.Invalid("model_name and username or organization are required"))
+		return
+	}
+
+	// Only members may generate from an organisation's model
+	model, ok := h.findModel(c, modelName, username, orgName, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	// Fetch the generated image from the Python backend; the seed is
+	// recorded so it can be reproduced
+	seed := rand.Int64()
+	imageData, err := h.AI.Generate(c.Request.Context(), storageOwner(username, orgName), modelName, model.Hash, seed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		h.Metrics.AICallErrors.WithLabelValues("generate").Inc()
+		apperr.Abort(c, apperr.Wrap(apperr.CodeUpstream, "Failed to generate image", err))
+		return
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Watermark it with the block hash, robustly enough to survive
+	// recompression, resizing and screenshots; see VerifyImage. An image
+	// that cannot be marked is refused rather than served unmarked, since
+	// nothing would then tie it to the model; it is neither stored nor
+	// credited.
+	imageData, err = watermarkImage(imageData, model.Hash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to watermark generated image", err))
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch image: %s", resp.Status)
+	// and sign a manifest of where it came from into its metadata; see
+	// InspectImage
+	now := time.Now()
+	imageData, err = h.attachManifest(imageData, model, now)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to sign generated image", err))
+		return
 	}
 
-	// Read the image data from the response
-	imageData, err := io.ReadAll(resp.Body)
+	hash, err := h.Blobs.Put(imageData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image data: %v", err)
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to store generated image", err))
+		return
+	}
+	image := models.GeneratedImage{
+		Hash:        hash,
+		ModelID:     model.ID,
+		BlockHash:   model.Hash,
+		Seed:        seed,
+		RequestedBy: c.GetString("username"),
+		RequestID:   c.GetString("request_id"),
+		Size:        len(imageData),
+		CreatedAt:   now,
 	}
+	if err := h.Repo.CreateGeneratedImage(&image); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to record generated image", err))
+		return
+	}
+
+	// Record the generation against the model's licence and pay the owners
+	// of the training images; the chain pays no generation before its
+	// usage record, so the record is queued first
+	h.recordUsage(c, model, image.Hash)
+	h.creditGeneration(model, image.Hash)
 
-	return imageData, nil
+	// Return the image as a binary response
+	c.Header("X-Image-Hash", hash)
+	c.Header("X-Block-Hash", model.Hash)
+	c.Data(http.StatusOK, "image/png", imageData)
 }
 
-// GenerateImageHandler handles the API request to fetch a generated image
-func GenerateImageHandler(c *gin.Context) {
+// ListGeneratedImages is the gallery of a model, newest first. Query
+// parameters: those of GenerateImageHandler, before (an image ID, for
+// paging) and limit (default 20, max 100).
+func (h *Handler) ListGeneratedImages(c *gin.Context) {
 	username := c.Query("username")
 	modelName := c.Query("model_name")
+	orgName := c.Query("organization")
+	if modelName == "" || (username == "" && orgName == "") {
+		apperr.Abort(c, apperr.Invalid("model_name and username or organization are required"))
+		return
+	}
+	model, ok := h.findModel(c, modelName, username, orgName, models.RoleMember)
+	if !ok {
+		return
+	}
 
-	if username == "" || modelName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username and model_name are required"})
+	limit := 20
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		limit = min(n, 100)
+	}
+	var before uint
+	if id, err := strconv.ParseUint(c.Query("before"), 10, 64); err == nil {
+		before = uint(id)
+	}
+
+	images, err := h.Repo.ListGeneratedImages(model.ID, before, limit)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to retrieve generated images", err))
 		return
 	}
+	c.JSON(http.StatusOK, api.GalleryResponse{Model: model, Images: images})
+}
 
-	// Fetch the generated image from the Python backend
-	imageData, err := FetchGeneratedImage(username, modelName)
+// generatedImage loads the generated image named by the hash parameter and
+// its model, which the caller must be a member of the organisation of. It
+// responds and returns false if there is none.
+func (h *Handler) generatedImage(c *gin.Context) (models.GeneratedImage, models.Model, bool) {
+	image, err := h.Repo.FindGeneratedImage(c.Param("hash"))
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "No image was generated with that hash"))
+		return image, models.Model{}, false
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to look up generated image", err))
+		return image, models.Model{}, false
+	}
+	model, err := h.Repo.FindModelByID(image.ModelID)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load model", err))
+		return image, model, false
+	}
+	if model.Organization != "" {
+		if _, _, err := h.requireOrgRole(model.Organization, c.GetString("username"), models.RoleMember); err != nil {
+			respondOrgError(c, err)
+			return image, model, false
+		}
+	}
+	return image, model, true
+}
+
+// GetGeneratedImage tells which model, and which block, produced a
+// generated image
+func (h *Handler) GetGeneratedImage(c *gin.Context) {
+	image, model, ok := h.generatedImage(c)
+	if !ok {
 		return
 	}
+	resp := api.ImageProvenanceResponse{Image: image, Model: model, BlockIndex: -1}
+	if block, ok := h.Chain.BlockByHash(image.BlockHash); ok {
+		resp.BlockIndex = block.Index
+	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	// Return the image as a binary response
-	c.Data(http.StatusOK, "image/png", imageData)
+// GetGeneratedImageContent returns a generated image from the blob store
+func (h *Handler) GetGeneratedImageContent(c *gin.Context) {
+	image, _, ok := h.generatedImage(c)
+	if !ok {
+		return
+	}
+	data, err := h.Blobs.Get(image.Hash)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Generated image is no longer stored"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read generated image", err))
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
 }
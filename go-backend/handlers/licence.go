@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// findModel loads a personal model of username or, when orgName is set,
+// the organisation's model, which the caller must hold at least minRole
+// in. It responds and returns false if there is none.
+func (h *Handler) findModel(c *gin.Context, modelName, username, orgName, minRole string) (models.Model, bool) {
+	if orgName != "" {
+		if _, _, err := h.requireOrgRole(orgName, c.GetString("username"), minRole); err != nil {
+			respondOrgError(c, err)
+			return models.Model{}, false
+		}
+	}
+	model, err := h.Repo.FindModel(modelName, username, orgName)
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Model not found"))
+		return model, false
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load model", err))
+		return model, false
+	}
+	return model, true
+}
+
+// recordUsage queues the usage record of the image with imageHash the
+// caller generated from model, under the licence on the chain. Like the
+// rewards of the generation it is keyed by the hash of the stored image,
+// which the client does not choose, so each image is recorded once.
+func (h *Handler) recordUsage(c *gin.Context, model models.Model, imageHash string) {
+	tx := blockchain.Transaction{
+		Sender:    c.GetString("username"),
+		Receiver:  blockchain.ReceiverUsage,
+		Model:     model.Hash,
+		Licence:   blockchain.LicenceOf(h.Chain.Blocks(), model.Hash),
+		Reference: blockchain.UsageReference(model.Hash, imageHash),
+	}
+	if h.Chain.Credited(tx.Reference) {
+		return
+	}
+	h.Mempool.Add(tx)
+	h.Peers.BroadcastTransaction(c.Request.Context(), tx)
+}
+
+// SetLicence queues a licence record for a model of the caller, or of an
+// organisation they administer. It takes effect in the next block.
+func (h *Handler) SetLicence(c *gin.Context) {
+	var req api.LicenceRequest
+	if err := apperr.BindJSON(c, &req); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+	if err := req.Licence.Validate(); err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid licence", apperr.Field("licence.id", err.Error())))
+		return
+	}
+	username := c.GetString("username")
+	model, ok := h.findModel(c, req.ModelName, username, req.Organization, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	tx := blockchain.Transaction{
+		Sender:       username,
+		Receiver:     blockchain.ReceiverLicence,
+		Organization: req.Organization,
+		Model:        model.Hash,
+		Licence:      &req.Licence,
+	}
+	h.Mempool.Add(tx)
+	h.Peers.BroadcastTransaction(c.Request.Context(), tx)
+
+	audit.Record(c, h.Repo, models.AuditLicence, "", model.Hash, true, req.Licence.ID)
+	c.JSON(http.StatusAccepted, api.LicenceChangeResponse{Message: "Licence queued for the next block", Transaction: tx})
+}
+
+// GetLicence returns the licence of a model, to anyone who may generate
+// from it
+func (h *Handler) GetLicence(c *gin.Context) {
+	modelName := c.Query("model_name")
+	username := c.Query("username")
+	orgName := c.Query("organization")
+	if modelName == "" || (username == "" && orgName == "") {
+		apperr.Abort(c, apperr.Invalid("model_name and username or organization are required"))
+		return
+	}
+	model, ok := h.findModel(c, modelName, username, orgName, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	resp := api.LicenceResponse{Model: model.Hash, Licence: blockchain.LicenceOf(h.Chain.Blocks(), model.Hash)}
+	for _, tx := range h.Mempool.Pending() {
+		if tx.IsLicence() && tx.Model == model.Hash {
+			resp.Pending = tx.Licence
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetModelUsage tells the owner of a model who generated how many images
+// from it under which licence
+func (h *Handler) GetModelUsage(c *gin.Context) {
+	modelName := c.Query("model_name")
+	if modelName == "" {
+		apperr.Abort(c, apperr.Invalid("model_name is required"))
+		return
+	}
+	model, ok := h.findModel(c, modelName, c.GetString("username"), c.Query("organization"), models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	resp := api.ModelUsageResponse{Model: model.Hash, Usage: blockchain.ModelUsage(h.Chain.Blocks(), model.Hash)}
+	for _, u := range resp.Usage {
+		resp.Images += u.Images
+	}
+	for _, tx := range h.Mempool.Pending() {
+		if tx.IsUsage() && tx.Model == model.Hash {
+			resp.Pending++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,63 +2,76 @@ package handlers
 
 import (
 	"encoding/base64"
+	"errors"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"path/filepath"
 
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
 	"github.com/Kami0rn/ProjectCPE/go-backend/database"
 	"github.com/Kami0rn/ProjectCPE/go-backend/models"
 	"github.com/gin-gonic/gin"
 )
 
 // GetAllModels retrieves all models from the database
-func GetAllModels(c *gin.Context) {
-	var models []models.Model
-
+func (h *Handler) GetAllModels(c *gin.Context) {
 	// Query the database for all models
-	if err := database.DB.Find(&models).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve models"})
+	models, err := h.Repo.ListModels()
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to retrieve models", err))
 		return
 	}
 
 	// Return the models as JSON
-	c.JSON(http.StatusOK, gin.H{"models": models})
+	c.JSON(http.StatusOK, api.ModelsResponse{Models: models})
 }
 
 // GetModel retrieves a specific model from the database and attaches a sample picture
-func GetModel(c *gin.Context) {
+func (h *Handler) GetModel(c *gin.Context) {
 	// Get dynamic values from JSON body
-	var req struct {
-		Username  string `json:"username"`
-		ModelName string `json:"model_name"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	var req api.ModelRequest
+	if err := apperr.BindJSON(c, &req); err != nil {
+		apperr.Abort(c, err)
 		return
 	}
 	username := req.Username
 	modelName := req.ModelName
 
-	if username == "" || modelName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username and model_name are required"})
+	orgName := req.Organization
+
+	if username == "" && orgName == "" {
+		apperr.Abort(c, apperr.Invalid("username or organization is required"))
 		return
 	}
 
-	// Query the database for the specific model
-	var model models.Model
-	if err := database.DB.Where("name = ? AND created_by = ?", modelName, username).First(&model).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+	// Query the database for the specific model; organisation models are
+	// visible to every member of the organisation
+	if orgName != "" {
+		if _, _, err := h.requireOrgRole(orgName, c.GetString("username"), models.RoleMember); err != nil {
+			respondOrgError(c, err)
+			return
+		}
+	}
+	model, err := h.Repo.FindModel(modelName, username, orgName)
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Model not found"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load model", err))
 		return
 	}
 
 	// Construct the path to the uploaded images directory dynamically
-	imagesDir := filepath.Join("C:\\Project\\go-backend\\user_data", username, modelName, "uploaded_images")
+	imagesDir := filepath.Join(h.DataDir, storageOwner(username, orgName), modelName, "uploaded_images")
 
 	// List all files in the directory
 	files, err := ioutil.ReadDir(imagesDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to read images directory"})
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Unable to read images directory", err))
 		return
 	}
 
@@ -87,9 +100,10 @@ func GetModel(c *gin.Context) {
 		}
 	}
 
-	// Return the model and the selected images as JSON
-	c.JSON(http.StatusOK, gin.H{
-		"model":         model,
-		"sample_images": encodedImages,
+	// Return the model, the selected images and its licence as JSON
+	c.JSON(http.StatusOK, api.ModelResponse{
+		Model:        model,
+		SampleImages: encodedImages,
+		Licence:      blockchain.LicenceOf(h.Chain.Blocks(), model.Hash),
 	})
 }
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Organisation names end up in storage paths, so keep them to a safe charset
+var orgNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{1,62}$`)
+
+var errNotMember = errors.New("not a member of this organisation")
+
+// roleRank orders roles so a check can ask for "at least admin"
+var roleRank = map[string]int{
+	models.RoleMember: 1,
+	models.RoleAdmin:  2,
+	models.RoleOwner:  3,
+}
+
+// requireOrgRole loads the organisation and checks that username holds at
+// least minRole in it.
+func (h *Handler) requireOrgRole(orgName, username, minRole string) (models.Organization, models.Membership, error) {
+	org, err := h.Repo.FindOrganization(orgName)
+	if err != nil {
+		return org, models.Membership{}, err
+	}
+
+	member, err := h.Repo.FindMembership(org.ID, username)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && roleRank[member.Role] < roleRank[minRole]) {
+		return org, member, errNotMember
+	}
+	return org, member, err
+}
+
+// respondOrgError maps a requireOrgRole error to a response
+func respondOrgError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Organisation not found"))
+	case errors.Is(err, errNotMember):
+		apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Insufficient role in organisation"))
+	default:
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load organisation", err))
+	}
+}
+
+// storageOwner is the directory under user_data that holds a model's files:
+// the username for personal models, "@<org>" for organisation models. The AI
+// service receives the same value in its username field.
+func storageOwner(username, orgName string) string {
+	if orgName != "" {
+		return "@" + orgName
+	}
+	return username
+}
+
+// CreateOrganization creates an organisation with the caller as owner
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	var req api.CreateOrganizationRequest
+	if err := apperr.BindJSON(c, &req); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+	if !orgNamePattern.MatchString(req.Name) {
+		apperr.Abort(c, apperr.Invalid("Invalid organisation name",
+			apperr.Field("name", "must be 2-63 letters, digits, '-' or '_', starting with a letter or digit")))
+		return
+	}
+	username := c.GetString("username")
+
+	org := models.Organization{Name: req.Name, CreatedBy: username, CreatedAt: time.Now()}
+	if err := h.Repo.CreateOrganization(&org, username); errors.Is(err, database.ErrDuplicate) {
+		apperr.Abort(c, apperr.New(apperr.CodeConflict, "Organisation already exists"))
+		return
+	} else if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to create organisation", err))
+		return
+	}
+	audit.Record(c, h.Repo, models.AuditOrgCreate, "", org.Name, true, "")
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations returns the organisations the caller belongs to with their role
+func (h *Handler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.Repo.ListOrganizationsFor(c.GetString("username"))
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to retrieve organisations", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.OrganizationsResponse{Organizations: orgs})
+}
+
+// ListMembers returns the members of an organisation; any member may call it
+func (h *Handler) ListMembers(c *gin.Context) {
+	org, _, err := h.requireOrgRole(c.Param("org"), c.GetString("username"), models.RoleMember)
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+
+	members, err := h.Repo.ListMembers(org.ID)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to retrieve members", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.MembersResponse{Organization: org.Name, Members: members})
+}
+
+// AddMember adds a user to an organisation or changes their role. Admins may
+// manage members; only owners may grant admin or owner or change an owner's
+// role, and the last owner cannot be demoted.
+func (h *Handler) AddMember(c *gin.Context) {
+	var req api.AddMemberRequest
+	if err := apperr.BindJSON(c, &req); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+
+	org, caller, err := h.requireOrgRole(c.Param("org"), c.GetString("username"), models.RoleAdmin)
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	if req.Role != models.RoleMember && caller.Role != models.RoleOwner {
+		apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Only owners can grant admin or owner"))
+		return
+	}
+
+	user, err := h.Repo.FindUserByUsername(req.Username)
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "User not found"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load user", err))
+		return
+	}
+
+	// Demoting an owner takes an owner, and leaves at least one
+	existing, err := h.Repo.FindMembership(org.ID, user.Username)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load member", err))
+		return
+	}
+	if err == nil && existing.Role == models.RoleOwner && req.Role != models.RoleOwner {
+		if caller.Role != models.RoleOwner {
+			apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Only owners can change an owner's role"))
+			return
+		}
+		owners, err := h.Repo.CountOwners(org.ID)
+		if err != nil {
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to count owners", err))
+			return
+		}
+		if owners <= 1 {
+			apperr.Abort(c, apperr.New(apperr.CodeConflict, "Cannot demote the last owner"))
+			return
+		}
+	}
+
+	member, err := h.Repo.SetMembership(org.ID, user.Username, req.Role)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to add member", err))
+		return
+	}
+	audit.Record(c, h.Repo, models.AuditMemberSet, "", org.Name+"/"+user.Username, true, "role "+req.Role)
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a user from an organisation. Members may remove
+// themselves; the last owner cannot leave.
+func (h *Handler) RemoveMember(c *gin.Context) {
+	username := c.GetString("username")
+	target := c.Param("username")
+
+	minRole := models.RoleAdmin
+	if target == username {
+		minRole = models.RoleMember
+	}
+	org, caller, err := h.requireOrgRole(c.Param("org"), username, minRole)
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+
+	member, err := h.Repo.FindMembership(org.ID, target)
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Member not found"))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to load member", err))
+		return
+	}
+	if member.Role == models.RoleOwner {
+		if caller.Role != models.RoleOwner {
+			apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Only owners can remove an owner"))
+			return
+		}
+		owners, err := h.Repo.CountOwners(org.ID)
+		if err != nil {
+			apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to count owners", err))
+			return
+		}
+		if owners <= 1 {
+			apperr.Abort(c, apperr.New(apperr.CodeConflict, "Cannot remove the last owner"))
+			return
+		}
+	}
+
+	if err := h.Repo.DeleteMembership(&member); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to remove member", err))
+		return
+	}
+	audit.Record(c, h.Repo, models.AuditMemberRemove, "", org.Name+"/"+target, true, "")
+
+	c.JSON(http.StatusOK, api.MessageResponse{Message: "Member removed"})
+}
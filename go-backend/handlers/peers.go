@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/audit"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// peerCheckTimeout bounds the status call of one health check
+const peerCheckTimeout = 5 * time.Second
+
+// LoadPeers stores the bootstrap peers and starts replicating with every
+// peer in the database. Call it once at startup.
+func (h *Handler) LoadPeers(bootstrap []string) error {
+	for _, address := range bootstrap {
+		if _, err := h.Repo.AddPeer(address, true); err != nil {
+			return fmt.Errorf("bootstrap peer %s: %w", address, err)
+		}
+	}
+
+	peers, err := h.Repo.ListPeers()
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		if err := h.Peers.Add(peer.Address); err != nil {
+			return fmt.Errorf("stored peer %s: %w", peer.Address, err)
+		}
+	}
+	slog.Info("loaded peers", "count", len(peers), "bootstrap", len(bootstrap))
+	return nil
+}
+
+func (h *Handler) AddPeer(c *gin.Context) {
+	var request api.AddPeerRequest
+	if err := apperr.BindJSON(c, &request); err != nil {
+		apperr.Abort(c, err)
+		return
+	}
+
+	address, err := blockchain.NormalizePeer(request.Peer)
+	if err != nil {
+		audit.Record(c, h.Repo, models.AuditAddPeer, "", request.Peer, false, err.Error())
+		apperr.Abort(c, apperr.Invalid("Invalid peer address", apperr.Field("peer", "must be host:port or an http(s) URL")))
+		return
+	}
+	if _, err := h.Repo.AddPeer(address, false); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to save peer", err))
+		return
+	}
+	h.Peers.Add(address)
+
+	audit.Record(c, h.Repo, models.AuditAddPeer, "", address, true, "")
+	c.JSON(http.StatusOK, api.MessageResponse{Message: "Peer added successfully"})
+}
+
+func (h *Handler) ListPeers(c *gin.Context) {
+	peers, err := h.Repo.ListPeers()
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to list peers", err))
+		return
+	}
+
+	statuses := map[string]blockchain.ReplicationStatus{}
+	for _, status := range h.Peers.Status() {
+		statuses[status.Peer] = status
+	}
+	resp := api.PeersResponse{Peers: make([]api.PeerInfo, len(peers))}
+	for i, peer := range peers {
+		resp.Peers[i].Peer = peer
+		if status, ok := statuses[peer.Address]; ok {
+			resp.Peers[i].Replication = &status
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) RemovePeer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid peer ID", apperr.Field("id", "must be a positive integer")))
+		return
+	}
+
+	peer, err := h.Repo.FindPeer(uint(id))
+	if errors.Is(err, database.ErrNotFound) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Peer not found"))
+		return
+	} else if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to look up peer", err))
+		return
+	}
+	if err := h.Repo.DeletePeer(&peer); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to remove peer", err))
+		return
+	}
+	h.Peers.Remove(peer.Address)
+
+	audit.Record(c, h.Repo, models.AuditRemovePeer, "", peer.Address, true, "")
+	c.JSON(http.StatusOK, api.MessageResponse{Message: "Peer removed"})
+}
+
+// RunPeerHealthChecks checks the peers every interval until ctx is done,
+// starting right away; see CheckPeers
+func (h *Handler) RunPeerHealthChecks(ctx context.Context, interval time.Duration, maxFailures int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.CheckPeers(ctx, maxFailures)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckPeers asks every stored peer for its chain status and records when it
+// was last seen or why it failed. A peer failing maxFailures checks in a row
+// is evicted unless it is a bootstrap peer. If a peer is ahead of us the
+// node synchronises afterwards.
+func (h *Handler) CheckPeers(ctx context.Context, maxFailures int) {
+	logger := logging.FromContext(ctx)
+	peers, err := h.Repo.ListPeers()
+	if err != nil {
+		logger.Error("failed to list peers for health checks", "error", err)
+		return
+	}
+
+	behind := false
+	for _, peer := range peers {
+		checkCtx, cancel := context.WithTimeout(ctx, peerCheckTimeout)
+		status, err := h.PeerClient.ChainStatus(checkCtx, peer.Address)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			peer.Failures++
+			peer.LastError = err.Error()
+			logger.Warn("peer health check failed", "peer", peer.Address, "failures", peer.Failures, "error", err)
+		} else {
+			now := time.Now()
+			peer.Failures, peer.LastError, peer.LastSeenAt = 0, "", &now
+			behind = behind || status.Height > h.Chain.LastBlock().Index
+		}
+
+		if peer.Failures >= maxFailures && !peer.Bootstrap {
+			h.evictPeer(ctx, peer)
+			continue
+		}
+		if err := h.Repo.UpdatePeerHealth(&peer); err != nil {
+			logger.Error("failed to save peer health", "peer", peer.Address, "error", err)
+		}
+	}
+
+	if behind {
+		h.SynchronizeBlockchain(ctx)
+	}
+}
+
+func (h *Handler) evictPeer(ctx context.Context, peer models.Peer) {
+	if err := h.Repo.DeletePeer(&peer); err != nil {
+		logging.FromContext(ctx).Error("failed to evict peer", "peer", peer.Address, "error", err)
+		return
+	}
+	h.Peers.Remove(peer.Address)
+	audit.RecordSystem(ctx, h.Repo, models.AuditEvictPeer, peer.Address, true,
+		fmt.Sprintf("%d failed health checks, last: %s", peer.Failures, peer.LastError))
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyProof checks the training proof of a block: the AI service reloads
+// the model and regenerates the sample from the stored seed, and both the
+// weights and the sample must hash to what the block claims
+func (h *Handler) VerifyProof(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		apperr.Abort(c, apperr.Invalid("Invalid block index", apperr.Field("index", "must be a non-negative integer")))
+		return
+	}
+
+	block, ok := h.Chain.BlockAt(index)
+	if !ok {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Block not found"))
+		return
+	}
+	proof := block.TrainingProof
+	if proof == nil {
+		apperr.Abort(c, apperr.Invalid("Block has no training proof"))
+		return
+	}
+
+	// Whoever produced the block chose the owner and model name, and the AI
+	// service joins them into a path
+	if !apperr.SafeName(strings.TrimPrefix(proof.Owner, "@")) || !apperr.SafeName(proof.ModelName) {
+		apperr.Abort(c, apperr.Invalid("Block's training proof names an invalid owner or model"))
+		return
+	}
+
+	sample, err := h.AI.Regenerate(c.Request.Context(), proof.Owner, proof.ModelName, proof.Seed)
+	if err != nil {
+		h.Metrics.AICallErrors.WithLabelValues("regenerate").Inc()
+		apperr.Abort(c, apperr.Wrap(apperr.CodeUpstream, "Failed to regenerate the sample", err))
+		return
+	}
+
+	resp := api.VerifyProofResponse{
+		BlockIndex:   block.Index,
+		Proof:        *proof,
+		WeightsHash:  sample.WeightsHash,
+		SampleHash:   sample.SampleHash,
+		WeightsMatch: sample.WeightsHash == proof.WeightsHash,
+		SampleMatch:  sample.SampleHash == proof.SampleHash,
+	}
+	resp.Valid = resp.WeightsMatch && resp.SampleMatch
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/Kami0rn/ProjectCPE/go-backend/provenance"
+	"github.com/gin-gonic/gin"
+)
+
+// nodeKeyFile holds the node key, inside DataDir, unless the config names
+// another file
+const nodeKeyFile = "node.key"
+
+// LoadNodeKey loads the key signing the provenance manifests of generated
+// images from path, or from DataDir if path is empty, where it is created
+// if missing
+func (h *Handler) LoadNodeKey(path string) error {
+	if path != "" {
+		key, err := consensus.LoadKey(path)
+		h.NodeKey = key
+		return err
+	}
+
+	path = filepath.Join(h.DataDir, nodeKeyFile)
+	key, err := consensus.LoadKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(h.DataDir, os.ModePerm); err != nil {
+			return err
+		}
+		key, err = consensus.WriteKey(path)
+	}
+	h.NodeKey = key
+	return err
+}
+
+// attachManifest signs a provenance manifest for an image generated from
+// model into the PNG data, unless the node has no key
+func (h *Handler) attachManifest(data []byte, model models.Model, at time.Time) ([]byte, error) {
+	if h.NodeKey == nil {
+		return data, nil
+	}
+	claim := provenance.Claim{
+		ModelID:      model.ID,
+		ModelName:    model.Name,
+		Owner:        model.CreatedBy,
+		Organization: model.Organization != "",
+		BlockHash:    model.Hash,
+		Timestamp:    at.UTC(),
+	}
+	if model.Organization != "" {
+		claim.Owner = model.Organization
+	}
+	return provenance.Attach(data, claim, h.NodeKey)
+}
+
+// trustedKey reports whether manifests signed with the hex public key come
+// from a node to trust: this one, a validator or one configured
+func (h *Handler) trustedKey(key string) bool {
+	if h.NodeKey != nil && key == consensus.PublicKeyHex(h.NodeKey) {
+		return true
+	}
+	if slices.Contains(h.TrustedNodeKeys, key) {
+		return true
+	}
+	return h.Authority != nil && slices.Contains(h.Authority.Validators(h.Chain.Blocks()), key)
+}
+
+// InspectImage reads the provenance manifest of an uploaded PNG or JPEG,
+// checks that a trusted node signed it and that the image is unchanged
+// since, and that the model it names was mined in the block it names
+func (h *Handler) InspectImage(c *gin.Context) {
+	header, err := c.FormFile("image")
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is required")))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read image", err))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read image", err))
+		return
+	}
+
+	resp := api.InspectImageResponse{BlockIndex: -1}
+	manifest, err := provenance.Read(data)
+	switch {
+	case errors.Is(err, provenance.ErrUnsupportedFormat), errors.Is(err, provenance.ErrCorrupt):
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is not a well-formed PNG or JPEG file")))
+		return
+	case errors.Is(err, provenance.ErrNoManifest):
+		c.JSON(http.StatusOK, resp)
+		return
+	case errors.Is(err, provenance.ErrMalformed):
+		resp.HasManifest = true
+		resp.Problem = err.Error()
+		c.JSON(http.StatusOK, resp)
+		return
+	case err != nil:
+		resp.Problem = err.Error()
+	}
+	resp.HasManifest = true
+	resp.Manifest = &manifest
+	// A key whose signature does not verify says nothing of who signed
+	resp.Trusted = !errors.Is(err, provenance.ErrBadSignature) && h.trustedKey(manifest.Claim.NodeKey)
+	if err == nil && !resp.Trusted {
+		resp.Problem = "manifest is signed by node key " + manifest.Claim.NodeKey + ", which this node does not trust"
+	}
+
+	claim := manifest.Claim
+	block, ok := h.Chain.BlockByHash(claim.BlockHash)
+	if ok {
+		resp.BlockExists = true
+		resp.BlockIndex = block.Index
+	}
+	owner := claim.Owner
+	if claim.Organization {
+		owner = storageOwner("", claim.Owner)
+	}
+	mined := ok && block.TrainingProof != nil && block.TrainingProof.ModelName == claim.ModelName && block.TrainingProof.Owner == owner
+	if resp.Problem == "" && !mined {
+		resp.Problem = "model " + claim.ModelName + " of " + claim.Owner + " was not mined in block " + claim.BlockHash + " of this node's chain"
+	}
+	resp.Valid = err == nil && resp.Trusted && mined
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+	"github.com/gin-gonic/gin"
+)
+
+// UseRewards sets the policy the node credits image owners by, which the
+// chain then checks the rewards of every block against. Call it before
+// LoadState so the restored chain is checked too.
+func (h *Handler) UseRewards(p rewards.Policy) {
+	h.Rewards = p
+	h.Chain.SetRewards(p)
+}
+
+// uncredited drops the transactions whose reference is already on the
+// chain; the chain would refuse a block holding them
+func (h *Handler) uncredited(txs []blockchain.Transaction) []blockchain.Transaction {
+	return slices.DeleteFunc(txs, func(tx blockchain.Transaction) bool {
+		return tx.Reference != "" && h.Chain.Credited(tx.Reference)
+	})
+}
+
+// trainingRewards credits the owners of the images of a training run; the
+// owner of the proof uploaded them
+func (h *Handler) trainingRewards(proof blockchain.TrainingProof, imageHashes []string) []blockchain.Transaction {
+	owners := rewards.Owners(h.Chain.Blocks(), imageHashes, proof.Owner)
+	return h.uncredited(h.Rewards.Credits(rewards.TrainingReference(proof), h.Rewards.Train, owners))
+}
+
+// payable drops the rewards the policy would not pay in the next block,
+// such as those of a model a fork took off the chain, so they do not keep
+// the block from being taken. Each reward is checked along with the other
+// transactions of txs, which may hold the usage record paying it.
+func (h *Handler) payable(txs []blockchain.Transaction) []blockchain.Transaction {
+	chain := h.Chain.Blocks()
+	records := slices.DeleteFunc(slices.Clone(txs), blockchain.Transaction.IsReward)
+	return slices.DeleteFunc(txs, func(tx blockchain.Transaction) bool {
+		return tx.IsReward() && h.Rewards.CheckRewards(chain, blockchain.Block{Transactions: append(slices.Clip(records), tx)}) != nil
+	})
+}
+
+// creditGeneration queues the rewards for the image with imageHash
+// generated from model. The hash of the stored image tells generations
+// apart, so each image is credited once. Peers take no rewards, so they
+// wait for the next block this node produces.
+func (h *Handler) creditGeneration(model models.Model, imageHash string) {
+	if h.Rewards.Generate <= 0 {
+		return
+	}
+
+	block, _ := h.Chain.BlockByHash(model.Hash)
+	reference := rewards.GenerationReference(model.Hash, imageHash)
+	owners := rewards.Owners(h.Chain.Blocks(), rewards.ModelImages(block), "")
+	h.Mempool.Add(h.uncredited(h.Rewards.Credits(reference, h.Rewards.Generate, owners))...)
+}
+
+// GetBalance sums the credits of a user on the chain and in the mempool
+func (h *Handler) GetBalance(c *gin.Context) {
+	username := c.Param("username")
+	resp := api.BalanceResponse{Username: username}
+	for _, block := range h.Chain.Blocks() {
+		for _, tx := range block.Transactions {
+			switch amount := rewards.Net(tx, username); {
+			case amount > 0:
+				resp.Credited += amount
+			case amount < 0:
+				resp.Debited -= amount
+			}
+		}
+	}
+	resp.Balance = resp.Credited - resp.Debited
+	for _, tx := range h.Mempool.Pending() {
+		resp.Pending += rewards.Net(tx, username)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStatement lists the credits and debits of a user on the chain
+func (h *Handler) GetStatement(c *gin.Context) {
+	username := c.Param("username")
+	entries := rewards.Statement(h.Chain.Blocks(), username)
+	resp := api.StatementResponse{Username: username, Entries: entries}
+	if resp.Entries == nil {
+		resp.Entries = []api.StatementEntry{}
+	}
+	if len(entries) > 0 {
+		resp.Balance = entries[len(entries)-1].Balance
+	}
+	c.JSON(http.StatusOK, resp)
+}
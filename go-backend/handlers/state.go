@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+// stateFile holds the chain and mempool between restarts, inside DataDir
+const stateFile = "node_state.json"
+
+type nodeState struct {
+	Chain   []blockchain.Block       `json:"chain"`
+	Mempool []blockchain.Transaction `json:"mempool"`
+}
+
+// SaveState writes the chain and the pending transactions to DataDir. The
+// file is replaced atomically so a crash never leaves a partial state.
+func (h *Handler) SaveState() error {
+	data, err := json.Marshal(nodeState{
+		Chain:   h.Chain.Blocks(),
+		Mempool: h.Mempool.Pending(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(h.DataDir, os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(h.DataDir, stateFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(h.DataDir, stateFile))
+}
+
+// LoadState restores the state saved by SaveState, if any. A saved chain
+// that does not validate is an error rather than silently discarded.
+func (h *Handler) LoadState() error {
+	data, err := os.ReadFile(filepath.Join(h.DataDir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state nodeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %w", stateFile, err)
+	}
+	if len(state.Chain) > 1 && !h.Chain.Replace(state.Chain) {
+		return fmt.Errorf("%s: saved chain is invalid", stateFile)
+	}
+	h.Mempool.Add(state.Mempool...)
+
+	slog.Info("restored node state", "blocks", len(state.Chain), "pending_transactions", len(state.Mempool))
+	return nil
+}
+
+// Shutdown lets in-flight broadcasts finish until ctx is done, then saves
+// the node state. Call it after the HTTP server has stopped.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	if err := h.Peers.Shutdown(ctx); err != nil {
+		slog.Warn("cancelled unfinished block broadcasts", "error", err)
+	}
+	if err := h.PeerClient.Close(); err != nil {
+		slog.Warn("failed to close peer connections", "error", err)
+	}
+	return h.SaveState()
+}
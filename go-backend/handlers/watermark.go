@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // VerifyImage reads JPEGs too
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"github.com/Kami0rn/ProjectCPE/go-backend/watermark"
+	"github.com/gin-gonic/gin"
+)
+
+// maxVerifyPixels bounds the images VerifyImage decodes, since a small
+// upload can declare a huge one
+const maxVerifyPixels = 40 << 20
+
+// watermarkImage marks a generated PNG with the start of blockHash, the
+// hash of the block its model was mined in
+func watermarkImage(data []byte, blockHash string) ([]byte, error) {
+	payload, err := hex.DecodeString(blockHash[:min(len(blockHash), 2*watermark.PayloadSize)])
+	if err != nil || len(payload) != watermark.PayloadSize {
+		return nil, fmt.Errorf("block hash %q is not hex", blockHash)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode generated image: %w", err)
+	}
+	marked, err := watermark.Embed(img, payload)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, marked); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyImage reads the watermark of an uploaded image, a generated one
+// perhaps since recompressed, resized or screenshotted, and finds the block
+// it names
+func (h *Handler) VerifyImage(c *gin.Context) {
+	header, err := c.FormFile("image")
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is required")))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read image", err))
+		return
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is not a PNG or JPEG image")))
+		return
+	}
+	if config.Width*config.Height > maxVerifyPixels {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "has too many pixels")))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read image", err))
+		return
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		apperr.Abort(c, apperr.Invalid("Invalid request", apperr.Field("image", "is not a PNG or JPEG image")))
+		return
+	}
+
+	resp := api.VerifyImageResponse{BlockIndex: -1}
+	mark, err := watermark.Extract(img)
+	if errors.Is(err, watermark.ErrNotFound) {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Failed to read watermark", err))
+		return
+	}
+	resp.Watermarked = true
+	resp.HashPrefix = hex.EncodeToString(mark.Payload)
+	resp.Corrected = mark.Corrected
+
+	for _, block := range h.Chain.Blocks() {
+		if strings.HasPrefix(block.Hash, resp.HashPrefix) {
+			resp.BlockIndex, resp.BlockHash = block.Index, block.Hash
+			break
+		}
+	}
+	if resp.BlockHash != "" {
+		model, err := h.Repo.FindModelByHash(resp.BlockHash)
+		if err == nil && h.canSeeModel(c, model) {
+			resp.Model = &model
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// canSeeModel reports whether the caller may see model: anyone may see a
+// user's model, members that of an organisation
+func (h *Handler) canSeeModel(c *gin.Context, model models.Model) bool {
+	if model.Organization == "" {
+		return true
+	}
+	_, _, err := h.requireOrgRole(model.Organization, c.GetString("username"), models.RoleMember)
+	return err == nil
+}
@@ -0,0 +1,292 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+)
+
+// fakeUTXONode answers the anchor and txstatus commands of a
+// sample_blockchain node. Transactions stay pending until mined or
+// dropped. Like a
+// node mining right after it answers, it keeps anchor connections open
+// until the test ends.
+type fakeUTXONode struct {
+	ln     net.Listener
+	mining chan struct{} // closed when the test ends
+
+	mu      sync.Mutex
+	data    [][]byte       // anchored payloads, the index is the txid; nil once dropped
+	heights map[string]int // UTXO block height of each mined txid
+	best    int
+}
+
+// Mirrors of the sample_blockchain network types
+type (
+	utxoAnchor struct {
+		AddrFrom string
+		Data     []byte
+	}
+	utxoAnchorReply struct {
+		TxID  []byte
+		Error string
+	}
+	utxoTxStatus struct {
+		AddrFrom string
+		ID       []byte
+	}
+	utxoTxInfo struct {
+		Found      bool
+		Pending    bool
+		BlockHash  []byte
+		Height     int
+		BestHeight int
+		Data       [][]byte
+	}
+)
+
+func newFakeUTXONode(t *testing.T) *fakeUTXONode {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeUTXONode{ln: ln, mining: make(chan struct{}), heights: map[string]int{}}
+	t.Cleanup(func() {
+		close(f.mining)
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeUTXONode) Addr() string {
+	return f.ln.Addr().String()
+}
+
+// Mine puts the transaction txid in a block at height and makes best the
+// tip height
+func (f *fakeUTXONode) Mine(txid string, height, best int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heights[txid] = height
+	f.best = best
+}
+
+// Drop forgets the transaction txid, as a node restarting with an empty
+// memory pool would
+func (f *fakeUTXONode) Drop(txid string) {
+	f.Rewrite(txid, nil)
+}
+
+// Rewrite makes the transaction txid carry data instead of its payload
+func (f *fakeUTXONode) Rewrite(txid string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id, _ := hex.DecodeString(txid)
+	f.data[id[0]] = data
+}
+
+// Data returns the payloads anchored so far
+func (f *fakeUTXONode) Data() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.data...)
+}
+
+func (f *fakeUTXONode) serve(conn net.Conn) {
+	defer conn.Close()
+	req, err := io.ReadAll(conn)
+	if err != nil || len(req) < 12 {
+		return
+	}
+	cmd := string(bytes.TrimRight(req[:12], "\x00"))
+	dec := gob.NewDecoder(bytes.NewReader(req[12:]))
+
+	cmdOut, reply := f.answer(cmd, dec)
+	if reply == nil {
+		return
+	}
+	out := make([]byte, 12)
+	copy(out, cmdOut)
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(reply)
+	conn.Write(append(out, buf.Bytes()...))
+	if cmd == "anchor" {
+		<-f.mining
+	}
+}
+
+func (f *fakeUTXONode) answer(cmd string, dec *gob.Decoder) (cmdOut string, reply any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch cmd {
+	case "anchor":
+		var payload utxoAnchor
+		dec.Decode(&payload)
+		f.data = append(f.data, payload.Data)
+		cmdOut, reply = "anchored", utxoAnchorReply{TxID: []byte{byte(len(f.data) - 1)}}
+	case "txstatus":
+		var payload utxoTxStatus
+		dec.Decode(&payload)
+		info := utxoTxInfo{BestHeight: f.best}
+		if len(payload.ID) == 1 && int(payload.ID[0]) < len(f.data) && f.data[payload.ID[0]] != nil {
+			info.Data = [][]byte{f.data[payload.ID[0]]}
+			if height, ok := f.heights[hex.EncodeToString(payload.ID)]; ok {
+				info.Found, info.Height, info.BlockHash = true, height, []byte{0xbb}
+			} else {
+				info.Pending = true
+			}
+		}
+		cmdOut, reply = "txinfo", info
+	}
+	return cmdOut, reply
+}
+
+func TestBlocksAreAnchoredInTheUTXOChain(t *testing.T) {
+	utxo := newFakeUTXONode(t)
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.Anchor.Node = utxo.Addr()
+	})
+	token := n.register("alice", "pw")
+	blocks := n.extend(2, "alice")
+
+	anchorOf := func(index int) (api.BlockAnchorResponse, int) {
+		var resp api.BlockAnchorResponse
+		status := n.get(fmt.Sprintf("/api/blocks/%d/anchor", index), token, &resp)
+		return resp, status
+	}
+	if _, status := anchorOf(1); status != http.StatusNotFound {
+		t.Fatalf("before anchoring: status %d, want 404", status)
+	}
+
+	// One anchor covers every block after the genesis block
+	ctx := context.Background()
+	a, err := n.Handler.AnchorBlocks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := blockchain.AnchorRoot(blocks)
+	if a == nil || a.FromIndex != 1 || a.ToIndex != 2 || a.TxID != "00" {
+		t.Fatalf("unexpected anchor %+v", a)
+	}
+	if data := utxo.Data(); len(data) != 1 || !bytes.Equal(data[0], blockchain.AnchorPayload(1, 2, root)) {
+		t.Fatalf("anchored payloads %x", data)
+	}
+	for _, block := range n.Handler.Chain.Blocks()[1:] {
+		if block.AnchorTxID != "00" {
+			t.Fatalf("block %d anchored by %q", block.Index, block.AnchorTxID)
+		}
+	}
+
+	resp, status := anchorOf(2)
+	if status != http.StatusOK {
+		t.Fatalf("anchor: status %d", status)
+	}
+	want := api.BlockAnchorResponse{
+		BlockIndex: 2, BlockHash: blocks[1].Hash, FromIndex: 1, ToIndex: 2,
+		Root: hex.EncodeToString(root[:]), TxID: "00", Status: api.AnchorPending,
+	}
+	if resp != want {
+		t.Fatalf("pending anchor %+v, want %+v", resp, want)
+	}
+
+	// Once mined, the depth counts the UTXO block and those on top of it
+	utxo.Mine("00", 5, 7)
+	resp, _ = anchorOf(1)
+	if resp.Status != api.AnchorConfirmed || resp.Confirmations != 3 || resp.UTXOHeight != 5 || resp.UTXOBlockHash != "bb" {
+		t.Fatalf("confirmed anchor %+v", resp)
+	}
+
+	// Nothing new, nothing to anchor; a new block gets an anchor of its own
+	if a, err := n.Handler.AnchorBlocks(ctx); err != nil || a != nil {
+		t.Fatalf("anchor without new blocks: %+v, %v", a, err)
+	}
+	n.extend(1, "bob")
+	if a, err := n.Handler.AnchorBlocks(ctx); err != nil || a.FromIndex != 3 || a.ToIndex != 3 || a.TxID != "01" {
+		t.Fatalf("second anchor: %+v, %v", a, err)
+	}
+
+	// Anchors are local: a peer cannot send one along with a block, and a
+	// longer chain sharing our blocks keeps their anchors
+	peer := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), nil, "proof")
+	peer.AnchorTxID = "ff"
+	longer := append(n.Handler.Chain.Blocks(), peer)
+	if !n.Handler.Chain.Replace(longer) {
+		t.Fatal("longer chain not adopted")
+	}
+	if tip := n.Handler.Chain.LastBlock(); tip.AnchorTxID != "" {
+		t.Fatalf("adopted the peer's anchor %q", tip.AnchorTxID)
+	}
+	if block, _ := n.Handler.Chain.BlockAt(3); block.AnchorTxID != "01" {
+		t.Fatalf("block 3 lost its anchor, now %q", block.AnchorTxID)
+	}
+
+	// An unreachable anchor node is an upstream error
+	utxo.ln.Close()
+	if _, status := anchorOf(1); status != http.StatusBadGateway {
+		t.Fatalf("unreachable node: status %d, want 502", status)
+	}
+}
+
+func TestStaleAnchorsAreMadeAgain(t *testing.T) {
+	utxo := newFakeUTXONode(t)
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.Anchor.Node = utxo.Addr()
+	})
+	token := n.register("alice", "pw")
+	n.extend(2, "alice")
+	ctx := context.Background()
+	if _, err := n.Handler.AnchorBlocks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction the node no longer knows is anchored again, along with
+	// the blocks added since
+	utxo.Drop("00")
+	n.extend(1, "bob")
+	a, err := n.Handler.AnchorBlocks(ctx)
+	if err != nil || a == nil || a.FromIndex != 1 || a.ToIndex != 3 || a.TxID != "01" {
+		t.Fatalf("anchor after a dropped transaction: %+v, %v", a, err)
+	}
+
+	// A mined transaction carrying other data is not a confirmation
+	utxo.Mine("01", 5, 7)
+	utxo.Rewrite("01", []byte("something else"))
+	var resp api.BlockAnchorResponse
+	if status := n.get("/api/blocks/2/anchor", token, &resp); status != http.StatusOK || resp.Status != api.AnchorMismatch || resp.Confirmations != 0 {
+		t.Fatalf("mismatched anchor: status %d, %+v", status, resp)
+	}
+	if a, err := n.Handler.AnchorBlocks(ctx); err != nil || a == nil || a.FromIndex != 1 || a.ToIndex != 3 || a.TxID != "02" {
+		t.Fatalf("anchor after a mismatched transaction: %+v, %v", a, err)
+	}
+
+	// A sound anchor is kept
+	utxo.Mine("02", 6, 7)
+	if a, err := n.Handler.AnchorBlocks(ctx); err != nil || a != nil {
+		t.Fatalf("anchor without new blocks: %+v, %v", a, err)
+	}
+	if status := n.get("/api/blocks/2/anchor", token, &resp); status != http.StatusOK || resp.Status != api.AnchorConfirmed || resp.TxID != "02" {
+		t.Fatalf("anchor made again: status %d, %+v", status, resp)
+	}
+}
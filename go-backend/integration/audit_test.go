@@ -0,0 +1,93 @@
+package integration
+
+import (
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+)
+
+func TestAuditTrail(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai, func(cfg *config.Config) {
+		cfg.AdminUsers = []string{"root"}
+	})
+
+	admin := n.register("root", "pw")
+	alice := n.register("alice", "pw")
+	if _, status := n.login("alice", "wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password: status %d", status)
+	}
+	if _, status := n.mine(alice, "m", map[string][]byte{"a.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+
+	if status := n.get("/api/admin/audit", alice, nil); status != http.StatusForbidden {
+		t.Fatalf("audit as non-admin: status %d", status)
+	}
+
+	var resp struct {
+		Events []models.AuditEvent `json:"events"`
+	}
+	if status := n.get("/api/admin/audit?actor=alice", admin, &resp); status != http.StatusOK {
+		t.Fatalf("audit as admin: status %d", status)
+	}
+	seen := map[string]bool{}
+	for _, e := range resp.Events {
+		if e.Actor != "alice" {
+			t.Fatalf("actor filter returned %+v", e)
+		}
+		if e.RequestID == "" {
+			t.Fatalf("event without request ID: %+v", e)
+		}
+		seen[e.Action] = true
+	}
+	for _, action := range []string{models.AuditRegister, models.AuditLogin, models.AuditLoginFailed, models.AuditMine} {
+		if !seen[action] {
+			t.Errorf("no %s event for alice in %+v", action, resp.Events)
+		}
+	}
+
+	if status := n.get("/api/admin/audit?action="+models.AuditLoginFailed+"&limit=1", admin, &resp); status != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].Success {
+		t.Fatalf("filtered audit: status %d, %+v", status, resp.Events)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	req := n.request("GET", "/api/me", token, nil, "")
+	req.Header.Set("X-Request-ID", "client-chosen-id")
+	resp, err := n.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Request-ID"); got != "client-chosen-id" {
+		t.Fatalf("response request ID = %q", got)
+	}
+
+	// Unsafe IDs are replaced rather than echoed
+	req = n.request("GET", "/healthz", "", nil, "")
+	req.Header.Set("X-Request-ID", "bad id; <script>")
+	resp, err = n.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Request-ID"); got == "" || got == "bad id; <script>" {
+		t.Fatalf("unsafe request ID not replaced: %q", got)
+	}
+
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	ids := ai.RequestIDs()
+	if len(ids) != 1 || ids[0] == "" {
+		t.Fatalf("AI service saw request IDs %q", ids)
+	}
+}
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/bundle"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+)
+
+func TestBundleExportImport(t *testing.T) {
+	ai := newFakeAI(t)
+	source := newNode(t, ai)
+	token := source.register("alice", "pw")
+	for name, c := range map[string]color.Color{"m": color.Black, "n": color.White} {
+		if _, status := source.mine(token, name, map[string][]byte{"a.png": testPNG(c)}, nil); status != http.StatusCreated {
+			t.Fatalf("mine: status %d", status)
+		}
+	}
+
+	for _, format := range []bundle.Format{bundle.FormatJSONL, bundle.FormatCBOR} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			manifest, err := source.Handler.ExportBundle(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Blocks != 3 || manifest.Models != 2 || manifest.TipHash != source.Handler.Chain.LastBlock().Hash {
+				t.Fatalf("manifest %+v", manifest)
+			}
+
+			b, err := bundle.Read(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if problems := bundle.Verify(b); len(problems) > 0 {
+				t.Fatalf("fresh bundle has problems: %v", problems)
+			}
+
+			target := newNode(t, ai)
+			if err := target.Handler.ImportBundle(b); err != nil {
+				t.Fatal(err)
+			}
+			if err := target.Handler.ImportBundle(b); !errors.Is(err, handlers.ErrNodeNotEmpty) {
+				t.Fatalf("second import = %v, want ErrNodeNotEmpty", err)
+			}
+
+			// The import was saved and survives a restart
+			restarted := handlers.New(target.Handler.Repo, nil, target.Handler.DataDir)
+			if err := restarted.LoadState(); err != nil {
+				t.Fatal(err)
+			}
+			if got := restarted.Chain.LastBlock().Hash; got != manifest.TipHash {
+				t.Fatalf("imported chain ends in %s, want %s", got, manifest.TipHash)
+			}
+			if models, _ := restarted.Repo.ListModels(); len(models) != 2 {
+				t.Fatalf("imported %d models, want 2", len(models))
+			}
+		})
+	}
+}
+
+func TestFailedImportLeavesTheNodeEmpty(t *testing.T) {
+	ai := newFakeAI(t)
+	source := newNode(t, ai)
+	token := source.register("alice", "pw")
+	if _, status := source.mine(token, "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	var buf bytes.Buffer
+	if _, err := source.Handler.ExportBundle(&buf, bundle.FormatJSONL); err != nil {
+		t.Fatal(err)
+	}
+	b, err := bundle.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The state cannot be saved under a data directory that is a file
+	target := newNode(t, ai)
+	dataDir := target.Handler.DataDir
+	target.Handler.DataDir = filepath.Join(dataDir, "file")
+	if err := os.WriteFile(target.Handler.DataDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Handler.ImportBundle(b); err == nil {
+		t.Fatal("import without a place to save the state succeeded")
+	}
+	if got := target.Handler.Chain.Len(); got != 1 {
+		t.Fatalf("chain after a failed import holds %d blocks, want the genesis block", got)
+	}
+	if models, _ := target.Handler.Repo.ListModels(); len(models) != 0 {
+		t.Fatalf("failed import kept %d models", len(models))
+	}
+
+	target.Handler.DataDir = dataDir
+	if err := target.Handler.ImportBundle(b); err != nil {
+		t.Fatalf("import once the state can be saved: %v", err)
+	}
+}
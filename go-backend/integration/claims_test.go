@@ -0,0 +1,212 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+// signer signs a message as a registered key of its type does
+type signer func(message []byte) []byte
+
+func ed25519Signer(key ed25519.PrivateKey) signer {
+	return func(message []byte) []byte { return ed25519.Sign(key, message) }
+}
+
+// p256Signer signs the SHA-256 of the message as r | s
+func p256Signer(key *ecdsa.PrivateKey) signer {
+	return func(message []byte) []byte {
+		digest := sha256.Sum256(message)
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+}
+
+// keyChange answers a new challenge of the node with a request
+// registering public for username, signed by sign and, unless it is nil,
+// by current
+func (n *node) keyChange(token, username, keyType string, public []byte, sign, current signer) api.SigningKeyRequest {
+	n.t.Helper()
+
+	var challenge api.SigningKeyChallenge
+	if status := n.get("/api/me/signing-key/challenge", token, &challenge); status != http.StatusOK {
+		n.t.Fatalf("signing key challenge: status %d", status)
+	}
+	req := api.SigningKeyRequest{Type: keyType, PublicKey: hex.EncodeToString(public), Challenge: challenge.Challenge}
+	change := blockchain.KeyChange{Username: username, Key: keyType + ":" + req.PublicKey, Challenge: challenge.Challenge}
+	req.Signature = hex.EncodeToString(sign(change.Encode()))
+	if current != nil {
+		req.CurrentSignature = hex.EncodeToString(current(change.Encode()))
+	}
+	return req
+}
+
+// putSigningKey sends a key change
+func (n *node) putSigningKey(token string, req api.SigningKeyRequest) (api.SigningKeyResponse, int) {
+	n.t.Helper()
+
+	body, _ := json.Marshal(req)
+	var resp api.SigningKeyResponse
+	status := n.do(n.request("PUT", "/api/me/signing-key", token, bytes.NewReader(body), "application/json"), &resp)
+	return resp, status
+}
+
+// setSigningKey registers the first signing key of username
+func (n *node) setSigningKey(token, username, keyType string, public []byte, sign signer) (api.SigningKeyResponse, int) {
+	n.t.Helper()
+	return n.putSigningKey(token, n.keyChange(token, username, keyType, public, sign, nil))
+}
+
+// claimFor is the dataset claim of sender training modelName on images
+func claimFor(sender, modelName string, images ...[]byte) blockchain.DatasetClaim {
+	hashes := make([]string, len(images))
+	for i, image := range images {
+		hashes[i] = sha256Hex(image)
+	}
+	return blockchain.DatasetClaim{Sender: sender, ModelName: modelName, DatasetRoot: blockchain.DatasetRoot(hashes)}
+}
+
+func TestSignedDatasetClaims(t *testing.T) {
+	nodes, _ := newCluster(t, 2)
+	n := nodes[0]
+	alice := n.register("alice", "pw")
+	cat, dog := testPNG(color.RGBA{R: 10, A: 255}), testPNG(color.RGBA{R: 20, A: 255})
+	images := map[string][]byte{"cat.png": cat, "dog.png": dog}
+
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	sign := ed25519Signer(key)
+	sig := hex.EncodeToString(ed25519.Sign(key, claimFor("alice", "pets", cat, dog).Encode()))
+
+	// A signature needs a registered key
+	if _, status := n.mine(alice, "pets", images, map[string]string{"signature": sig}); status != http.StatusBadRequest {
+		t.Fatalf("signature without a key: status %d, want 400", status)
+	}
+	for _, bad := range []struct {
+		keyType string
+		key     []byte
+	}{
+		{"ed25519", pub[:31]},
+		{"p256", make([]byte, 64)}, // not on the curve
+		{"rsa", pub},
+	} {
+		if _, status := n.setSigningKey(alice, "alice", bad.keyType, bad.key, sign); status != http.StatusBadRequest {
+			t.Fatalf("%s key %x: status %d, want 400", bad.keyType, bad.key, status)
+		}
+	}
+	registered, status := n.setSigningKey(alice, "alice", "ed25519", pub, sign)
+	if status != http.StatusOK || registered.SigningKey != "ed25519:"+hex.EncodeToString(pub) {
+		t.Fatalf("register key: status %d, %+v", status, registered)
+	}
+	var lookup api.SigningKeyResponse
+	if status := n.get("/api/users/alice/signing-key", alice, &lookup); status != http.StatusOK || lookup != registered {
+		t.Fatalf("look up key: status %d, %+v", status, lookup)
+	}
+
+	// Once registered, uploads must carry a signature of exactly this
+	// claim. Other mismatches are left to the claim checks below: mining
+	// is rate limited.
+	other := hex.EncodeToString(ed25519.Sign(key, claimFor("alice", "dogs", cat, dog).Encode()))
+	if _, status := n.mine(alice, "pets", images, map[string]string{"signature": other}); status != http.StatusBadRequest {
+		t.Fatalf("signature for another model: status %d, want 400", status)
+	}
+	if claimFor("alice", "pets", cat).Verify(registered.SigningKey, sig) == nil {
+		t.Fatal("signature verifies for a claim on other images")
+	}
+	block, status := n.mine(alice, "pets", images, map[string]string{"signature": sig})
+	if status != http.StatusCreated {
+		t.Fatalf("signed mine: status %d", status)
+	}
+	for _, tx := range block.Transactions {
+		if tx.SenderKey != registered.SigningKey || tx.Signature != sig {
+			t.Fatalf("transaction not signed: %+v", tx)
+		}
+	}
+
+	// The claim travels with the block, so a peer verifies it on its own,
+	// and a block whose signature was tampered with is rejected
+	waitFor(t, "block to replicate", func() bool { return nodes[1].Handler.Chain.Len() == 2 })
+	replicated, _ := nodes[1].Handler.Chain.BlockAt(1)
+	if replicated.Hash != block.Hash || replicated.ValidateClaims() != nil {
+		t.Fatalf("replicated block does not verify: %+v", replicated)
+	}
+	forged := blockchain.GenerateTrainedBlock(block, append([]blockchain.Transaction(nil), block.Transactions...), *block.TrainingProof)
+	forged.Transactions[0].Sender = "mallory"
+	forged.Hash = blockchain.CalculateHash(forged)
+	if err := n.Handler.Chain.AddBlock(forged); err == nil {
+		t.Fatal("block with a claim signed for someone else accepted")
+	}
+
+	// P-256 keys are the X | Y of the point, as sample_blockchain wallets
+	// write them, and sign the SHA-256 of the claim as r | s
+	peer := nodes[1]
+	bob := peer.register("bob", "pw")
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	point := append(p256.X.FillBytes(make([]byte, 32)), p256.Y.FillBytes(make([]byte, 32))...)
+	if _, status := peer.setSigningKey(bob, "bob", "p256", point, p256Signer(p256)); status != http.StatusOK {
+		t.Fatalf("register p256 key: status %d", status)
+	}
+	bird := map[string][]byte{"bird.png": testPNG(color.RGBA{R: 30, A: 255})}
+	if _, status := peer.mine(bob, "birds", bird, nil); status != http.StatusBadRequest {
+		t.Fatalf("unsigned upload with a key: status %d, want 400", status)
+	}
+	p256Sig := hex.EncodeToString(p256Signer(p256)(claimFor("bob", "birds", bird["bird.png"]).Encode()))
+	block, status = peer.mine(bob, "birds", bird, map[string]string{"signature": p256Sig})
+	if status != http.StatusCreated || block.Transactions[0].SenderKey != "p256:"+hex.EncodeToString(point) {
+		t.Fatalf("p256 signed mine: status %d, %+v", status, block.Transactions)
+	}
+}
+
+func TestSigningKeyChangesAreSigned(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice := n.register("alice", "pw")
+	first, firstKey, _ := ed25519.GenerateKey(rand.Reader)
+	second, secondKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	// The challenge is the node's and the new key must sign it
+	req := n.keyChange(alice, "alice", "ed25519", first, ed25519Signer(firstKey), nil)
+	unknown := req
+	unknown.Challenge = strings.Repeat("0", len(req.Challenge))
+	if _, status := n.putSigningKey(alice, unknown); status != http.StatusBadRequest {
+		t.Fatalf("unknown challenge: status %d, want 400", status)
+	}
+	if _, status := n.putSigningKey(alice, n.keyChange(alice, "alice", "ed25519", first, ed25519Signer(otherKey), nil)); status != http.StatusBadRequest {
+		t.Fatalf("key change signed by another key: status %d, want 400", status)
+	}
+	if _, status := n.putSigningKey(alice, n.keyChange(alice, "bob", "ed25519", first, ed25519Signer(firstKey), nil)); status != http.StatusBadRequest {
+		t.Fatalf("key change signed for another user: status %d, want 400", status)
+	}
+	req = n.keyChange(alice, "alice", "ed25519", first, ed25519Signer(firstKey), nil)
+	if _, status := n.putSigningKey(alice, req); status != http.StatusOK {
+		t.Fatalf("register key: status %d", status)
+	}
+
+	// A challenge is answered once
+	if _, status := n.putSigningKey(alice, req); status != http.StatusBadRequest {
+		t.Fatalf("replayed key change: status %d, want 400", status)
+	}
+
+	// Replacing the key takes the current key's signature too
+	if _, status := n.putSigningKey(alice, n.keyChange(alice, "alice", "ed25519", second, ed25519Signer(secondKey), nil)); status != http.StatusBadRequest {
+		t.Fatalf("replacement without the current key: status %d, want 400", status)
+	}
+	if _, status := n.putSigningKey(alice, n.keyChange(alice, "alice", "ed25519", second, ed25519Signer(secondKey), ed25519Signer(otherKey))); status != http.StatusBadRequest {
+		t.Fatalf("replacement signed by another key: status %d, want 400", status)
+	}
+	resp, status := n.putSigningKey(alice, n.keyChange(alice, "alice", "ed25519", second, ed25519Signer(secondKey), ed25519Signer(firstKey)))
+	if status != http.StatusOK || resp.SigningKey != "ed25519:"+hex.EncodeToString(second) {
+		t.Fatalf("replace key: status %d, %+v", status, resp)
+	}
+}
@@ -0,0 +1,366 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+)
+
+const testSlot = 100 * time.Millisecond
+
+// newPoACluster starts a proof-of-authority cluster over the keys of the
+// first validators nodes. The other nodes get keys outside the set. All
+// nodes are peered with each other and "root" is an admin everywhere.
+func newPoACluster(t *testing.T, validators, followers int) ([]*node, []ed25519.PrivateKey) {
+	t.Helper()
+
+	keys := make([]ed25519.PrivateKey, validators+followers)
+	files := make([]string, len(keys))
+	var set []string
+	for i := range keys {
+		files[i] = filepath.Join(t.TempDir(), "validator.key")
+		key, err := consensus.WriteKey(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+		if i < validators {
+			set = append(set, consensus.PublicKeyHex(key))
+		}
+	}
+
+	ai := newFakeAI(t)
+	nodes := make([]*node, len(keys))
+	for i := range nodes {
+		nodes[i] = newNode(t, ai, func(cfg *config.Config) {
+			cfg.AdminUsers = []string{"root"}
+			cfg.Consensus = config.ConsensusConfig{
+				Mode:             config.ConsensusPoA,
+				Validators:       set,
+				ValidatorKeyFile: files[i],
+				SlotDuration:     config.Duration(testSlot),
+			}
+		})
+	}
+	for _, a := range nodes {
+		for _, b := range nodes {
+			if a != b {
+				if err := a.Handler.Peers.Add(b.Server.URL); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	return nodes, keys
+}
+
+// checkSealed fails unless block was produced by validator in one of its
+// slots and carries its signature
+func checkSealed(t *testing.T, a *consensus.Authority, set []string, block blockchain.Block, validator string) {
+	t.Helper()
+
+	if block.Version != blockchain.VersionValidator || block.Validator != validator {
+		t.Fatalf("block %d: version %d by %q, want a version 3 block by %s", block.Index, block.Version, block.Validator, validator)
+	}
+	if scheduled := consensus.Scheduled(set, a.Slot(block.Timestamp)); scheduled != validator {
+		t.Fatalf("block %d is in a slot of %s", block.Index, scheduled)
+	}
+	key, _ := consensus.ParsePublicKey(validator)
+	hash, _ := hex.DecodeString(block.Hash)
+	sig, _ := hex.DecodeString(block.Signature)
+	if !ed25519.Verify(key, hash, sig) {
+		t.Fatalf("block %d: signature does not verify", block.Index)
+	}
+}
+
+func TestPoAValidatorsTakeTurns(t *testing.T) {
+	nodes, keys := newPoACluster(t, 2, 1)
+	a := nodes[0].Handler.Authority
+	set := []string{consensus.PublicKeyHex(keys[0]), consensus.PublicKeyHex(keys[1])}
+
+	carol := nodes[2].register("carol", "pw")
+	var info api.ValidatorsResponse
+	if status := nodes[2].get("/api/validators", carol, &info); status != http.StatusOK {
+		t.Fatalf("validators: status %d", status)
+	}
+	if info.Mode != config.ConsensusPoA || info.SlotDurationMS != testSlot.Milliseconds() || len(info.Validators) != 2 ||
+		info.Validators[0] != set[0] || info.Self != consensus.PublicKeyHex(keys[2]) {
+		t.Fatalf("unexpected validators %+v", info)
+	}
+
+	// Each validator mines in its own slots
+	for i, n := range nodes[:2] {
+		token := n.register("alice", "pw")
+		block, status := n.mine(token, fmt.Sprintf("pets-%d", i), map[string][]byte{"cat.png": testPNG(color.RGBA{R: uint8(i), A: 255})}, nil)
+		if status != http.StatusCreated {
+			t.Fatalf("mine on validator %d: status %d", i, status)
+		}
+		checkSealed(t, a, set, block, set[i])
+		waitFor(t, "block to replicate", func() bool {
+			for _, n := range nodes {
+				if n.Handler.Chain.LastBlock().Hash != block.Hash {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	// A node outside the set refuses to mine before training
+	if _, status := nodes[2].mine(carol, "pets", map[string][]byte{"cat.png": testPNG(color.White)}, nil); status != http.StatusForbidden {
+		t.Fatalf("mine on a follower: status %d, want 403", status)
+	}
+
+	// A transaction sent to the follower reaches a validator, whose block
+	// producer includes it without anyone mining
+	tx := blockchain.Transaction{Sender: "carol", Receiver: "dave"}
+	if status := nodes[2].postJSON("/api/transaction", carol, tx, nil); status != http.StatusCreated {
+		t.Fatalf("transaction: status %d", status)
+	}
+	waitFor(t, "transaction to be produced into a block", func() bool {
+		block := nodes[2].Handler.Chain.LastBlock()
+		return len(block.Transactions) == 1 && block.Transactions[0] == tx
+	})
+	tip := nodes[2].Handler.Chain.LastBlock()
+	checkSealed(t, a, set, tip, tip.Validator)
+	if err := a.CheckChain(nodes[2].Handler.Chain.Blocks()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoARejectsUnscheduledBlocks(t *testing.T) {
+	nodes, keys := newPoACluster(t, 2, 0)
+	set := []string{consensus.PublicKeyHex(keys[0]), consensus.PublicKeyHex(keys[1])}
+	chain := nodes[0].Handler.Chain
+	genesis := chain.LastBlock()
+
+	sealers := make([]*consensus.Authority, len(keys))
+	for i, key := range keys {
+		var err error
+		if sealers[i], err = consensus.New(set, testSlot, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// blockIn returns a block on parent dated at the start of the latest
+	// past slot of validator i, sealed by sealer
+	now := sealers[0].Slot(time.Now())
+	blockIn := func(parent blockchain.Block, i int, back int64, sealer *consensus.Authority) blockchain.Block {
+		slot := now - back
+		for consensus.Scheduled(set, slot) != set[i] {
+			slot--
+		}
+		block := blockchain.GenerateBlock(parent, nil, "")
+		block.Timestamp = time.UnixMicro(slot * testSlot.Microseconds()).UTC()
+		if err := sealer.Seal(&block); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	// Unsigned blocks, as open mode produces them, are rejected
+	if err := chain.AddBlock(blockchain.GenerateBlock(genesis, nil, "proof")); !errors.Is(err, consensus.ErrNotScheduled) {
+		t.Fatalf("unsigned block: %v", err)
+	}
+	// So are blocks by the wrong validator for the slot
+	if err := chain.AddBlock(blockIn(genesis, 1, 10, sealers[0])); !errors.Is(err, consensus.ErrNotScheduled) {
+		t.Fatalf("block outside its validator's slot: %v", err)
+	}
+	// And blocks whose signature is not the named validator's
+	forged := blockIn(genesis, 0, 10, sealers[0])
+	forged.Signature = hex.EncodeToString(ed25519.Sign(keys[1], []byte(forged.Hash)))
+	if err := chain.AddBlock(forged); !errors.Is(err, consensus.ErrBadSignature) {
+		t.Fatalf("forged signature: %v", err)
+	}
+	// And blocks dated in a future slot
+	future := blockchain.GenerateBlock(genesis, nil, "")
+	future.Timestamp = time.Now().Add(time.Minute).UTC().Truncate(blockchain.TimestampPrecision)
+	for consensus.Scheduled(set, sealers[0].Slot(future.Timestamp)) != set[0] {
+		future.Timestamp = future.Timestamp.Add(testSlot)
+	}
+	sealers[0].Seal(&future)
+	if err := chain.AddBlock(future); !errors.Is(err, consensus.ErrNotScheduled) {
+		t.Fatalf("future block: %v", err)
+	}
+
+	// A block in its validator's slot is accepted, but not a second block
+	// in a slot that does not follow it
+	first := blockIn(genesis, 0, 10, sealers[0])
+	if err := chain.AddBlock(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock(blockIn(first, 1, 20, sealers[1])); !errors.Is(err, consensus.ErrNotScheduled) {
+		t.Fatalf("block in an earlier slot than its parent: %v", err)
+	}
+	if err := chain.AddBlock(blockIn(first, 1, 0, sealers[1])); err != nil {
+		t.Fatal(err)
+	}
+
+	// A longer chain from a peer must pass the same rules
+	bad := append(chain.Blocks(), blockIn(chain.LastBlock(), 1, 0, sealers[0]))
+	if chain.Replace(bad) {
+		t.Fatal("chain with a misscheduled block replaced ours")
+	}
+}
+
+func TestPoAValidatorSetChangesOnChain(t *testing.T) {
+	nodes, keys := newPoACluster(t, 1, 1)
+	validator, follower := nodes[0], nodes[1]
+	self, other := consensus.PublicKeyHex(keys[0]), consensus.PublicKeyHex(keys[1])
+	root := validator.register("root", "pw")
+	user := validator.register("alice", "pw")
+
+	change := func(n *node, token, action, key string) int {
+		return n.postJSON("/api/admin/validators", token, api.ValidatorChangeRequest{Action: action, PublicKey: key}, nil)
+	}
+	if status := change(validator, user, "add", other); status != http.StatusForbidden {
+		t.Fatalf("change by a non-admin: status %d, want 403", status)
+	}
+	for _, bad := range []struct{ action, key string }{
+		{"add", "not-a-key"},
+		{"add", self},      // already a validator
+		{"remove", other},  // not a validator
+		{"remove", self},   // would leave no validator
+		{"promote", other}, // unknown action
+	} {
+		if status := change(validator, root, bad.action, bad.key); status != http.StatusBadRequest {
+			t.Fatalf("%s %s: status %d, want 400", bad.action, bad.key, status)
+		}
+	}
+	// Only a validator may propose, and changes never travel as plain
+	// transactions
+	if status := change(follower, follower.register("root", "pw"), "add", other); status != http.StatusForbidden {
+		t.Fatalf("change on a follower: status %d, want 403", status)
+	}
+	tx := blockchain.Transaction{Sender: self, Receiver: blockchain.ReceiverAddValidator, ValidatorKey: other}
+	if status := validator.postJSON("/api/transaction", user, tx, nil); status != http.StatusBadRequest {
+		t.Fatalf("validator change as a transaction: status %d, want 400", status)
+	}
+
+	// The queued change goes into the validator's next block, with the key
+	// in lower case, and then applies on every node: the only validator is
+	// a majority
+	var resp api.ValidatorChangeResponse
+	if status := validator.postJSON("/api/admin/validators", root, api.ValidatorChangeRequest{Action: "add", PublicKey: strings.ToUpper(other)}, &resp); status != http.StatusAccepted {
+		t.Fatalf("add validator: status %d", status)
+	}
+	if resp.Transaction != tx {
+		t.Fatalf("queued %+v, want %+v", resp.Transaction, tx)
+	}
+	waitFor(t, "the change to apply on the follower", func() bool {
+		var info api.ValidatorsResponse
+		follower.get("/api/validators", user, &info)
+		return len(info.Validators) == 2 && info.Validators[1] == other
+	})
+	block, _ := follower.Handler.Chain.BlockAt(1)
+	if len(block.Transactions) != 1 || block.Transactions[0] != tx || block.Validator != self {
+		t.Fatalf("unexpected change block %+v", block)
+	}
+
+	// The former follower now produces blocks in its own slots
+	mined, status := follower.mine(follower.register("bob", "pw"), "pets", map[string][]byte{"cat.png": testPNG(color.White)}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine on the new validator: status %d", status)
+	}
+	checkSealed(t, follower.Handler.Authority, []string{self, other}, mined, other)
+	waitFor(t, "block to replicate", func() bool { return validator.Handler.Chain.LastBlock().Hash == mined.Hash })
+}
+
+func TestPoAValidatorChangesNeedAMajority(t *testing.T) {
+	nodes, keys := newPoACluster(t, 2, 1)
+	set := []string{consensus.PublicKeyHex(keys[0]), consensus.PublicKeyHex(keys[1])}
+	newcomer := consensus.PublicKeyHex(keys[2])
+	tokens := make([]string, len(nodes))
+	for i, n := range nodes {
+		tokens[i] = n.register("root", "pw")
+	}
+	propose := func(i int, action, key string) int {
+		return nodes[i].postJSON("/api/admin/validators", tokens[i], api.ValidatorChangeRequest{Action: action, PublicKey: key}, nil)
+	}
+	// The follower's view of the chain
+	follower := nodes[2].Handler
+	validators := func() []string { return follower.Authority.Validators(follower.Chain.Blocks()) }
+	proposals := func() []consensus.Proposal { return follower.Authority.Proposals(follower.Chain.Blocks()) }
+
+	// One validator of two is no majority: its proposal waits on the chain
+	if status := propose(0, "add", newcomer); status != http.StatusAccepted {
+		t.Fatalf("propose on validator 0: status %d", status)
+	}
+	waitFor(t, "the proposal to replicate", func() bool { return len(proposals()) == 1 })
+	var info api.ValidatorsResponse
+	if status := nodes[2].get("/api/validators", tokens[2], &info); status != http.StatusOK {
+		t.Fatalf("validators: status %d", status)
+	}
+	if len(info.Validators) != 2 || len(info.Proposals) != 1 || info.Proposals[0].Action != "add" || info.Proposals[0].PublicKey != newcomer ||
+		!slices.Equal(info.Proposals[0].Proposers, set[:1]) {
+		t.Fatalf("after one proposal %+v", info)
+	}
+	if status := propose(0, "add", newcomer); status != http.StatusBadRequest {
+		t.Fatalf("second proposal of one validator: status %d, want 400", status)
+	}
+	// Nor can a validator remove the other on its own
+	if status := propose(0, "remove", set[1]); status != http.StatusAccepted {
+		t.Fatalf("propose a removal: status %d", status)
+	}
+	waitFor(t, "the removal proposal to replicate", func() bool { return len(proposals()) == 2 })
+	if got := validators(); !slices.Equal(got, set) {
+		t.Fatalf("a single validator changed the set to %v", got)
+	}
+
+	// The second proposal makes a majority
+	if status := propose(1, "add", newcomer); status != http.StatusAccepted {
+		t.Fatalf("propose on validator 1: status %d", status)
+	}
+	waitFor(t, "the change to apply", func() bool { return len(validators()) == 3 })
+	if got, pending := validators(), proposals(); got[2] != newcomer || len(pending) != 1 || pending[0].Action != "remove" {
+		t.Fatalf("after the majority: validators %v, proposals %+v", got, pending)
+	}
+}
+
+func TestPoANormalisesValidatorKeysFromPeers(t *testing.T) {
+	key, other := newValidatorKey(t), newValidatorKey(t)
+	a, err := consensus.New([]string{strings.ToUpper(consensus.PublicKeyHex(key))}, testSlot, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer's block may carry the key in upper case; the set holds it as
+	// the chain names validators
+	genesis := blockchain.NewGenesisBlock()
+	tx := blockchain.Transaction{Sender: consensus.PublicKeyHex(key), Receiver: blockchain.ReceiverAddValidator, ValidatorKey: strings.ToUpper(consensus.PublicKeyHex(other))}
+	block := blockchain.GenerateBlock(genesis, []blockchain.Transaction{tx}, "")
+	if err := a.Seal(&block); err != nil {
+		t.Fatal(err)
+	}
+	chain := []blockchain.Block{genesis}
+	if err := a.CheckBlock(chain, block); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{consensus.PublicKeyHex(key), consensus.PublicKeyHex(other)}
+	if got := a.Validators(append(chain, block)); !slices.Equal(got, want) {
+		t.Fatalf("validators %v, want %v", got, want)
+	}
+}
+
+func newValidatorKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	key, err := consensus.WriteKey(filepath.Join(t.TempDir(), "validator.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
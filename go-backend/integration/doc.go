@@ -0,0 +1,6 @@
+// Package integration holds the end-to-end tests of go-backend. The tests
+// boot routes.NewHandler against in-memory SQLite databases, a fake AI
+// service and several nodes peered with each other; see harness_test.go.
+// Only the SQLite migrations run by default: set TEST_POSTGRES_DSN to a
+// scratch Postgres database to run the Postgres ones too.
+package integration
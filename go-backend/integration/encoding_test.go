@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+func TestCanonicalHashSurvivesRoundTrips(t *testing.T) {
+	genesis := blockchain.NewGenesisBlock()
+	txs := []blockchain.Transaction{{Sender: "alice", Receiver: "blockchain", Amount: 0.1, ImageHash: "ab"}}
+	block := blockchain.GenerateBlock(genesis, txs, "proof")
+	if block.Version != blockchain.CurrentVersion {
+		t.Fatalf("new block has version %d", block.Version)
+	}
+
+	// Another time zone, as a client or a database might render it
+	moved := block
+	moved.Timestamp = block.Timestamp.In(time.FixedZone("ICT", 7*3600))
+	data, _ := json.Marshal(moved)
+	var decoded blockchain.Block
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !blockchain.IsBlockValid(decoded, genesis) {
+		t.Fatalf("block no longer validates after a round trip through %s", data)
+	}
+
+	// Version 0 blocks keep their JSON hash
+	legacy := blockchain.Block{Index: 1, Timestamp: time.Now(), PrevHash: genesis.Hash, Transactions: txs, Proof: "proof"}
+	legacy.Hash = blockchain.CalculateHash(legacy)
+	if !blockchain.IsBlockValid(legacy, genesis) {
+		t.Fatal("version 0 block rejected")
+	}
+
+	// Versions never go back, and unknown versions never validate
+	downgrade := blockchain.Block{Index: 2, Timestamp: time.Now(), PrevHash: block.Hash}
+	downgrade.Hash = blockchain.CalculateHash(downgrade)
+	if blockchain.IsBlockValid(downgrade, block) {
+		t.Fatal("version 0 block accepted after a version 1 block")
+	}
+	future := blockchain.Block{Version: 99, Index: 1, PrevHash: genesis.Hash}
+	if blockchain.IsBlockValid(future, genesis) {
+		t.Fatal("block of an unknown version accepted")
+	}
+}
+
+func TestTrainingProofIsHashed(t *testing.T) {
+	genesis := blockchain.NewGenesisBlock()
+	proof := blockchain.TrainingProof{Owner: "alice", ModelName: "pets", WeightsHash: "ab", Seed: 7, SampleHash: "cd"}
+	block := blockchain.GenerateTrainedBlock(genesis, nil, proof)
+	if block.Proof != "cd" || !blockchain.IsBlockValid(block, genesis) {
+		t.Fatalf("trained block %+v does not validate", block)
+	}
+
+	// Any change to the proof changes the hash
+	tampered := block
+	tampered.TrainingProof = &blockchain.TrainingProof{Owner: "alice", ModelName: "pets", WeightsHash: "ab", Seed: 8, SampleHash: "cd"}
+	if blockchain.IsBlockValid(tampered, genesis) {
+		t.Fatal("block with a changed seed accepted")
+	}
+
+	// A proof the hash does not cover, or that disagrees with Proof, is
+	// rejected even when the hash matches
+	unhashed := block
+	unhashed.Version = blockchain.VersionCanonical
+	unhashed.Hash = blockchain.CalculateHash(unhashed)
+	if blockchain.IsBlockValid(unhashed, genesis) {
+		t.Fatal("version 1 block with a training proof accepted")
+	}
+	mismatched := block
+	mismatched.Proof = "other"
+	mismatched.Hash = blockchain.CalculateHash(mismatched)
+	if blockchain.IsBlockValid(mismatched, genesis) {
+		t.Fatal("block whose proof is not the sample hash accepted")
+	}
+}
+
+// TestDatasetRootVector checks DatasetRoot against the value the AI service
+// computes for the same digests
+func TestDatasetRootVector(t *testing.T) {
+	root := blockchain.DatasetRoot([]string{"cc", "aa", "bb"})
+	if got, want := hex.EncodeToString(root[:]), "90ffc5d6dd7e6ae4ec31a168508f048e4f964e3b3ac5ed2f4f23277d62a4f4e5"; got != want {
+		t.Fatalf("dataset root %s, want %s", got, want)
+	}
+}
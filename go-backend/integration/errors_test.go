@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+)
+
+func TestValidationErrorsListFields(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+
+	var resp api.ErrorResponse
+	status := n.postJSON("/auth/register", "", map[string]string{"email": "not-an-email"}, &resp)
+	if status != http.StatusBadRequest || resp.Code != "invalid_input" || resp.RequestID == "" {
+		t.Fatalf("register without fields: status %d, %+v", status, resp)
+	}
+	fields := map[string]string{}
+	for _, f := range resp.Fields {
+		fields[f.Field] = f.Message
+	}
+	if fields["username"] != "is required" || fields["password"] != "is required" || fields["email"] == "" {
+		t.Fatalf("field errors %+v", resp.Fields)
+	}
+
+	// Usernames and model names name storage directories, so one may not
+	// pass for an organisation's, the blob store or a path
+	resp = api.ErrorResponse{}
+	for _, username := range []string{"@acme", "..", ".blobs", "a/b"} {
+		status = n.postJSON("/auth/register", "", map[string]string{"username": username, "password": "pw"}, &resp)
+		if status != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "username" {
+			t.Fatalf("register %s: status %d, %+v", username, status, resp)
+		}
+	}
+
+	token := n.register("alice", "pw")
+	for _, modelName := range []string{"..", "a/b"} {
+		if _, status := n.mine(token, modelName, map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusBadRequest {
+			t.Fatalf("mine %q: status %d, want 400", modelName, status)
+		}
+	}
+
+	resp = api.ErrorResponse{}
+	status = n.postJSON("/api/orgs/none/members", token, map[string]string{"username": "bob", "role": "root"}, &resp)
+	if status != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "role" {
+		t.Fatalf("add member with unknown role: status %d, %+v", status, resp)
+	}
+
+	resp = api.ErrorResponse{}
+	if status := n.do(n.request("POST", "/api/mine", token, strings.NewReader("--x--\r\n"), "multipart/form-data; boundary=x"), &resp); status != http.StatusBadRequest || len(resp.Fields) != 3 {
+		t.Fatalf("mine with an empty form: status %d, %+v", status, resp)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	var resp api.ErrorResponse
+	status := n.postJSON("/auth/register", "", map[string]string{"username": "alice", "password": "pw"}, &resp)
+	if status != http.StatusConflict || resp.Code != "conflict" || len(resp.Fields) != 1 || resp.Fields[0].Field != "username" {
+		t.Fatalf("duplicate register: status %d, %+v", status, resp)
+	}
+	resp = api.ErrorResponse{}
+	status = n.postJSON("/auth/register", "", map[string]string{"username": "alice2", "password": "pw", "email": "alice@example.com"}, &resp)
+	if status != http.StatusConflict || len(resp.Fields) != 1 || resp.Fields[0].Field != "email" {
+		t.Fatalf("duplicate email: status %d, %+v", status, resp)
+	}
+	// Users without an email never collide on it
+	for _, username := range []string{"carol", "dave"} {
+		if status := n.postJSON("/auth/register", "", map[string]string{"username": username, "password": "pw"}, nil); status != http.StatusOK {
+			t.Fatalf("register %s without email: status %d", username, status)
+		}
+	}
+
+	if status := n.get("/api/nope", token, &resp); status != http.StatusNotFound || resp.Code != "not_found" {
+		t.Fatalf("unknown route: status %d, %+v", status, resp)
+	}
+
+	if status := n.get("/api/chain", "bogus", &resp); status != http.StatusUnauthorized || resp.Code != "unauthorized" {
+		t.Fatalf("bad token: status %d, %+v", status, resp)
+	}
+
+	// Upstream failures are reported without the AI service's own message
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.RGBA{A: 255})}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	ai.SetFailing(true)
+	if status := n.get("/api/generate-image?username=alice&model_name=m", token, &resp); status != http.StatusBadGateway || resp.Code != "upstream_error" {
+		t.Fatalf("generate during outage: status %d, %+v", status, resp)
+	}
+	if strings.Contains(resp.Error, "generator unavailable") {
+		t.Fatalf("AI service error leaked to the client: %q", resp.Error)
+	}
+}
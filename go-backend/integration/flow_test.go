@@ -0,0 +1,376 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+)
+
+func TestRegisterLoginMineBroadcastCheckImage(t *testing.T) {
+	nodes, ai := newCluster(t, 3)
+	miner := nodes[0]
+
+	token := miner.register("alice", "correct horse")
+
+	cat, dog := testPNG(color.RGBA{G: 255, A: 255}), testPNG(color.RGBA{B: 255, A: 255})
+	block, status := miner.mine(token, "pets", map[string][]byte{"cat.png": cat, "dog.png": dog}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	if block.Index != 1 || block.PrevHash != "genesis_hash" {
+		t.Fatalf("mined block %d on %q, want 1 on genesis", block.Index, block.PrevHash)
+	}
+	if want := expectedProof("pets", cat, dog); block.Proof != want {
+		t.Fatalf("proof %q, want %q", block.Proof, want)
+	}
+	if len(block.Transactions) != 2 || block.Transactions[0].Sender != "alice" {
+		t.Fatalf("unexpected transactions %+v", block.Transactions)
+	}
+	if ai.TrainCalls() != 1 {
+		t.Fatalf("AI service trained %d times, want 1", ai.TrainCalls())
+	}
+
+	// Every peer receives and accepts the block
+	for _, peer := range nodes[1:] {
+		waitFor(t, "block to reach "+peer.Server.URL, func() bool {
+			return peer.Handler.Chain.Len() == 2
+		})
+		if got := peer.Handler.Chain.LastBlock().Hash; got != block.Hash {
+			t.Fatalf("peer has block %s, want %s", got, block.Hash)
+		}
+	}
+
+	// A peer answers check-image from its replicated chain. The token was
+	// issued by the miner, the peers share its JWT secret.
+	resp, status := nodes[2].checkImage(token, cat)
+	if status != http.StatusOK || !resp.Trained || len(resp.Matches) != 1 || resp.Matches[0].BlockIndex != 1 {
+		t.Fatalf("check-image for a trained image: status %d, %+v", status, resp)
+	}
+	resp, status = nodes[2].checkImage(token, testPNG(color.White))
+	if status != http.StatusOK || resp.Trained {
+		t.Fatalf("check-image for an unknown image: status %d, %+v", status, resp)
+	}
+
+	// The model was recorded on the mining node only
+	var models struct {
+		Models []struct {
+			Name string `json:"name"`
+			Hash string `json:"hash"`
+		} `json:"models"`
+	}
+	if miner.get("/models", "", &models); len(models.Models) != 1 || models.Models[0].Hash != block.Hash {
+		t.Fatalf("models on miner: %+v", models)
+	}
+}
+
+func TestSecondBlockExtendsReplicatedChain(t *testing.T) {
+	nodes, _ := newCluster(t, 2)
+	token := nodes[0].register("alice", "pw")
+
+	first, _ := nodes[0].mine(token, "m1", map[string][]byte{"a.png": testPNG(color.Black)}, nil)
+	waitFor(t, "first block to replicate", func() bool { return nodes[1].Handler.Chain.Len() == 2 })
+
+	// Mining on the other node builds on the replicated block
+	token1 := nodes[1].register("bob", "pw")
+	second, status := nodes[1].mine(token1, "m2", map[string][]byte{"b.png": testPNG(color.White)}, nil)
+	if status != http.StatusCreated || second.PrevHash != first.Hash {
+		t.Fatalf("second block: status %d, prev %q, want %q", status, second.PrevHash, first.Hash)
+	}
+	waitFor(t, "second block to replicate back", func() bool { return nodes[0].Handler.Chain.Len() == 3 })
+
+	if !blockchain.IsChainValid(nodes[0].Handler.Chain.Blocks()) {
+		t.Fatal("replicated chain is not valid")
+	}
+}
+
+func TestLoginFailuresLockAccount(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	n.register("alice", "right")
+
+	for i := 0; i < 4; i++ {
+		if _, status := n.login("alice", "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i+1, status)
+		}
+	}
+	if _, status := n.login("alice", "wrong"); status != http.StatusTooManyRequests {
+		t.Fatalf("fifth failure: status %d, want 429", status)
+	}
+	// Locked even with the right password
+	if _, status := n.login("alice", "right"); status != http.StatusTooManyRequests {
+		t.Fatalf("login while locked: status %d, want 429", status)
+	}
+}
+
+func TestLockoutIsPerClientIP(t *testing.T) {
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.TrustedProxies = []string{"127.0.0.1"}
+	})
+	n.register("alice", "right")
+	login := func(ip, password string) int {
+		body, _ := json.Marshal(map[string]string{"username": "alice", "password": password})
+		req := n.request("POST", "/auth/login", "", bytes.NewReader(body), "application/json")
+		req.Header.Set("X-Forwarded-For", ip)
+		return n.do(req, nil)
+	}
+
+	for i := 0; i < 4; i++ {
+		if status := login("203.0.113.1", "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := login("203.0.113.1", "wrong"); status != http.StatusTooManyRequests {
+		t.Fatalf("fifth failure: status %d, want 429", status)
+	}
+	// The attacker's failures do not lock alice out elsewhere
+	if status := login("203.0.113.2", "right"); status != http.StatusOK {
+		t.Fatalf("login from another IP: status %d, want 200", status)
+	}
+	if status := login("203.0.113.1", "right"); status != http.StatusTooManyRequests {
+		t.Fatalf("login from the locked IP: status %d, want 429", status)
+	}
+}
+
+func TestForwardedForNeedsATrustedProxy(t *testing.T) {
+	limited := func(cfg *config.Config) {
+		cfg.RateLimits.Auth.PerIP = config.RateLimit{Rate: 0.001, Burst: 2}
+	}
+	login := func(n *node, ip string) int {
+		body, _ := json.Marshal(map[string]string{"username": "nobody", "password": "pw"})
+		req := n.request("POST", "/auth/login", "", bytes.NewReader(body), "application/json")
+		req.Header.Set("X-Forwarded-For", ip)
+		return n.do(req, nil)
+	}
+
+	// A client naming another IP each time still shares one bucket
+	direct := newNode(t, newFakeAI(t), limited)
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if status := login(direct, ip); status != http.StatusUnauthorized {
+			t.Fatalf("request %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := login(direct, "203.0.113.3"); status != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For: status %d, want 429", status)
+	}
+
+	// Behind a trusted proxy each client gets its own
+	proxied := newNode(t, newFakeAI(t), limited, func(cfg *config.Config) {
+		cfg.TrustedProxies = []string{"127.0.0.1"}
+	})
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		if status := login(proxied, ip); status != http.StatusUnauthorized {
+			t.Fatalf("client %d behind the proxy: status %d, want 401", i+1, status)
+		}
+	}
+}
+
+func TestUsersWithoutEmailDoNotCollide(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+
+	for _, username := range []string{"alice", "bob"} {
+		if status := n.postJSON("/auth/register", "", map[string]string{"username": username, "password": "pw"}, nil); status != http.StatusOK {
+			t.Fatalf("register %s without email: status %d", username, status)
+		}
+		if _, status := n.login(username, "pw"); status != http.StatusOK {
+			t.Fatalf("login %s: status %d", username, status)
+		}
+	}
+}
+
+func TestRateLimitsComeFromConfig(t *testing.T) {
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.RateLimits.API.PerUser = config.RateLimit{Rate: 0.001, Burst: 2}
+	})
+	alice := n.register("alice", "pw")
+
+	for i := 0; i < 2; i++ {
+		if status := n.get("/api/chain", alice, nil); status != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, status)
+		}
+	}
+	if status := n.get("/api/chain", alice, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429", status)
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+
+	if status := n.get("/api/chain", "", nil); status != http.StatusUnauthorized {
+		t.Fatalf("no token: status %d", status)
+	}
+	if status := n.get("/api/chain", "not-a-jwt", nil); status != http.StatusUnauthorized {
+		t.Fatalf("bad token: status %d", status)
+	}
+	if _, status := n.mine("", "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("mine without token: status %d", status)
+	}
+}
+
+func TestReceiveBlockRejectsInvalidBlocks(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	genesis := n.Handler.Chain.LastBlock()
+
+	valid := blockchain.GenerateBlock(genesis, []blockchain.Transaction{{Sender: "x", Receiver: "blockchain", ImageHash: "ab"}}, "proof")
+
+	tampered := valid
+	tampered.Proof = "forged"
+
+	wrongParent := blockchain.GenerateBlock(blockchain.Block{Index: 0, Hash: "other"}, nil, "proof")
+
+	skipped := blockchain.GenerateBlock(valid, nil, "proof")
+
+	for name, block := range map[string]blockchain.Block{
+		"tampered":     tampered,
+		"wrong parent": wrongParent,
+		"skips index":  skipped,
+	} {
+		if status := n.postJSON("/api/receive-block", "", block, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, status)
+		}
+	}
+	if n.Handler.Chain.Len() != 1 {
+		t.Fatalf("chain grew to %d after invalid blocks", n.Handler.Chain.Len())
+	}
+
+	if status := n.postJSON("/api/receive-block", "", valid, nil); status != http.StatusOK {
+		t.Fatalf("valid block: status %d", status)
+	}
+	// The same block twice no longer extends the chain
+	if status := n.postJSON("/api/receive-block", "", valid, nil); status != http.StatusBadRequest {
+		t.Fatalf("duplicate block: status %d, want 400", status)
+	}
+}
+
+func TestMineFailsWhileAIServiceIsDown(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	ai.SetFailing(true)
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusBadGateway {
+		t.Fatalf("mine during outage: status %d, want 502", status)
+	}
+	if n.Handler.Chain.Len() != 1 || n.Handler.Mempool.Len() != 0 {
+		t.Fatalf("outage left chain %d, mempool %d", n.Handler.Chain.Len(), n.Handler.Mempool.Len())
+	}
+
+	// After recovery the block holds only the new upload
+	ai.SetFailing(false)
+	block, status := n.mine(token, "m", map[string][]byte{"b.png": testPNG(color.White)}, nil)
+	if status != http.StatusCreated || len(block.Transactions) != 1 {
+		t.Fatalf("mine after recovery: status %d, %d transactions", status, len(block.Transactions))
+	}
+}
+
+func TestMineFailsWhenAIServiceUnreachable(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	ai.Close()
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusBadGateway {
+		t.Fatalf("mine with AI down: status %d, want 502", status)
+	}
+}
+
+func TestBroadcastToUnreachablePeerDoesNotFailMining(t *testing.T) {
+	nodes, _ := newCluster(t, 2)
+	nodes[1].Server.Close()
+
+	token := nodes[0].register("alice", "pw")
+	if _, status := nodes[0].mine(token, "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	time.Sleep(50 * time.Millisecond) // let the broadcast goroutine fail
+	if nodes[0].Handler.Chain.Len() != 2 {
+		t.Fatal("block was not kept locally")
+	}
+}
+
+func TestAddPeer(t *testing.T) {
+	n := newNode(t, newFakeAI(t), withAdmins("alice"))
+	token := n.register("alice", "pw")
+
+	// Only admins manage the node's peers
+	bob := n.register("bob", "pw")
+	if status := n.postJSON("/api/add-peer", bob, map[string]string{"peer": "127.0.0.1:9000"}, nil); status != http.StatusForbidden {
+		t.Fatalf("add peer as non-admin: status %d, want 403", status)
+	}
+
+	for _, peer := range []string{"127.0.0.1:9000", "http://127.0.0.1:9000/"} {
+		if status := n.postJSON("/api/add-peer", token, map[string]string{"peer": peer}, nil); status != http.StatusOK {
+			t.Fatalf("add %q: status %d", peer, status)
+		}
+	}
+	if peers := n.Handler.Peers.List(); len(peers) != 1 || peers[0] != "http://127.0.0.1:9000" {
+		t.Fatalf("peers %v, want one normalised entry", peers)
+	}
+
+	if status := n.postJSON("/api/add-peer", token, map[string]string{"peer": "ftp://nope"}, nil); status != http.StatusBadRequest {
+		t.Fatalf("invalid peer: status %d, want 400", status)
+	}
+}
+
+func TestOrganisationMining(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice := n.register("alice", "pw")
+	mallory := n.register("mallory", "pw")
+
+	if status := n.postJSON("/api/orgs", alice, map[string]string{"name": "acme"}, nil); status != http.StatusCreated {
+		t.Fatalf("create org: status %d", status)
+	}
+
+	img := map[string][]byte{"a.png": testPNG(color.Black)}
+	if _, status := n.mine(mallory, "shared", img, map[string]string{"organization": "acme"}); status != http.StatusForbidden {
+		t.Fatalf("non-member mining for org: status %d, want 403", status)
+	}
+
+	block, status := n.mine(alice, "shared", img, map[string]string{"organization": "acme"})
+	if status != http.StatusCreated {
+		t.Fatalf("member mining for org: status %d", status)
+	}
+	if tx := block.Transactions[0]; tx.Sender != "alice" || tx.Organization != "acme" {
+		t.Fatalf("transaction %+v, want sender alice on behalf of acme", tx)
+	}
+}
+
+func TestOrganisationOwnersKeepTheirRole(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice, bob := n.register("alice", "pw"), n.register("bob", "pw")
+	n.register("carol", "pw")
+	if status := n.postJSON("/api/orgs", alice, map[string]string{"name": "acme"}, nil); status != http.StatusCreated {
+		t.Fatalf("create org: status %d", status)
+	}
+	setRole := func(token, username, role string) int {
+		return n.postJSON("/api/orgs/acme/members", token, map[string]string{"username": username, "role": role}, nil)
+	}
+	if status := setRole(alice, "bob", "admin"); status != http.StatusOK {
+		t.Fatalf("add admin: status %d", status)
+	}
+
+	// An admin cannot demote an owner
+	if status := setRole(bob, "alice", "member"); status != http.StatusForbidden {
+		t.Fatalf("admin demoting the owner: status %d, want 403", status)
+	}
+	// nor can the last owner step down, the way they cannot leave
+	if status := setRole(alice, "alice", "admin"); status != http.StatusConflict {
+		t.Fatalf("last owner demoting themselves: status %d, want 409", status)
+	}
+	if status := n.do(n.request("DELETE", "/api/orgs/acme/members/alice", alice, nil, ""), nil); status != http.StatusConflict {
+		t.Fatalf("last owner leaving: status %d, want 409", status)
+	}
+
+	// With a second owner, an owner can be demoted
+	if status := setRole(alice, "carol", "owner"); status != http.StatusOK {
+		t.Fatalf("add owner: status %d", status)
+	}
+	if status := setRole(alice, "carol", "member"); status != http.StatusOK {
+		t.Fatalf("demote the second owner: status %d", status)
+	}
+}
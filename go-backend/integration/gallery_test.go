@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image/color"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/provenance"
+)
+
+func TestGeneratedImageGalleryAndProvenance(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice, bob, carol := n.register("alice", "pw"), n.register("bob", "pw"), n.register("carol", "pw")
+
+	block, status := n.mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.RGBA{R: 9, A: 255})}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+
+	// Every generated image is kept, named by its hash
+	generate := func(token, query string) (string, []byte) {
+		t.Helper()
+		resp, err := n.Server.Client().Do(n.request("GET", "/api/generate-image?"+query, token, nil, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("generate: status %d, %s", resp.StatusCode, data)
+		}
+		if sum := sha256.Sum256(data); resp.Header.Get("X-Image-Hash") != hex.EncodeToString(sum[:]) {
+			t.Fatalf("X-Image-Hash %s is not the hash of the image", resp.Header.Get("X-Image-Hash"))
+		}
+		return resp.Header.Get("X-Image-Hash"), data
+	}
+	var hashes []string
+	var images [][]byte
+	for range 3 {
+		hash, data := generate(bob, "username=alice&model_name=pets")
+		hashes, images = append(hashes, hash), append(images, data)
+	}
+
+	// The gallery pages newest first
+	var page api.GalleryResponse
+	if status := n.get("/api/model/images?username=alice&model_name=pets&limit=2", bob, &page); status != http.StatusOK || len(page.Images) != 2 {
+		t.Fatalf("first page: status %d, %+v", status, page)
+	}
+	if page.Model.Hash != block.Hash || page.Images[0].Hash != hashes[2] || page.Images[1].Hash != hashes[1] {
+		t.Fatalf("first page: %+v", page)
+	}
+	last := page.Images[1]
+	if last.RequestedBy != "bob" || last.BlockHash != block.Hash || last.Size != len(images[1]) {
+		t.Fatalf("gallery entry %+v", last)
+	}
+	before := strconv.FormatUint(uint64(last.ID), 10)
+	if status := n.get("/api/model/images?username=alice&model_name=pets&before="+before, bob, &page); status != http.StatusOK ||
+		len(page.Images) != 1 || page.Images[0].Hash != hashes[0] {
+		t.Fatalf("second page: status %d, %+v", status, page)
+	}
+
+	// An image leads back to its model, its block and the seed that
+	// reproduces it, watermarked with the block hash, manifest aside
+	var origin api.ImageProvenanceResponse
+	if status := n.get("/api/generated-images/"+hashes[1], carol, &origin); status != http.StatusOK {
+		t.Fatalf("provenance: status %d", status)
+	}
+	if origin.Model.Name != "pets" || origin.Model.CreatedBy != "alice" || origin.BlockIndex != block.Index || origin.Image != last {
+		t.Fatalf("provenance %+v", origin)
+	}
+	again, err := n.Handler.AI.Generate(context.Background(), "alice", "pets", block.Hash, origin.Image.Seed)
+	served, _ := provenance.Strip(images[1])
+	if err != nil || string(watermarked(t, again, block.Hash)) != string(served) {
+		t.Fatalf("seed %d does not reproduce the image: %v", origin.Image.Seed, err)
+	}
+	resp, err := n.Server.Client().Do(n.request("GET", "/api/generated-images/"+hashes[1]+"/content", carol, nil, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(content) != string(images[1]) {
+		t.Fatalf("content: status %d, %d bytes", resp.StatusCode, len(content))
+	}
+	if status := n.get("/api/generated-images/"+hex.EncodeToString(make([]byte, 32)), carol, nil); status != http.StatusNotFound {
+		t.Fatalf("unknown image: status %d, want 404", status)
+	}
+
+	// Images of an organisation's model stay within the organisation
+	if status := n.postJSON("/api/orgs", alice, map[string]string{"name": "lab"}, nil); status != http.StatusCreated {
+		t.Fatalf("create org: status %d", status)
+	}
+	if _, status := n.mine(alice, "secret", map[string][]byte{"a.png": testPNG(color.RGBA{R: 10, A: 255})}, map[string]string{"organization": "lab"}); status != http.StatusCreated {
+		t.Fatalf("mine for org: status %d", status)
+	}
+	orgHash, _ := generate(alice, "organization=lab&model_name=secret")
+	if status := n.get("/api/generated-images/"+orgHash, carol, nil); status != http.StatusForbidden {
+		t.Fatalf("org image provenance for a non-member: status %d, want 403", status)
+	}
+	if status := n.get("/api/generated-images/"+orgHash+"/content", carol, nil); status != http.StatusForbidden {
+		t.Fatalf("org image content for a non-member: status %d, want 403", status)
+	}
+	if status := n.get("/api/model/images?organization=lab&model_name=secret", carol, nil); status != http.StatusForbidden {
+		t.Fatalf("org gallery for a non-member: status %d, want 403", status)
+	}
+	if status := n.get("/api/generated-images/"+orgHash, alice, &origin); status != http.StatusOK || origin.Model.Organization != "lab" {
+		t.Fatalf("org image provenance for a member: status %d, %+v", status, origin)
+	}
+}
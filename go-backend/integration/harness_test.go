@@ -0,0 +1,553 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/aiclient"
+	"github.com/Kami0rn/ProjectCPE/go-backend/anchor"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/controllers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+	"github.com/Kami0rn/ProjectCPE/go-backend/routes"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testJWTSecret = "integration-test-secret"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	controllers.PasswordHashCost = bcrypt.MinCost
+	logging.Setup(io.Discard, slog.LevelError)
+	os.Exit(m.Run())
+}
+
+// fakeAI stands in for the Python service. /train answers with a training
+// proof whose sample hash is derived only from the model name and the
+// uploaded images, so tests can predict it; /regenerate reproduces it for
+// the training seed; /generate returns a small PNG depending on the seed.
+type fakeAI struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	failing     bool
+	brokenPNGs  bool // /generate answers with bytes that are not a PNG
+	trainCalls  int
+	requestIDs  []string             // X-Request-ID of each /train call
+	models      map[string]fakeModel // by owner/model_name
+	datasetRoot string               // reported instead of the real root when set
+}
+
+// fakeModel is what fakeAI keeps of a trained model
+type fakeModel struct {
+	weightsHash string
+	seed        int64
+	sampleHash  string // for seed
+}
+
+// fakeSeed is the seed of every fakeAI proof sample
+const fakeSeed = 42
+
+func newFakeAI(t *testing.T) *fakeAI {
+	ai := &fakeAI{models: map[string]fakeModel{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/train", ai.train)
+	mux.HandleFunc("/regenerate", ai.regenerate)
+	mux.HandleFunc("/generate", ai.generate)
+	ai.Server = httptest.NewServer(mux)
+	t.Cleanup(ai.Close)
+	return ai
+}
+
+// SetFailing makes every endpoint answer 500, simulating an outage
+func (ai *fakeAI) SetFailing(failing bool) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	ai.failing = failing
+}
+
+// SetBrokenPNGs makes /generate answer with a truncated PNG, one the node
+// cannot watermark
+func (ai *fakeAI) SetBrokenPNGs(broken bool) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	ai.brokenPNGs = broken
+}
+
+// SetDatasetRoot makes /train report root as the dataset root, simulating a
+// service that trained on something else than it was sent
+func (ai *fakeAI) SetDatasetRoot(root string) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	ai.datasetRoot = root
+}
+
+// ReplaceWeights swaps the stored weights of a trained model, as if it had
+// been retrained or tampered with after its block was mined
+func (ai *fakeAI) ReplaceWeights(owner, modelName string) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	key := owner + "/" + modelName
+	model := ai.models[key]
+	model.weightsHash = sha256Hex([]byte("replaced " + model.weightsHash))
+	model.sampleHash = sha256Hex([]byte("replaced " + model.sampleHash))
+	ai.models[key] = model
+}
+
+func (ai *fakeAI) TrainCalls() int {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	return ai.trainCalls
+}
+
+func (ai *fakeAI) RequestIDs() []string {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	return append([]string(nil), ai.requestIDs...)
+}
+
+func (ai *fakeAI) isFailing() bool {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	return ai.failing
+}
+
+func (ai *fakeAI) train(w http.ResponseWriter, r *http.Request) {
+	ai.mu.Lock()
+	ai.trainCalls++
+	ai.requestIDs = append(ai.requestIDs, r.Header.Get("X-Request-ID"))
+	ai.mu.Unlock()
+
+	if ai.isFailing() {
+		http.Error(w, `{"error": "CUDA out of memory"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var images [][]byte
+	var imageHashes []string
+	for _, header := range r.MultipartForm.File["images"] {
+		f, err := header.Open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		images = append(images, data)
+		imageHashes = append(imageHashes, sha256Hex(data))
+	}
+
+	modelName := r.FormValue("model_name")
+	sample := expectedProof(modelName, images...)
+	model := fakeModel{weightsHash: sha256Hex([]byte("weights " + sample)), seed: fakeSeed, sampleHash: sample}
+	root := blockchain.DatasetRoot(imageHashes)
+	epochs, _ := strconv.Atoi(r.FormValue("epochs"))
+
+	ai.mu.Lock()
+	ai.models[r.FormValue("username")+"/"+modelName] = model
+	datasetRoot := hex.EncodeToString(root[:])
+	if ai.datasetRoot != "" {
+		datasetRoot = ai.datasetRoot
+	}
+	ai.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":  "Training complete",
+		"epochs":   epochs,
+		"ai_proof": sample,
+		"training_proof": map[string]any{
+			"weights_hash": model.weightsHash,
+			"dataset_root": datasetRoot,
+			"seed":         model.seed,
+			"hyperparameters": map[string]any{
+				"epochs": epochs, "latent_dim": 100, "batch_size": 64, "lr": 0.0001, "n_critic": 5, "lambda_gp": 10,
+			},
+			"sample_hash": sample,
+		},
+	})
+}
+
+func (ai *fakeAI) regenerate(w http.ResponseWriter, r *http.Request) {
+	if ai.isFailing() {
+		http.Error(w, `{"error": "generator unavailable"}`, http.StatusInternalServerError)
+		return
+	}
+	seed, err := strconv.ParseInt(r.FormValue("seed"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ai.mu.Lock()
+	model, ok := ai.models[r.FormValue("username")+"/"+r.FormValue("model_name")]
+	ai.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"error": "Generator model not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Another seed gives another sample
+	sample := model.sampleHash
+	if seed != model.seed {
+		sample = sha256Hex([]byte(fmt.Sprintf("%s %d", sample, seed)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"weights_hash": model.weightsHash,
+		"sample_hash":  sample,
+	})
+}
+
+func (ai *fakeAI) generate(w http.ResponseWriter, r *http.Request) {
+	if ai.isFailing() {
+		http.Error(w, `{"error": "generator unavailable"}`, http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("block_hash") == "" {
+		http.Error(w, `{"error": "username, model_name, and block_hash are required"}`, http.StatusBadRequest)
+		return
+	}
+	seed, err := strconv.ParseInt(r.FormValue("seed"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Another seed gives another image
+	data := testPNG(color.RGBA{R: 200, G: uint8(seed), B: uint8(seed >> 8), A: 255})
+	ai.mu.Lock()
+	if ai.brokenPNGs {
+		data = data[:len(data)/2]
+	}
+	ai.mu.Unlock()
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+// expectedProof is the sample hash fakeAI returns for a training run
+func expectedProof(modelName string, images ...[]byte) string {
+	hashes := make([]string, 0, len(images))
+	for _, img := range images {
+		hashes = append(hashes, sha256Hex(img))
+	}
+	sort.Strings(hashes)
+
+	h := sha256.New()
+	h.Write([]byte(modelName))
+	for _, hash := range hashes {
+		h.Write([]byte(hash))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testPNG returns a distinct 8x8 image per colour
+func testPNG(c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// node is one go-backend instance with its own in-memory database
+type node struct {
+	t       *testing.T
+	Handler *handlers.Handler
+	Server  *httptest.Server
+}
+
+// withAdmins configures the admin users of a node
+func withAdmins(usernames ...string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.AdminUsers = usernames
+	}
+}
+
+// newNode starts a node; configure may adjust its config before validation
+func newNode(t *testing.T, ai *fakeAI, configure ...func(*config.Config)) *node {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.JWTSecret = testJWTSecret
+	cfg.AIServiceURL = ai.URL
+	cfg.DataDir = t.TempDir()
+	cfg.Database = config.DatabaseConfig{Driver: config.DriverSQLite, DSN: ":memory:"}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	h := handlers.New(database.NewRepository(db), aiclient.New(cfg.AIServiceURL), cfg.DataDir)
+	authority, err := consensus.FromConfig(cfg.Consensus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authority != nil {
+		h.UseAuthority(authority)
+		ctx, cancel := context.WithCancel(context.Background())
+		go h.RunBlockProducer(ctx)
+		t.Cleanup(cancel)
+	}
+	h.UseRewards(rewards.FromConfig(cfg.Rewards))
+	if err := h.LoadNodeKey(cfg.NodeKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	h.TrustedNodeKeys = cfg.TrustedNodeKeys
+	if cfg.Anchor.Node != "" {
+		h.Anchors = anchor.New(cfg.Anchor.Node)
+	}
+	server := httptest.NewServer(routes.NewHandler(&cfg, h))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		// Stop the replication goroutines without waiting for peers
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		h.Peers.Shutdown(ctx)
+	})
+
+	return &node{t: t, Handler: h, Server: server}
+}
+
+// newCluster starts n nodes sharing one fake AI, each peered with all others
+func newCluster(t *testing.T, n int) ([]*node, *fakeAI) {
+	t.Helper()
+
+	ai := newFakeAI(t)
+	nodes := make([]*node, n)
+	for i := range nodes {
+		nodes[i] = newNode(t, ai)
+	}
+	for _, a := range nodes {
+		for _, b := range nodes {
+			if a != b {
+				if err := a.Handler.Peers.Add(b.Server.URL); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	return nodes, ai
+}
+
+// extend appends n blocks to the node's chain directly, each holding one
+// transaction from sender
+func (n *node) extend(count int, sender string) []blockchain.Block {
+	n.t.Helper()
+
+	var blocks []blockchain.Block
+	for i := 0; i < count; i++ {
+		tx := blockchain.Transaction{Sender: sender, Receiver: "blockchain", ImageHash: fmt.Sprintf("%s-%d", sender, i)}
+		block := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{tx}, "proof")
+		if err := n.Handler.Chain.AddBlock(block); err != nil {
+			n.t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// do sends a request and decodes a JSON response into out when out is non-nil
+func (n *node) do(req *http.Request, out any) int {
+	n.t.Helper()
+
+	resp, err := n.Server.Client().Do(req)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			n.t.Fatalf("%s %s: decode %q: %v", req.Method, req.URL.Path, body, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (n *node) request(method, path, token string, body io.Reader, contentType string) *http.Request {
+	n.t.Helper()
+
+	req, err := http.NewRequest(method, n.Server.URL+path, body)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func (n *node) postJSON(path, token string, payload, out any) int {
+	n.t.Helper()
+
+	data, _ := json.Marshal(payload)
+	return n.do(n.request("POST", path, token, bytes.NewReader(data), "application/json"), out)
+}
+
+func (n *node) get(path, token string, out any) int {
+	n.t.Helper()
+	return n.do(n.request("GET", path, token, nil, ""), out)
+}
+
+// metrics returns the node's Prometheus exposition
+func (n *node) metrics() string {
+	n.t.Helper()
+
+	resp, err := n.Server.Client().Get(n.Server.URL + "/metrics")
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// register creates the user and returns a session token
+func (n *node) register(username, password string) string {
+	n.t.Helper()
+
+	status := n.postJSON("/auth/register", "", map[string]string{
+		"username": username,
+		"password": password,
+		"email":    username + "@example.com",
+	}, nil)
+	if status != http.StatusOK {
+		n.t.Fatalf("register %s: status %d", username, status)
+	}
+
+	token, status := n.login(username, password)
+	if status != http.StatusOK {
+		n.t.Fatalf("login %s: status %d", username, status)
+	}
+	return token
+}
+
+func (n *node) login(username, password string) (string, int) {
+	n.t.Helper()
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	status := n.postJSON("/auth/login", "", map[string]string{
+		"username": username,
+		"password": password,
+	}, &resp)
+	return resp.Token, status
+}
+
+// mine uploads images for training; fields adds extra form fields
+func (n *node) mine(token, modelName string, images map[string][]byte, fields map[string]string) (blockchain.Block, int) {
+	n.t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		part, _ := writer.CreateFormFile("images", name)
+		part.Write(images[name])
+	}
+	writer.WriteField("model_name", modelName)
+	writer.WriteField("epochs", "1")
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	writer.Close()
+
+	var block blockchain.Block
+	status := n.do(n.request("POST", "/api/mine", token, &body, writer.FormDataContentType()), &block)
+	return block, status
+}
+
+type checkImageResponse struct {
+	Trained bool `json:"trained"`
+	Matches []struct {
+		BlockIndex int `json:"block_index"`
+	} `json:"matches"`
+}
+
+func (n *node) checkImage(token string, data []byte) (checkImageResponse, int) {
+	n.t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "query.png")
+	part.Write(data)
+	writer.Close()
+
+	var resp checkImageResponse
+	status := n.do(n.request("POST", "/api/check-image", token, &body, writer.FormDataContentType()), &resp)
+	return resp, status
+}
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
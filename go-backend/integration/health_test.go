@@ -0,0 +1,64 @@
+package integration
+
+import (
+	"image/color"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHealthAndReadiness(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+
+	if status := n.get("/healthz", "", nil); status != http.StatusOK {
+		t.Fatalf("healthz: status %d", status)
+	}
+
+	var ready struct {
+		Ready  bool              `json:"ready"`
+		Checks map[string]string `json:"checks"`
+	}
+	if status := n.get("/readyz", "", &ready); status != http.StatusOK || !ready.Ready {
+		t.Fatalf("readyz: status %d, %+v", status, ready)
+	}
+
+	ai.Close()
+	if status := n.get("/readyz", "", &ready); status != http.StatusServiceUnavailable || ready.Checks["ai_service"] == "ok" {
+		t.Fatalf("readyz with AI down: status %d, %+v", status, ready)
+	}
+	if ready.Checks["database"] != "ok" || ready.Checks["chain"] != "ok" {
+		t.Fatalf("readyz with AI down reported other failures: %+v", ready.Checks)
+	}
+}
+
+func TestMetricsExposeNodeState(t *testing.T) {
+	nodes, ai := newCluster(t, 2)
+	token := nodes[0].register("alice", "pw")
+	nodes[0].mine(token, "m", map[string][]byte{"a.png": testPNG(color.Black)}, nil)
+
+	ai.SetFailing(true)
+	nodes[0].mine(token, "n", map[string][]byte{"b.png": testPNG(color.White)}, nil)
+
+	resp, err := http.Get(nodes[0].Server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	for _, want := range []string{
+		"blockchain_height 2",
+		"mempool_transactions 0",
+		"peers 1",
+		`ai_service_errors_total{endpoint="train"} 1`,
+		"training_job_duration_seconds_count 2",
+		`http_request_duration_seconds_count{method="POST",route="/api/mine",status="201"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+func setLicence(n *node, token string, req api.LicenceRequest) (api.LicenceChangeResponse, int) {
+	n.t.Helper()
+	body, _ := json.Marshal(req)
+	var resp api.LicenceChangeResponse
+	status := n.do(n.request("PUT", "/api/model/licence", token, bytes.NewReader(body), "application/json"), &resp)
+	return resp, status
+}
+
+func TestModelLicenceAndUsage(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice, bob := n.register("alice", "pw"), n.register("bob", "pw")
+	mine := func(modelName string, shade uint8) {
+		t.Helper()
+		if _, status := n.mine(alice, modelName, map[string][]byte{"a.png": testPNG(color.RGBA{B: shade, A: 255})}, nil); status != http.StatusCreated {
+			t.Fatalf("mine %s: status %d", modelName, status)
+		}
+	}
+	generate := func(requestID string) {
+		t.Helper()
+		req := n.request("GET", "/api/generate-image?username=alice&model_name=pets", bob, nil, "")
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		if status := n.do(req, nil); status != http.StatusOK {
+			t.Fatalf("generate: status %d", status)
+		}
+	}
+	mine("pets", 1)
+
+	// Only the owner sets terms, and they must name a licence
+	ncBy := blockchain.Licence{ID: "CC-BY-NC-4.0", Attribution: true}
+	if _, status := setLicence(n, bob, api.LicenceRequest{ModelName: "pets", Licence: ncBy}); status != http.StatusNotFound {
+		t.Fatalf("licence for someone else's model: status %d, want 404", status)
+	}
+	if _, status := setLicence(n, alice, api.LicenceRequest{ModelName: "pets", Licence: blockchain.Licence{ID: "any use!"}}); status != http.StatusBadRequest {
+		t.Fatalf("invalid licence: status %d, want 400", status)
+	}
+	change, status := setLicence(n, alice, api.LicenceRequest{ModelName: "pets", Licence: ncBy})
+	if status != http.StatusAccepted || !change.Transaction.IsLicence() || change.Transaction.Sender != "alice" {
+		t.Fatalf("set licence: status %d, %+v", status, change)
+	}
+	var licence api.LicenceResponse
+	if status := n.get("/api/model/licence?username=alice&model_name=pets", bob, &licence); status != http.StatusOK ||
+		licence.Licence != nil || licence.Pending == nil || *licence.Pending != ncBy {
+		t.Fatalf("pending licence: status %d, %+v", status, licence)
+	}
+
+	// Generating before the licence is on the chain records no terms
+	generate("")
+	mine("more", 2)
+	licence = api.LicenceResponse{}
+	if status := n.get("/api/model/licence?username=alice&model_name=pets", bob, &licence); status != http.StatusOK ||
+		licence.Licence == nil || *licence.Licence != ncBy || licence.Pending != nil {
+		t.Fatalf("licence on the chain: status %d, %+v", status, licence)
+	}
+	var model api.ModelResponse
+	if status := n.postJSON("/api/model", alice, api.ModelRequest{Username: "alice", ModelName: "pets"}, &model); status != http.StatusOK ||
+		model.Licence == nil || model.Licence.ID != ncBy.ID {
+		t.Fatalf("model: status %d, licence %+v", status, model.Licence)
+	}
+
+	// Every generated image is recorded, whatever X-Request-ID the client
+	// sends
+	generate("gen-1")
+	generate("gen-1")
+	var usage api.ModelUsageResponse
+	if status := n.get("/api/model/usage?model_name=pets", alice, &usage); status != http.StatusOK || usage.Images != 1 || usage.Pending != 2 {
+		t.Fatalf("usage before mining: status %d, %+v", status, usage)
+	}
+	mine("third", 3)
+	if status := n.get("/api/model/usage?model_name=pets", alice, &usage); status != http.StatusOK {
+		t.Fatalf("usage: status %d", status)
+	}
+	want := []api.ModelUsage{{User: "bob", Licence: "", Images: 1}, {User: "bob", Licence: ncBy.ID, Images: 2}}
+	if usage.Images != 3 || usage.Pending != 0 || len(usage.Usage) != len(want) || usage.Usage[0] != want[0] || usage.Usage[1] != want[1] {
+		t.Fatalf("usage: %+v, want %+v", usage, want)
+	}
+	if status := n.get("/api/model/usage?model_name=pets", bob, &usage); status != http.StatusNotFound {
+		t.Fatalf("usage of someone else's model: status %d, want 404", status)
+	}
+
+	// Records go through the endpoints above, and the chain refuses
+	// malformed ones
+	forged := blockchain.Transaction{Sender: "bob", Receiver: blockchain.ReceiverLicence, Model: licence.Model, Licence: &blockchain.Licence{ID: "MIT", Commercial: true}}
+	if status := n.postJSON("/api/transaction", bob, forged, nil); status != http.StatusBadRequest {
+		t.Fatalf("licence through /api/transaction: status %d, want 400", status)
+	}
+	bare := blockchain.Transaction{Sender: "alice", Receiver: blockchain.ReceiverLicence, Model: licence.Model}
+	block := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{bare}, "proof")
+	if err := n.Handler.Chain.AddBlock(block); !errors.Is(err, blockchain.ErrInvalidBlock) {
+		t.Fatalf("licence record without terms: %v", err)
+	}
+}
+
+func TestPeerBlockClearsPendingLicence(t *testing.T) {
+	ai := newFakeAI(t)
+	n, peer := newNode(t, ai), newNode(t, ai)
+	alice := n.register("alice", "pw")
+	if _, status := n.mine(alice, "pets", map[string][]byte{"a.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	change, status := setLicence(n, alice, api.LicenceRequest{ModelName: "pets", Licence: blockchain.Licence{ID: "MIT", Commercial: true}})
+	if status != http.StatusAccepted {
+		t.Fatalf("set licence: status %d", status)
+	}
+
+	// The copy in a peer's block is decoded afresh, its Licence a pointer of
+	// its own, and still takes the record out of the mempool
+	block := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{change.Transaction}, "proof")
+	if _, err := peer.Handler.PeerClient.AnnounceBlock(context.Background(), n.Server.URL, block); err != nil {
+		t.Fatal(err)
+	}
+	if n.Handler.Chain.LastBlock().Hash != block.Hash {
+		t.Fatal("peer block not accepted")
+	}
+	if pending := n.Handler.Mempool.Pending(); len(pending) != 0 {
+		t.Fatalf("mempool after the licence was mined by a peer: %+v", pending)
+	}
+}
+
+func TestLicencesComeFromTheModelOwner(t *testing.T) {
+	ai := newFakeAI(t)
+	n, peer := newNode(t, ai), newNode(t, ai)
+	alice := n.register("alice", "pw")
+	model, status := n.mine(alice, "pets", map[string][]byte{"a.png": testPNG(color.RGBA{G: 7, A: 255})}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+
+	// A peer relaying a licence for someone else's model, in their name or
+	// in that of an organisation, is refused
+	ctx := context.Background()
+	terms := &blockchain.Licence{ID: "MIT", Commercial: true}
+	forged := blockchain.Transaction{Sender: "bob", Receiver: blockchain.ReceiverLicence, Model: model.Hash, Licence: terms}
+	asOrg := blockchain.Transaction{Sender: "alice", Receiver: blockchain.ReceiverLicence, Organization: "lab", Model: model.Hash, Licence: terms}
+	for _, tx := range []blockchain.Transaction{forged, asOrg} {
+		if err := peer.Handler.PeerClient.RelayTransaction(ctx, n.Server.URL, tx); err == nil {
+			t.Fatalf("relayed licence %+v taken", tx)
+		}
+	}
+	if pending := n.Handler.Mempool.Pending(); len(pending) != 0 {
+		t.Fatalf("mempool after forged licences: %+v", pending)
+	}
+
+	// and so is a block holding one
+	block := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{forged}, "proof")
+	if err := n.Handler.Chain.AddBlock(block); !errors.Is(err, blockchain.ErrNotModelOwner) {
+		t.Fatalf("block with a forged licence: %v", err)
+	}
+
+	// The owner's licence is relayed
+	owned := blockchain.Transaction{Sender: "alice", Receiver: blockchain.ReceiverLicence, Model: model.Hash, Licence: terms}
+	if err := peer.Handler.PeerClient.RelayTransaction(ctx, n.Server.URL, owned); err != nil {
+		t.Fatalf("relayed licence of the owner: %v", err)
+	}
+	if pending := n.Handler.Mempool.Pending(); len(pending) != 1 || pending[0].Sender != "alice" {
+		t.Fatalf("mempool after the owner's licence: %+v", pending)
+	}
+}
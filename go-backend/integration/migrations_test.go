@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+	"gorm.io/gorm"
+)
+
+// openDatabase opens cfg's database with every migration applied
+func openDatabase(t *testing.T, cfg config.DatabaseConfig) *gorm.DB {
+	t.Helper()
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testMigrationsRoundTrip reverts every migration of db and applies them
+// again
+func testMigrationsRoundTrip(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	migrations, err := database.LoadMigrations(db.Dialector.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := database.MigrateDown(db, len(migrations)); err != nil || reverted != len(migrations) {
+		t.Fatalf("down: reverted %d of %d: %v", reverted, len(migrations), err)
+	}
+	if applied, err := database.MigrateUp(db); err != nil || applied != len(migrations) {
+		t.Fatalf("up again: applied %d of %d: %v", applied, len(migrations), err)
+	}
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	testMigrationsRoundTrip(t, openDatabase(t, config.DatabaseConfig{Driver: config.DriverSQLite, DSN: ":memory:"}))
+}
+
+// The Postgres migrations only run against a server named by
+// TEST_POSTGRES_DSN, whose tables the test drops; without one they are not
+// covered
+func TestPostgresMigrationsRoundTrip(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	testMigrationsRoundTrip(t, openDatabase(t, config.DatabaseConfig{Driver: config.DriverPostgres, DSN: dsn}))
+}
+
+func TestNullEmailsRevertOnlyWhileUnique(t *testing.T) {
+	db := openDatabase(t, config.DatabaseConfig{Driver: config.DriverSQLite, DSN: ":memory:"})
+	repo := database.NewRepository(db)
+	count := func(where string) int64 {
+		var n int64
+		if err := db.Raw("SELECT COUNT(*) FROM users WHERE " + where).Scan(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// One user without an email goes back to ''
+	if err := repo.CreateUser(&models.User{Username: "alice", PasswordHash: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.MigrateDown(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if empty := count("email = ''"); empty != 1 {
+		t.Fatalf("%d empty emails after down, want 1", empty)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two cannot, so the migration stays applied
+	if err := repo.CreateUser(&models.User{Username: "bob", PasswordHash: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := database.MigrateDown(db, 1); err == nil || reverted != 0 {
+		t.Fatalf("down with two users without email: reverted %d, %v", reverted, err)
+	}
+	if missing := count("email IS NULL"); missing != 2 {
+		t.Fatalf("%d NULL emails after a failed down, want 2", missing)
+	}
+	if pending, err := database.PendingMigrations(db); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after a failed down: %v, %v", pending, err)
+	}
+}
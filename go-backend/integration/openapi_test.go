@@ -0,0 +1,130 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image/color"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/client"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/internal/apigen"
+	"github.com/Kami0rn/ProjectCPE/go-backend/routes"
+)
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if status := n.get("/openapi.json", "", &spec); status != http.StatusOK {
+		t.Fatalf("openapi.json: status %d", status)
+	}
+
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Validate()
+	var missing []string
+	for _, route := range routes.SetupRouter(&cfg, n.Handler).Routes() {
+		key := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		if !documented[key] {
+			missing = append(missing, key)
+		}
+		delete(documented, key)
+	}
+	var stale []string
+	for key := range documented {
+		stale = append(stale, key)
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 || len(stale) > 0 {
+		t.Fatalf("openapi.json out of date\nroutes not documented: %v\ndocumented but not routed: %v", missing, stale)
+	}
+
+	// Every $ref must resolve
+	for _, ref := range regexp.MustCompile(`"#/components/schemas/(\w+)"`).FindAllSubmatch(api.Spec, -1) {
+		if _, ok := spec.Components.Schemas[string(ref[1])]; !ok {
+			t.Errorf("dangling $ref to %s", ref[1])
+		}
+	}
+}
+
+func TestGeneratedClientUpToDate(t *testing.T) {
+	want, err := apigen.Generate(api.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../client/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client/client_gen.go is stale, run go generate ./client")
+	}
+}
+
+func TestGeneratedClient(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	ctx := context.Background()
+	c := client.New(n.Server.URL)
+
+	if _, err := c.Register(ctx, api.RegisterRequest{Username: "alice", Password: "pw", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := c.Login(ctx, api.LoginRequest{Username: "alice", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Token = login.Token
+
+	me, err := c.Me(ctx)
+	if err != nil || me.Username != "alice" || me.UserID == 0 {
+		t.Fatalf("Me = %+v, %v", me, err)
+	}
+
+	img := testPNG(color.RGBA{G: 255, A: 255})
+	block, err := c.MineBlock(ctx, client.Form{
+		Fields: map[string]string{"model_name": "m", "epochs": "1"},
+		Files:  []client.FormFile{{Field: "images", Filename: "a.png", Content: img}},
+	})
+	if err != nil || block.Index != 1 {
+		t.Fatalf("MineBlock = %+v, %v", block, err)
+	}
+
+	check, err := c.CheckImage(ctx, client.Form{Files: []client.FormFile{{Field: "image", Filename: "q.png", Content: img}}})
+	if err != nil || !check.Trained || check.Matches[0].BlockIndex != 1 {
+		t.Fatalf("CheckImage = %+v, %v", check, err)
+	}
+
+	png, err := c.GenerateImage(ctx, client.GenerateImageParams{Username: "alice", ModelName: "m"})
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("GenerateImage = %d bytes, %v", len(png), err)
+	}
+
+	_, err = c.ListMembers(ctx, "no-such-org")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message == "" {
+		t.Fatalf("ListMembers of a missing organisation: %v", err)
+	}
+}
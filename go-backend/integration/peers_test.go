@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/models"
+)
+
+func TestPeerManagement(t *testing.T) {
+	n := newNode(t, newFakeAI(t), withAdmins("alice"))
+	token := n.register("alice", "pw")
+
+	for _, peer := range []string{"127.0.0.1:9000", "http://127.0.0.1:9000/", "https://node2.example"} {
+		if status := n.postJSON("/api/add-peer", token, map[string]string{"peer": peer}, nil); status != http.StatusOK {
+			t.Fatalf("add %q: status %d", peer, status)
+		}
+	}
+
+	var resp api.PeersResponse
+	if status := n.get("/api/peers", token, &resp); status != http.StatusOK || len(resp.Peers) != 2 {
+		t.Fatalf("list peers: status %d, %+v", status, resp.Peers)
+	}
+	first := resp.Peers[0]
+	if first.Address != "http://127.0.0.1:9000" || first.Bootstrap || first.LastSeenAt != nil {
+		t.Fatalf("first peer %+v", first)
+	}
+
+	// Peers survive a restart of the node
+	restarted := handlers.New(n.Handler.Repo, n.Handler.AI, n.Handler.DataDir)
+	if err := restarted.LoadPeers(nil); err != nil {
+		t.Fatal(err)
+	}
+	if peers := restarted.Peers.List(); len(peers) != 2 || peers[0] != first.Address {
+		t.Fatalf("restored peers %v", peers)
+	}
+
+	remove := func(id string) int {
+		return n.do(n.request("DELETE", "/api/peers/"+id, token, nil, ""), nil)
+	}
+	bob := n.register("bob", "pw")
+	if status := n.do(n.request("DELETE", fmt.Sprintf("/api/peers/%d", first.ID), bob, nil, ""), nil); status != http.StatusForbidden {
+		t.Fatalf("remove as non-admin: status %d, want 403", status)
+	}
+	if status := remove(fmt.Sprint(first.ID)); status != http.StatusOK {
+		t.Fatalf("remove: status %d", status)
+	}
+	if peers := n.Handler.Peers.List(); len(peers) != 1 || peers[0] != "https://node2.example" {
+		t.Fatalf("peers after removal %v", peers)
+	}
+	if status := remove(fmt.Sprint(first.ID)); status != http.StatusNotFound {
+		t.Fatalf("remove twice: status %d, want 404", status)
+	}
+	if status := remove("abc"); status != http.StatusBadRequest {
+		t.Fatalf("remove with a bad ID: status %d, want 400", status)
+	}
+}
+
+func TestPeerHealthChecksEvictDeadPeers(t *testing.T) {
+	ai := newFakeAI(t)
+	n, live, dead, deadBootstrap := newNode(t, ai, withAdmins("alice")), newNode(t, ai), newNode(t, ai), newNode(t, ai)
+	dead.Server.Close()
+	deadBootstrap.Server.Close()
+	live.extend(2, "alice")
+
+	token := n.register("alice", "pw")
+	for _, peer := range []*node{live, dead} {
+		if status := n.postJSON("/api/add-peer", token, map[string]string{"peer": peer.Server.URL}, nil); status != http.StatusOK {
+			t.Fatalf("add peer: status %d", status)
+		}
+	}
+	if err := n.Handler.LoadPeers([]string{deadBootstrap.Server.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	n.Handler.CheckPeers(ctx, 2)
+
+	peers, err := n.Handler.Repo.ListPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAddress := map[string]models.Peer{}
+	for _, p := range peers {
+		byAddress[p.Address] = p
+	}
+	if p := byAddress[live.Server.URL]; p.Failures != 0 || p.LastSeenAt == nil {
+		t.Fatalf("live peer after a check: %+v", p)
+	}
+	if p := byAddress[dead.Server.URL]; p.Failures != 1 || p.LastError == "" {
+		t.Fatalf("dead peer after a check: %+v", p)
+	}
+	// The live peer was ahead, so the check also synchronised
+	if n.Handler.Chain.Len() != 3 {
+		t.Fatalf("chain has %d blocks after the check, want 3", n.Handler.Chain.Len())
+	}
+
+	n.Handler.CheckPeers(ctx, 2)
+
+	peers, err = n.Handler.Repo.ListPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0].Address != live.Server.URL || peers[1].Address != deadBootstrap.Server.URL || peers[1].Failures != 2 {
+		t.Fatalf("peers after the second check %+v, want the live and the bootstrap peer", peers)
+	}
+	if list := n.Handler.Peers.List(); len(list) != 2 {
+		t.Fatalf("replicating with %v after eviction", list)
+	}
+
+	events, err := n.Handler.Repo.ListAuditEvents(database.AuditFilter{Action: models.AuditEvictPeer, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Target != dead.Server.URL || events[0].Actor != "system" {
+		t.Fatalf("eviction audit events %+v", events)
+	}
+}
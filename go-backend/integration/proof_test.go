@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/hex"
+	"image/color"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+func TestMinedBlockCarriesVerifiableTrainingProof(t *testing.T) {
+	nodes, ai := newCluster(t, 2)
+	token := nodes[0].register("alice", "pw")
+
+	cat, dog := testPNG(color.RGBA{G: 255, A: 255}), testPNG(color.RGBA{B: 255, A: 255})
+	block, status := nodes[0].mine(token, "pets", map[string][]byte{"cat.png": cat, "dog.png": dog}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	proof := block.TrainingProof
+	if proof == nil || block.Version != blockchain.CurrentVersion {
+		t.Fatalf("version %d block without training proof", block.Version)
+	}
+	root := blockchain.DatasetRoot([]string{sha256Hex(cat), sha256Hex(dog)})
+	if proof.Owner != "alice" || proof.ModelName != "pets" || proof.Seed != fakeSeed ||
+		proof.DatasetRoot != hex.EncodeToString(root[:]) || proof.Hyperparameters.Epochs != 1 || proof.SampleHash != block.Proof {
+		t.Fatalf("unexpected training proof %+v", proof)
+	}
+
+	// The proof replicates intact, so a peer can verify it too
+	waitFor(t, "block to replicate", func() bool { return nodes[1].Handler.Chain.Len() == 2 })
+	for _, n := range nodes {
+		var resp api.VerifyProofResponse
+		if status := n.postJSON("/api/blocks/1/verify-proof", token, nil, &resp); status != http.StatusOK {
+			t.Fatalf("verify on %s: status %d", n.Server.URL, status)
+		}
+		if !resp.Valid || !resp.WeightsMatch || !resp.SampleMatch || resp.Proof != *proof {
+			t.Fatalf("verify on %s: %+v", n.Server.URL, resp)
+		}
+	}
+
+	// Weights replaced after mining no longer match the block
+	ai.ReplaceWeights("alice", "pets")
+	var resp api.VerifyProofResponse
+	if status := nodes[0].postJSON("/api/blocks/1/verify-proof", token, nil, &resp); status != http.StatusOK {
+		t.Fatalf("verify after replacing weights: status %d", status)
+	}
+	if resp.Valid || resp.WeightsMatch || resp.SampleMatch {
+		t.Fatalf("replaced weights verified: %+v", resp)
+	}
+}
+
+func TestVerifyProofErrors(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+	n.extend(1, "alice")
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/api/blocks/abc/verify-proof", http.StatusBadRequest},
+		{"/api/blocks/0/verify-proof", http.StatusBadRequest}, // genesis has no proof
+		{"/api/blocks/1/verify-proof", http.StatusBadRequest}, // nor has a block not mined from training
+		{"/api/blocks/9/verify-proof", http.StatusNotFound},
+	} {
+		var resp api.ErrorResponse
+		if status := n.postJSON(tc.path, token, nil, &resp); status != tc.want {
+			t.Errorf("%s: status %d (%+v), want %d", tc.path, status, resp, tc.want)
+		}
+	}
+	if status := n.postJSON("/api/blocks/1/verify-proof", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d", status)
+	}
+
+	// An AI service outage is an upstream error, not a failed verification
+	block, status := n.mine(token, "pets", map[string][]byte{"cat.png": testPNG(color.Black)}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	ai.SetFailing(true)
+	var resp api.ErrorResponse
+	if status := n.postJSON("/api/blocks/2/verify-proof", token, nil, &resp); status != http.StatusBadGateway || resp.Code != "upstream_error" {
+		t.Fatalf("verify block %d during an outage: status %d, %+v", block.Index, status, resp)
+	}
+
+}
+
+func TestVerifyProofRefusesUnsafeNames(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	token := n.register("alice", "pw")
+
+	// A peer's block may name any owner, which must not reach the AI
+	// service's paths
+	proof := blockchain.TrainingProof{Owner: "../../etc", ModelName: "pets", WeightsHash: "ab", SampleHash: "cd"}
+	if err := n.Handler.Chain.AddBlock(blockchain.GenerateTrainedBlock(n.Handler.Chain.LastBlock(), nil, proof)); err != nil {
+		t.Fatal(err)
+	}
+	var resp api.ErrorResponse
+	if status := n.postJSON("/api/blocks/1/verify-proof", token, nil, &resp); status != http.StatusBadRequest {
+		t.Fatalf("verify a proof with an unsafe owner: status %d, %+v", status, resp)
+	}
+}
+
+func TestModelsAreMinedOnce(t *testing.T) {
+	nodes, _ := newCluster(t, 2)
+	alice, bob := nodes[0].register("alice", "pw"), nodes[0].register("bob", "pw")
+
+	// The AI service keeps one set of weights per owner and model name
+	if _, status := nodes[0].mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.Black)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	if _, status := nodes[0].mine(alice, "pets", map[string][]byte{"dog.png": testPNG(color.White)}, nil); status != http.StatusConflict {
+		t.Fatalf("mine the same model again: status %d, want 409", status)
+	}
+	if _, status := nodes[0].mine(bob, "pets", map[string][]byte{"dog.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine another owner's model name: status %d", status)
+	}
+
+	// Images are stored under their names, so two may not share one
+	other := nodes[1].register("alice", "pw")
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, c := range []color.Color{color.Black, color.White} {
+		part, _ := writer.CreateFormFile("images", "cat.png")
+		part.Write(testPNG(c))
+	}
+	writer.WriteField("model_name", "cats")
+	writer.WriteField("epochs", "1")
+	writer.Close()
+	var resp api.ErrorResponse
+	status := nodes[1].do(nodes[1].request("POST", "/api/mine", other, &body, writer.FormDataContentType()), &resp)
+	if status != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "images" {
+		t.Fatalf("mine two images of one name: status %d, %+v", status, resp)
+	}
+
+	// A model mined on another node is taken there too
+	waitFor(t, "blocks to replicate", func() bool { return nodes[1].Handler.Chain.Len() == 3 })
+	if _, status := nodes[1].mine(other, "pets", map[string][]byte{"dog.png": testPNG(color.White)}, nil); status != http.StatusConflict {
+		t.Fatalf("mine a model of the chain on a peer: status %d, want 409", status)
+	}
+}
+
+func TestMineRejectsForeignDatasetRoot(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	ai.SetDatasetRoot("00ff")
+	if _, status := n.mine(token, "pets", map[string][]byte{"cat.png": testPNG(color.Black)}, nil); status != http.StatusBadGateway {
+		t.Fatalf("mine with a foreign dataset root: status %d", status)
+	}
+	if n.Handler.Chain.Len() != 1 {
+		t.Fatalf("chain grew to %d blocks", n.Handler.Chain.Len())
+	}
+}
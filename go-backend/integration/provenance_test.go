@@ -0,0 +1,186 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/provenance"
+)
+
+func TestProvenanceManifestInPNGAndJPEG(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	claim := provenance.Claim{ModelID: 7, ModelName: "pets", Owner: "alice", BlockHash: strings.Repeat("ab", 32), Timestamp: time.Now().UTC()}
+
+	var jpg bytes.Buffer
+	img, _ := png.Decode(bytes.NewReader(testPNG(color.RGBA{R: 200, A: 255})))
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, original := range map[string][]byte{"png": testPNG(color.RGBA{R: 200, A: 255}), "jpeg": jpg.Bytes()} {
+		signed, err := provenance.Attach(original, claim, key)
+		if err != nil {
+			t.Fatalf("%s: attach: %v", name, err)
+		}
+		m, err := provenance.Read(signed)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+		if m.Claim.ModelName != "pets" || m.Claim.BlockHash != claim.BlockHash || m.Claim.Format != provenance.Format ||
+			m.Claim.NodeKey != hex.EncodeToString(key.Public().(ed25519.PublicKey)) || !m.Claim.Timestamp.Equal(claim.Timestamp) {
+			t.Fatalf("%s: manifest %+v", name, m)
+		}
+
+		// The file still decodes, and taking the manifest out restores it
+		if name == "png" {
+			_, err = png.Decode(bytes.NewReader(signed))
+		} else {
+			_, err = jpeg.Decode(bytes.NewReader(signed))
+		}
+		if err != nil {
+			t.Fatalf("%s: decode signed file: %v", name, err)
+		}
+		if stripped, err := provenance.Strip(signed); err != nil || !bytes.Equal(stripped, original) {
+			t.Fatalf("%s: strip: %v", name, err)
+		}
+
+		// Signing again replaces the manifest
+		claim2 := claim
+		claim2.ModelName = "dogs"
+		resigned, err := provenance.Attach(signed, claim2, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, err := provenance.Read(resigned); err != nil || m.Claim.ModelName != "dogs" {
+			t.Fatalf("%s: re-signed: %+v, %v", name, m, err)
+		}
+
+		// Changing the image or the claim breaks the manifest
+		modified := bytes.Clone(signed)
+		modified[len(modified)-5] ^= 0xff
+		if _, err := provenance.Read(modified); !errors.Is(err, provenance.ErrModified) {
+			t.Fatalf("%s: modified image: %v, want ErrModified", name, err)
+		}
+		forged := bytes.Replace(signed, []byte(`"model_name":"pets"`), []byte(`"model_name":"cats"`), 1)
+		if _, err := provenance.Read(forged); !errors.Is(err, provenance.ErrBadSignature) {
+			t.Fatalf("%s: forged claim: %v, want ErrBadSignature", name, err)
+		}
+
+		if _, err := provenance.Read(original); !errors.Is(err, provenance.ErrNoManifest) {
+			t.Fatalf("%s: unsigned file: %v, want ErrNoManifest", name, err)
+		}
+	}
+
+	if _, err := provenance.Read([]byte("GIF89a")); !errors.Is(err, provenance.ErrUnsupportedFormat) {
+		t.Fatalf("GIF: %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestInspectImageChecksTheManifestOfAGeneratedImage(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice, bob := n.register("alice", "pw"), n.register("bob", "pw")
+
+	block, status := n.mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.RGBA{R: 9, A: 255})}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	generated := n.generate(bob, "username=alice&model_name=pets")
+
+	resp, status := n.inspectImage(bob, generated)
+	if status != http.StatusOK || !resp.HasManifest || !resp.Valid || !resp.Trusted || !resp.BlockExists || resp.BlockIndex != block.Index || resp.Manifest == nil {
+		t.Fatalf("inspect: status %d, %+v", status, resp)
+	}
+	claim := resp.Manifest.Claim
+	nodeKey := hex.EncodeToString(n.Handler.NodeKey.Public().(ed25519.PublicKey))
+	if claim.ModelName != "pets" || claim.Owner != "alice" || claim.BlockHash != block.Hash || claim.NodeKey != nodeKey {
+		t.Fatalf("claim %+v", claim)
+	}
+
+	// The node keeps its key across restarts
+	if err := n.Handler.LoadNodeKey(""); err != nil || hex.EncodeToString(n.Handler.NodeKey.Public().(ed25519.PublicKey)) != nodeKey {
+		t.Fatalf("reloaded node key: %v", err)
+	}
+
+	// An edited image keeps its manifest, which no longer holds
+	edited := bytes.Clone(generated)
+	edited[len(edited)-20] ^= 0xff
+	resp, status = n.inspectImage(bob, edited)
+	if status != http.StatusOK || !resp.HasManifest || resp.Valid || resp.Problem == "" || !resp.BlockExists {
+		t.Fatalf("edited image: status %d, %+v", status, resp)
+	}
+
+	// Re-encoding drops the manifest, though not the watermark
+	img, _ := png.Decode(bytes.NewReader(generated))
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 80})
+	resp, status = n.inspectImage(bob, jpg.Bytes())
+	if status != http.StatusOK || resp.HasManifest || resp.Valid || resp.BlockIndex != -1 {
+		t.Fatalf("re-encoded image: status %d, %+v", status, resp)
+	}
+	if verified, _ := n.verifyImage(bob, jpg.Bytes()); verified.BlockIndex != block.Index {
+		t.Fatalf("re-encoded image lost its watermark: %+v", verified)
+	}
+
+	// Anyone can sign a manifest naming a real model, but only one signed
+	// by a trusted node holds
+	pub, key, _ := ed25519.GenerateKey(nil)
+	sign := func(claim provenance.Claim) []byte {
+		t.Helper()
+		claim.Timestamp = time.Now()
+		signed, err := provenance.Attach(jpg.Bytes(), claim, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	foreign := sign(provenance.Claim{ModelName: "pets", Owner: "alice", BlockHash: block.Hash})
+	resp, status = n.inspectImage(bob, foreign)
+	if status != http.StatusOK || resp.Valid || resp.Trusted || !resp.BlockExists || !strings.Contains(resp.Problem, "does not trust") {
+		t.Fatalf("manifest of an unknown key: status %d, %+v", status, resp)
+	}
+	n.Handler.TrustedNodeKeys = []string{hex.EncodeToString(pub)}
+	resp, status = n.inspectImage(bob, foreign)
+	if status != http.StatusOK || !resp.Valid || !resp.Trusted || resp.BlockIndex != block.Index {
+		t.Fatalf("manifest of a trusted key: status %d, %+v", status, resp)
+	}
+
+	// and names a model mined in a block of this node's chain
+	for name, claim := range map[string]provenance.Claim{
+		"other model":   {ModelName: "birds", Owner: "alice", BlockHash: block.Hash},
+		"other owner":   {ModelName: "pets", Owner: "bob", BlockHash: block.Hash},
+		"unknown block": {ModelName: "pets", Owner: "alice", BlockHash: strings.Repeat("0", 64)},
+	} {
+		resp, status = n.inspectImage(bob, sign(claim))
+		if status != http.StatusOK || resp.Valid || !resp.Trusted || resp.Problem == "" {
+			t.Fatalf("%s: status %d, %+v", name, status, resp)
+		}
+	}
+
+	if _, status := n.inspectImage(bob, []byte("not an image")); status != http.StatusBadRequest {
+		t.Fatalf("not an image: status %d, want 400", status)
+	}
+}
+
+func (n *node) inspectImage(token string, data []byte) (api.InspectImageResponse, int) {
+	n.t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "query")
+	part.Write(data)
+	writer.Close()
+
+	var resp api.InspectImageResponse
+	status := n.do(n.request("POST", "/api/inspect-image", token, &body, writer.FormDataContentType()), &resp)
+	return resp, status
+}
@@ -0,0 +1,205 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+func TestTransactionRelay(t *testing.T) {
+	nodes, _ := newCluster(t, 3)
+	token := nodes[0].register("alice", "pw")
+
+	tx := blockchain.Transaction{Sender: "alice", Receiver: "bob"}
+	if status := nodes[0].postJSON("/api/transaction", token, tx, nil); status != http.StatusCreated {
+		t.Fatalf("add transaction: status %d", status)
+	}
+	for _, peer := range nodes[1:] {
+		waitFor(t, "transaction to reach "+peer.Server.URL, func() bool {
+			pending := peer.Handler.Mempool.Pending()
+			return len(pending) == 1 && pending[0] == tx
+		})
+	}
+
+	// Once a block including it is announced, every mempool drops it
+	block := blockchain.GenerateBlock(nodes[1].Handler.Chain.LastBlock(), nodes[1].Handler.Mempool.Drain(), "proof")
+	if err := nodes[1].Handler.Chain.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	nodes[1].Handler.Peers.BroadcastBlock(context.Background(), block)
+	for _, peer := range []*node{nodes[0], nodes[2]} {
+		waitFor(t, "mempool of "+peer.Server.URL+" to empty", func() bool {
+			return peer.Handler.Chain.Len() == 2 && peer.Handler.Mempool.Len() == 0
+		})
+	}
+
+	// Only blocks carry rewards, checked against the policy
+	reward := blockchain.Transaction{Sender: blockchain.SenderRewards, Receiver: "alice", Amount: 100, Reference: "train:forged:alice"}
+	if err := nodes[0].Handler.PeerClient.RelayTransaction(context.Background(), nodes[1].Server.URL, reward); err == nil {
+		t.Fatal("peer took a relayed reward")
+	}
+	// nor image hashes, which only a training run claims
+	image := blockchain.Transaction{Sender: "alice", Receiver: "blockchain", ImageHash: strings.Repeat("ab", 32)}
+	if err := nodes[0].Handler.PeerClient.RelayTransaction(context.Background(), nodes[1].Server.URL, image); err == nil {
+		t.Fatal("peer took a relayed image hash")
+	}
+	if nodes[1].Handler.Mempool.Len() != 0 {
+		t.Fatalf("mempool after a relayed reward and image %+v", nodes[1].Handler.Mempool.Pending())
+	}
+}
+
+func TestSyncStreamsMissingBlocks(t *testing.T) {
+	ai := newFakeAI(t)
+	ahead, behind := newNode(t, ai), newNode(t, ai)
+	blocks := ahead.extend(5, "alice")
+
+	if err := behind.Handler.Peers.Add(ahead.Server.URL); err != nil {
+		t.Fatal(err)
+	}
+	behind.Handler.SynchronizeBlockchain(context.Background())
+
+	if behind.Handler.Chain.Len() != 6 || behind.Handler.Chain.LastBlock().Hash != blocks[4].Hash {
+		t.Fatalf("after sync: %d blocks ending in %s, want 6 ending in %s",
+			behind.Handler.Chain.Len(), behind.Handler.Chain.LastBlock().Hash, blocks[4].Hash)
+	}
+}
+
+func TestSyncSwitchesForkAndRestoresOrphans(t *testing.T) {
+	ai := newFakeAI(t)
+	long, short := newNode(t, ai), newNode(t, ai)
+	shared := long.extend(1, "shared")
+	if err := short.Handler.Chain.AddBlock(shared[0]); err != nil {
+		t.Fatal(err)
+	}
+	long.extend(3, "alice")
+
+	// Of the orphaned transactions only the transfer may wait on its own:
+	// the image belongs with the block's training proof and the usage
+	// record with a model the longer chain does not have
+	transfer := blockchain.Transaction{Sender: "bob", Receiver: "carol"}
+	orphan := blockchain.GenerateBlock(short.Handler.Chain.LastBlock(), []blockchain.Transaction{
+		transfer,
+		{Sender: "bob", Receiver: "blockchain", ImageHash: "bob-0"},
+		{Sender: "bob", Receiver: blockchain.ReceiverUsage, Model: "x", Reference: blockchain.UsageReference("x", "bob")},
+	}, "proof")
+	if err := short.Handler.Chain.AddBlock(orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := short.Handler.Peers.Add(long.Server.URL); err != nil {
+		t.Fatal(err)
+	}
+	short.Handler.SynchronizeBlockchain(context.Background())
+
+	if got, want := short.Handler.Chain.LastBlock().Hash, long.Handler.Chain.LastBlock().Hash; got != want {
+		t.Fatalf("short node ends in %s, want %s", got, want)
+	}
+	if pending := short.Handler.Mempool.Pending(); len(pending) != 1 || pending[0] != transfer {
+		t.Fatalf("mempool after the switch %+v, want the orphaned transfer", pending)
+	}
+}
+
+func TestAnnounceBeyondTipTriggersSync(t *testing.T) {
+	ai := newFakeAI(t)
+	ahead, behind := newNode(t, ai), newNode(t, ai)
+	ahead.extend(3, "alice")
+
+	// behind learns of ahead only through an announce it cannot apply
+	if err := behind.Handler.Peers.Add(ahead.Server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := ahead.Handler.Peers.Add(behind.Server.URL); err != nil {
+		t.Fatal(err)
+	}
+	ahead.Handler.Peers.BroadcastBlock(context.Background(), ahead.extend(1, "alice")[0])
+
+	waitFor(t, "lagging node to catch up", func() bool {
+		return behind.Handler.Chain.Len() == 5
+	})
+}
+
+func TestChainStatusAndHeaders(t *testing.T) {
+	ai := newFakeAI(t)
+	n, other := newNode(t, ai), newNode(t, ai)
+	blocks := n.extend(3, "alice")
+	n.Handler.Mempool.Add(blockchain.Transaction{Sender: "a", Receiver: "b"})
+
+	client := other.Handler.PeerClient
+	ctx := context.Background()
+
+	status, err := client.ChainStatus(ctx, n.Server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Height != 3 || status.TipHash != blocks[2].Hash || status.MempoolSize != 1 {
+		t.Fatalf("status %+v", status)
+	}
+
+	headers, err := client.Headers(ctx, n.Server.URL, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 || headers[0].Hash != blocks[0].Hash || headers[1].PrevHash != blocks[0].Hash {
+		t.Fatalf("headers %+v", headers)
+	}
+
+	if _, err := client.Blocks(ctx, n.Server.URL, 5, 0); err == nil {
+		t.Fatal("blocks past the tip: want an error")
+	}
+}
+
+func TestBroadcastRetriesUntilPeerComesBack(t *testing.T) {
+	ai := newFakeAI(t)
+	n, peer := newNode(t, ai, withAdmins("alice")), newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	// Reserve an address for the peer, which is down for now
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "http://" + ln.Addr().String()
+	ln.Close()
+	if status := n.postJSON("/api/add-peer", token, map[string]string{"peer": address}, nil); status != http.StatusOK {
+		t.Fatalf("add peer: status %d", status)
+	}
+
+	blocks := n.extend(3, "alice")
+	n.Handler.Peers.BroadcastBlock(context.Background(), blocks[2])
+
+	waitFor(t, "delivery to fail", func() bool {
+		var resp api.PeersResponse
+		n.get("/api/peers", token, &resp)
+		r := resp.Peers[0].Replication
+		return r != nil && r.Failures > 0 && r.Lag == 3 && r.NextRetryAt != nil
+	})
+	if body := n.metrics(); !strings.Contains(body, fmt.Sprintf("peer_replication_lag_blocks{peer=%q} 3", address)) {
+		t.Errorf("metrics do not show the lag:\n%s", body)
+	}
+
+	// The peer comes up on its address and is sent every block it missed
+	ln, err = net.Listen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: peer.Server.Config.Handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	waitFor(t, "peer to catch up", func() bool {
+		return peer.Handler.Chain.Len() == 4
+	})
+	if got := peer.Handler.Chain.LastBlock().Hash; got != blocks[2].Hash {
+		t.Fatalf("peer ends in %s, want %s", got, blocks[2].Hash)
+	}
+	waitFor(t, "replication status to recover", func() bool {
+		status := n.Handler.Peers.Status()[0]
+		return status.Lag == 0 && status.Failures == 0 && status.AckedHeight == 3
+	})
+}
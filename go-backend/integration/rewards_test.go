@@ -0,0 +1,231 @@
+package integration
+
+import (
+	"encoding/hex"
+	"errors"
+	"image/color"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
+)
+
+func TestRewardsCreditImageOwnersOnce(t *testing.T) {
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.Rewards = config.RewardsConfig{Policy: config.RewardPerImage, Train: 10, Generate: 2}
+	})
+	alice, bob := n.register("alice", "pw"), n.register("bob", "pw")
+	cat, dog, bird := testPNG(color.RGBA{R: 1, A: 255}), testPNG(color.RGBA{R: 2, A: 255}), testPNG(color.RGBA{R: 3, A: 255})
+
+	balance := func(username string) api.BalanceResponse {
+		var resp api.BalanceResponse
+		if status := n.get("/api/users/"+username+"/balance", alice, &resp); status != http.StatusOK {
+			t.Fatalf("balance of %s: status %d", username, status)
+		}
+		return resp
+	}
+	pendingRewards := func() int {
+		count := 0
+		for _, tx := range n.Handler.Mempool.Pending() {
+			if tx.IsReward() {
+				count++
+			}
+		}
+		return count
+	}
+	rewardsIn := func(block blockchain.Block) map[string]float64 {
+		credits := map[string]float64{}
+		for _, tx := range block.Transactions {
+			if tx.IsReward() {
+				credits[tx.Receiver] += tx.Amount
+			}
+		}
+		return credits
+	}
+
+	// Training credits the owners of the images, and the first to put an
+	// image on the chain owns it
+	block, status := n.mine(alice, "pets", map[string][]byte{"cat.png": cat, "dog.png": dog}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	if credits := rewardsIn(block); len(credits) != 1 || credits["alice"] != 10 {
+		t.Fatalf("rewards for alice's run: %v", credits)
+	}
+	block, status = n.mine(bob, "birds", map[string][]byte{"cat.png": cat, "bird.png": bird}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	if credits := rewardsIn(block); len(credits) != 2 || credits["alice"] != 5 || credits["bob"] != 5 {
+		t.Fatalf("rewards for bob's run: %v", credits)
+	}
+
+	// Every generated image is credited, whatever X-Request-ID the client
+	// sends
+	generate := func(requestID string) {
+		req := n.request("GET", "/api/generate-image?username=bob&model_name=birds", bob, nil, "")
+		req.Header.Set("X-Request-ID", requestID)
+		if status := n.do(req, nil); status != http.StatusOK {
+			t.Fatalf("generate: status %d", status)
+		}
+	}
+	generate("gen-1")
+	generate("gen-1")
+	if pending := pendingRewards(); pending != 4 {
+		t.Fatalf("%d pending rewards after two generations, want 4", pending)
+	}
+	if got := balance("alice"); got.Balance != 15 || got.Pending != 2 {
+		t.Fatalf("alice before the generation is mined: %+v", got)
+	}
+
+	// Users can neither mint credits nor move them, and send transactions
+	// as themselves
+	minted := blockchain.Transaction{Sender: blockchain.SenderRewards, Receiver: "alice", Amount: 100, Reference: "train:forged:alice"}
+	if status := n.postJSON("/api/transaction", alice, minted, nil); status != http.StatusBadRequest {
+		t.Fatalf("minting: status %d, want 400", status)
+	}
+	if status := n.postJSON("/api/transaction", alice, blockchain.Transaction{Receiver: "bob", Amount: 2}, nil); status != http.StatusBadRequest {
+		t.Fatalf("transfer with an amount: status %d, want 400", status)
+	}
+	if status := n.postJSON("/api/transaction", alice, blockchain.Transaction{Sender: "bob", Receiver: "carol"}, nil); status != http.StatusCreated {
+		t.Fatalf("transfer: status %d", status)
+	}
+	var sent []string
+	for _, tx := range n.Handler.Mempool.Pending() {
+		if tx.IsTransfer() {
+			sent = append(sent, tx.Sender)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "alice" {
+		t.Fatalf("senders of pending transfers %v, want [alice]", sent)
+	}
+
+	// Only training on an image claims it: bob naming it first, in a
+	// transaction or a block of his own, does not
+	fish := testPNG(color.RGBA{R: 4, A: 255})
+	claim := blockchain.Transaction{Sender: "bob", Receiver: "blockchain", ImageHash: sha256Hex(fish)}
+	if status := n.postJSON("/api/transaction", bob, claim, nil); status != http.StatusBadRequest {
+		t.Fatalf("image claim: status %d, want 400", status)
+	}
+	if err := n.Handler.Chain.AddBlock(blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{claim}, "proof")); err != nil {
+		t.Fatal(err)
+	}
+	block, status = n.mine(alice, "more", map[string][]byte{"fish.png": fish}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	if credits := rewardsIn(block); credits["alice"] != 12 || credits["bob"] != 2 {
+		t.Fatalf("rewards for alice's second run: %v", credits)
+	}
+	generate("gen-1")
+	if pending := pendingRewards(); pending != 2 {
+		t.Fatalf("%d pending rewards for a generation reusing a mined request ID, want 2", pending)
+	}
+
+	if got := balance("alice"); got.Balance != 27 || got.Credited != 27 || got.Debited != 0 || got.Pending != 1 {
+		t.Fatalf("alice: %+v", got)
+	}
+	if got := balance("bob"); got.Balance != 7 {
+		t.Fatalf("bob: %+v", got)
+	}
+	var statement api.StatementResponse
+	if status := n.get("/api/users/bob/statement", bob, &statement); status != http.StatusOK {
+		t.Fatalf("statement: status %d", status)
+	}
+	if len(statement.Entries) != 3 || statement.Balance != 7 {
+		t.Fatalf("bob's statement %+v", statement)
+	}
+	for i, want := range []struct {
+		counterparty string
+		amount       float64
+	}{{blockchain.SenderRewards, 5}, {blockchain.SenderRewards, 1}, {blockchain.SenderRewards, 1}} {
+		if e := statement.Entries[i]; e.Counterparty != want.counterparty || e.Amount != want.amount {
+			t.Fatalf("entry %d: %+v, want %v", i, e, want)
+		}
+	}
+
+	// The chain itself refuses a second credit for an event
+	var credited blockchain.Transaction
+	for _, tx := range n.Handler.Chain.Blocks()[1].Transactions {
+		if tx.IsReward() {
+			credited = tx
+		}
+	}
+	again := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{credited}, "proof")
+	if err := n.Handler.Chain.AddBlock(again); !errors.Is(err, blockchain.ErrInvalidBlock) {
+		t.Fatalf("second credit: %v", err)
+	}
+
+	// and a reward its policy does not pay, such as one a peer forged
+	forged := blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), []blockchain.Transaction{minted}, "proof")
+	if err := n.Handler.Chain.AddBlock(forged); !errors.Is(err, rewards.ErrUnpaidReward) {
+		t.Fatalf("forged reward: %v", err)
+	}
+}
+
+func TestRewardsNeedTheirEvent(t *testing.T) {
+	n := newNode(t, newFakeAI(t), func(cfg *config.Config) {
+		cfg.Rewards = config.RewardsConfig{Policy: config.RewardPerImage, Train: 10, Generate: 2}
+	})
+	alice := n.register("alice", "pw")
+	model, status := n.mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.RGBA{R: 5, A: 255})}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	add := func(txs ...blockchain.Transaction) error {
+		return n.Handler.Chain.AddBlock(blockchain.GenerateBlock(n.Handler.Chain.LastBlock(), txs, "proof"))
+	}
+
+	// A generation is paid only along with, or after, its usage record
+	reference := rewards.GenerationReference(model.Hash, "made-up")
+	paid := n.Handler.Rewards.Credits(reference, 2, []string{"alice"})
+	if err := add(paid...); !errors.Is(err, rewards.ErrUnpaidReward) {
+		t.Fatalf("generation without a usage record: %v", err)
+	}
+	usage := blockchain.Transaction{Sender: "bob", Receiver: blockchain.ReceiverUsage, Model: model.Hash, Reference: blockchain.UsageReference(model.Hash, "made-up")}
+	if err := add(append(paid, usage)...); err != nil {
+		t.Fatalf("generation with its usage record: %v", err)
+	}
+
+	// The images of a training run are those of its proof's dataset, and
+	// its owner uploaded them, whoever sent the transactions
+	fish := sha256Hex(testPNG(color.RGBA{R: 6, A: 255}))
+	root := blockchain.DatasetRoot([]string{fish})
+	proof := blockchain.TrainingProof{Owner: "alice", ModelName: "fish", DatasetRoot: hex.EncodeToString(root[:]), SampleHash: "cd"}
+	image := blockchain.Transaction{Sender: "mallory", Receiver: "blockchain", ImageHash: fish}
+	trained := func(credited string, txs ...blockchain.Transaction) error {
+		txs = append(txs, n.Handler.Rewards.Credits(rewards.TrainingReference(proof), 10, []string{credited})...)
+		return n.Handler.Chain.AddBlock(blockchain.GenerateTrainedBlock(n.Handler.Chain.LastBlock(), txs, proof))
+	}
+	junk := blockchain.Transaction{Sender: "mallory", Receiver: "blockchain", ImageHash: strings.Repeat("ab", 32)}
+	if err := trained("mallory", image, junk); !errors.Is(err, rewards.ErrUnpaidReward) {
+		t.Fatalf("run with an image outside its dataset: %v", err)
+	}
+	if err := trained("mallory", image); !errors.Is(err, rewards.ErrUnpaidReward) {
+		t.Fatalf("run paying the sender of its images: %v", err)
+	}
+	if err := trained("alice", image); err != nil {
+		t.Fatalf("run paying the owner of its proof: %v", err)
+	}
+}
+
+func TestRewardPolicies(t *testing.T) {
+	owners := []string{"bob", "alice", "alice"}
+	for _, c := range []struct {
+		split      string
+		alice, bob float64
+	}{
+		{config.RewardPerImage, 6, 3},
+		{config.RewardEqual, 4.5, 4.5},
+	} {
+		txs := rewards.Policy{Split: c.split}.Credits("train:x", 9, owners)
+		if len(txs) != 2 || txs[0].Receiver != "alice" || txs[0].Amount != c.alice || txs[1].Amount != c.bob ||
+			txs[0].Reference != "train:x:alice" || !txs[1].IsReward() {
+			t.Fatalf("%s: %+v", c.split, txs)
+		}
+	}
+}
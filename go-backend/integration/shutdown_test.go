@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+)
+
+func TestNodeStateSurvivesRestart(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	pending := blockchain.Transaction{Sender: "alice", Receiver: "bob"}
+	if status := n.postJSON("/api/transaction", token, pending, nil); status != http.StatusCreated {
+		t.Fatalf("add transaction: status %d", status)
+	}
+
+	if err := n.Handler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := handlers.New(n.Handler.Repo, n.Handler.AI, n.Handler.DataDir)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restarted.Chain.LastBlock().Hash, n.Handler.Chain.LastBlock().Hash; got != want {
+		t.Fatalf("restored chain ends in %s, want %s", got, want)
+	}
+	if got := restarted.Mempool.Pending(); len(got) != 1 || got[0] != pending {
+		t.Fatalf("restored mempool %+v, want [%+v]", got, pending)
+	}
+}
+
+func TestShutdownCancelsStuckBroadcasts(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	token := n.register("alice", "pw")
+
+	// A peer that accepts connections and never answers
+	stuck, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stuck.Close() })
+	go func() {
+		for {
+			conn, err := stuck.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	if err := n.Handler.Peers.Add(stuck.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, status := n.mine(token, "m", map[string][]byte{"a.png": testPNG(color.White)}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Handler.Peers.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Shutdown took %s", elapsed)
+	}
+}
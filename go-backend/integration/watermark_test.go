@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/watermark"
+)
+
+func TestVerifyImageFindsTheBlockOfAGeneratedImage(t *testing.T) {
+	n := newNode(t, newFakeAI(t))
+	alice, bob := n.register("alice", "pw"), n.register("bob", "pw")
+
+	block, status := n.mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.RGBA{R: 9, A: 255})}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+	generated := n.generate(bob, "username=alice&model_name=pets")
+
+	// The mark outlives recompression and a screenshot-like rescale
+	img, err := png.Decode(bytes.NewReader(generated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, scale(img, 0.8), &jpeg.Options{Quality: 70}); err != nil {
+		t.Fatal(err)
+	}
+	resp, status := n.verifyImage(bob, jpg.Bytes())
+	if status != http.StatusOK || !resp.Watermarked || resp.BlockIndex != block.Index || resp.BlockHash != block.Hash {
+		t.Fatalf("verify: status %d, %+v", status, resp)
+	}
+	if resp.HashPrefix != block.Hash[:2*watermark.PayloadSize] || resp.Model == nil || resp.Model.Name != "pets" || resp.Model.CreatedBy != "alice" {
+		t.Fatalf("verify: %+v", resp)
+	}
+
+	// An image without a mark names no block
+	resp, status = n.verifyImage(bob, testPNG(color.RGBA{R: 9, A: 255}))
+	if status != http.StatusOK || resp.Watermarked || resp.BlockIndex != -1 || resp.Model != nil {
+		t.Fatalf("unmarked image: status %d, %+v", status, resp)
+	}
+	if _, status := n.verifyImage(bob, []byte("not an image")); status != http.StatusBadRequest {
+		t.Fatalf("not an image: status %d, want 400", status)
+	}
+
+	// The block of an organisation's model is found for anyone, the model
+	// only for its members
+	if status := n.postJSON("/api/orgs", alice, map[string]string{"name": "lab"}, nil); status != http.StatusCreated {
+		t.Fatalf("create org: status %d", status)
+	}
+	orgBlock, status := n.mine(alice, "secret", map[string][]byte{"a.png": testPNG(color.RGBA{R: 10, A: 255})}, map[string]string{"organization": "lab"})
+	if status != http.StatusCreated {
+		t.Fatalf("mine for org: status %d", status)
+	}
+	orgImage := n.generate(alice, "organization=lab&model_name=secret")
+	if resp, status := n.verifyImage(bob, orgImage); status != http.StatusOK || resp.BlockIndex != orgBlock.Index || resp.Model != nil {
+		t.Fatalf("org image for a non-member: status %d, %+v", status, resp)
+	}
+	if resp, status := n.verifyImage(alice, orgImage); status != http.StatusOK || resp.Model == nil || resp.Model.Organization != "lab" {
+		t.Fatalf("org image for a member: status %d, %+v", status, resp)
+	}
+}
+
+func TestImagesThatCannotBeMarkedAreRefused(t *testing.T) {
+	ai := newFakeAI(t)
+	n := newNode(t, ai)
+	alice := n.register("alice", "pw")
+	if _, status := n.mine(alice, "pets", map[string][]byte{"cat.png": testPNG(color.RGBA{R: 11, A: 255})}, nil); status != http.StatusCreated {
+		t.Fatalf("mine: status %d", status)
+	}
+
+	// An unmarked image is not served, stored or recorded
+	ai.SetBrokenPNGs(true)
+	var resp api.ErrorResponse
+	if status := n.get("/api/generate-image?username=alice&model_name=pets", alice, &resp); status != http.StatusInternalServerError || resp.Code != "internal" {
+		t.Fatalf("generate: status %d, %+v", status, resp)
+	}
+	var page api.GalleryResponse
+	if status := n.get("/api/model/images?username=alice&model_name=pets", alice, &page); status != http.StatusOK || len(page.Images) != 0 {
+		t.Fatalf("gallery: status %d, %+v", status, page)
+	}
+	if pending := n.Handler.Mempool.Pending(); len(pending) != 0 {
+		t.Fatalf("mempool after a refused image: %+v", pending)
+	}
+}
+
+// generate fetches an image generated from a model
+func (n *node) generate(token, query string) []byte {
+	n.t.Helper()
+
+	resp, err := n.Server.Client().Do(n.request("GET", "/api/generate-image?"+query, token, nil, ""))
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		n.t.Fatalf("generate: status %d, %s", resp.StatusCode, data)
+	}
+	return data
+}
+
+func (n *node) verifyImage(token string, data []byte) (api.VerifyImageResponse, int) {
+	n.t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "query")
+	part.Write(data)
+	writer.Close()
+
+	var resp api.VerifyImageResponse
+	status := n.do(n.request("POST", "/api/verify-image", token, &body, writer.FormDataContentType()), &resp)
+	return resp, status
+}
+
+// watermarked is a generated PNG as the node serves it, marked with the
+// start of blockHash
+func watermarked(t *testing.T, data []byte, blockHash string) []byte {
+	t.Helper()
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := hex.DecodeString(blockHash[:2*watermark.PayloadSize])
+	marked, err := watermark.Embed(img, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, marked)
+	return buf.Bytes()
+}
+
+// scale resizes img by f with bilinear interpolation, as an image viewer
+// or a screenshot of one would
+func scale(img image.Image, f float64) image.Image {
+	b := img.Bounds()
+	w, h := int(float64(b.Dx())*f), int(float64(b.Dy())*f)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	at := func(x, y int) [4]float64 {
+		x, y = min(max(x, 0), b.Dx()-1), min(max(y, 0), b.Dy()-1)
+		r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return [4]float64{float64(r >> 8), float64(g >> 8), float64(bl >> 8), float64(a >> 8)}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := (float64(x)+0.5)/f-0.5, (float64(y)+0.5)/f-0.5
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			p00, p10, p01, p11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+			var c [4]uint8
+			for i := range c {
+				v := (p00[i]*(1-fx)+p10[i]*fx)*(1-fy) + (p01[i]*(1-fx)+p11[i]*fx)*fy
+				c[i] = uint8(math.Round(v))
+			}
+			out.Set(x, y, color.RGBA{c[0], c[1], c[2], c[3]})
+		}
+	}
+	return out
+}
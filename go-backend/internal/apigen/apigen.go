@@ -0,0 +1,237 @@
+// Package apigen generates the Go client in package client from the OpenAPI
+// document in package api. It supports the subset of OpenAPI the API uses:
+// path and query parameters, JSON and multipart request bodies, and JSON
+// ($ref) or raw responses.
+package apigen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type document struct {
+	Paths map[string]map[string]operation `json:"paths"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]media `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]media `json:"content"`
+	} `json:"responses"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description"`
+	Schema      schema `json:"schema"`
+}
+
+type media struct {
+	Schema schema `json:"schema"`
+}
+
+type schema struct {
+	Ref  string `json:"$ref"`
+	Type string `json:"type"`
+}
+
+// typeName returns the api type a $ref points to
+func (s schema) typeName() string {
+	return "api." + strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// Generate returns the gofmt'd source of client_gen.go for the spec
+func Generate(spec []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var ops bytes.Buffer
+	for _, p := range paths {
+		methods := make([]string, 0, len(doc.Paths[p]))
+		for method := range doc.Paths[p] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			if err := writeOperation(&ops, strings.ToUpper(method), p, doc.Paths[p][method]); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), p, err)
+			}
+		}
+	}
+
+	// Only import what the operations use
+	imports := []string{"context", "net/http"}
+	for _, pkg := range []string{"net/url", "strconv"} {
+		if bytes.Contains(ops.Bytes(), []byte(path.Base(pkg)+".")) {
+			imports = append(imports, pkg)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by apigen from api/openapi.json. DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	for _, pkg := range imports {
+		fmt.Fprintf(&buf, "\t%q\n", pkg)
+	}
+	buf.WriteString("\n\t\"github.com/Kami0rn/ProjectCPE/go-backend/api\"\n)\n")
+	buf.Write(ops.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+func writeOperation(buf *bytes.Buffer, method, path string, op operation) error {
+	name := op.OperationID
+	if name == "" || !unicode.IsUpper(rune(name[0])) {
+		return fmt.Errorf("operationId %q must be an exported Go name", name)
+	}
+
+	args := []string{"ctx context.Context"}
+	pathExpr := `"` + path + `"`
+	var query []parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			args = append(args, lowerFirst(goName(p.Name))+" string")
+			pathExpr = strings.Replace(pathExpr, "{"+p.Name+"}", `" + url.PathEscape(`+lowerFirst(goName(p.Name))+`) + "`, 1)
+		case "query":
+			query = append(query, p)
+		default:
+			return fmt.Errorf("unsupported parameter location %q", p.In)
+		}
+	}
+	pathExpr = strings.TrimSuffix(pathExpr, ` + ""`)
+
+	// Query parameters become a params struct
+	queryExpr := "nil"
+	if len(query) > 0 {
+		paramsType := name + "Params"
+		fmt.Fprintf(buf, "\n// %s holds the query parameters of %s\ntype %s struct {\n", paramsType, name, paramsType)
+		for _, p := range query {
+			goType, err := scalarType(p.Schema)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %w", p.Name, err)
+			}
+			fmt.Fprintf(buf, "\t%s %s // %s\n", goName(p.Name), goType, p.Description)
+		}
+		buf.WriteString("}\n")
+
+		fmt.Fprintf(buf, "\nfunc (p %s) values() url.Values {\n\tq := url.Values{}\n", paramsType)
+		for _, p := range query {
+			field := "p." + goName(p.Name)
+			if p.Schema.Type == "integer" {
+				fmt.Fprintf(buf, "\tif %s != 0 {\n\t\tq.Set(%q, strconv.Itoa(%s))\n\t}\n", field, p.Name, field)
+			} else {
+				fmt.Fprintf(buf, "\tif %s != \"\" {\n\t\tq.Set(%q, %s)\n\t}\n", field, p.Name, field)
+			}
+		}
+		buf.WriteString("\treturn q\n}\n")
+
+		args = append(args, "params "+paramsType)
+		queryExpr = "params.values()"
+	}
+
+	bodyExpr := "nil"
+	if op.RequestBody != nil {
+		switch {
+		case op.RequestBody.Content["application/json"].Schema.Ref != "":
+			args = append(args, "body "+op.RequestBody.Content["application/json"].Schema.typeName())
+			bodyExpr = "jsonBody(body)"
+		case op.RequestBody.Content["multipart/form-data"] != (media{}):
+			args = append(args, "form Form")
+			bodyExpr = "form"
+		default:
+			return fmt.Errorf("unsupported request body")
+		}
+	}
+
+	// The lowest 2xx response is the result
+	var codes []string
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return fmt.Errorf("no 2xx response")
+	}
+	sort.Strings(codes)
+	result := "[]byte"
+	for ctype, m := range op.Responses[codes[0]].Content {
+		if ctype == "application/json" && m.Schema.Ref != "" {
+			result = m.Schema.typeName()
+		}
+	}
+
+	fmt.Fprintf(buf, "\n// %s calls %s %s: %s\n", name, method, path, op.Summary)
+	if result == "[]byte" {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) ([]byte, error) {\n", name, strings.Join(args, ", "))
+		fmt.Fprintf(buf, "\tvar out []byte\n\terr := c.do(ctx, %s, %s, %s, %s, &out)\n\treturn out, err\n}\n",
+			methodConst(method), pathExpr, queryExpr, bodyExpr)
+		return nil
+	}
+	fmt.Fprintf(buf, "func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), result)
+	fmt.Fprintf(buf, "\tvar out %s\n\tif err := c.do(ctx, %s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+		result, methodConst(method), pathExpr, queryExpr, bodyExpr)
+	return nil
+}
+
+func scalarType(s schema) (string, error) {
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+func methodConst(method string) string {
+	switch method {
+	case http.MethodGet:
+		return "http.MethodGet"
+	case http.MethodPost:
+		return "http.MethodPost"
+	case http.MethodPut:
+		return "http.MethodPut"
+	case http.MethodDelete:
+		return "http.MethodDelete"
+	}
+	return fmt.Sprintf("%q", method)
+}
+
+// goName turns model_name into ModelName
+func goName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
+
+const keygenUsage = `usage: go-backend keygen <file>
+
+Writes a new key to file, which must not exist, and prints its public key.
+Use it as a validator key, for the consensus validators list of every node,
+or as the node key signing the provenance of generated images.`
+
+// runKeygen implements the "keygen" subcommand
+func runKeygen(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, keygenUsage)
+		os.Exit(2)
+	}
+	key, err := consensus.WriteKey(args[0])
+	if err != nil {
+		logging.Fatal("failed to write key", "error", err)
+	}
+	fmt.Println(consensus.PublicKeyHex(key))
+}
@@ -1,36 +1,176 @@
 package main
 
 import (
-	"flag"
-	"log"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/aiclient"
+	"github.com/Kami0rn/ProjectCPE/go-backend/anchor"
 	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/consensus"
 	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/Kami0rn/ProjectCPE/go-backend/rewards"
 	"github.com/Kami0rn/ProjectCPE/go-backend/routes"
-	"github.com/joho/godotenv"
 )
 
-func init() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+func main() {
+	logging.Setup(os.Stdout, slog.LevelInfo)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		runKeygen(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+	level, _ := cfg.SlogLevel()
+	logging.Setup(os.Stdout, level)
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		logging.Fatal("failed to open database", "error", err)
+	}
+
+	// Development applies pending migrations on start; production expects
+	// them to be applied explicitly with "migrate up"
+	pending, err := database.PendingMigrations(db)
+	if err != nil {
+		logging.Fatal("failed to check migrations", "error", err)
+	}
+	if len(pending) > 0 {
+		if cfg.Env == config.EnvProduction {
+			logging.Fatal("pending migrations, run \"go-backend migrate up\" first", "pending", len(pending))
+		}
+		if _, err := database.MigrateUp(db); err != nil {
+			logging.Fatal("failed to migrate database", "error", err)
+		}
+		slog.Info("applied pending migrations", "count", len(pending))
+	}
+
+	// The node resumes from the state saved at the last shutdown, or from
+	// the genesis block
+	node := handlers.New(database.NewRepository(db), aiclient.New(cfg.AIServiceURL), cfg.DataDir)
+	authority, err := consensus.FromConfig(cfg.Consensus)
+	if err != nil {
+		logging.Fatal("invalid consensus configuration", "error", err)
+	}
+	if authority != nil {
+		node.UseAuthority(authority)
+		slog.Info("proof-of-authority consensus", "validators", len(cfg.Consensus.Validators), "self", authority.Self())
+	}
+	node.UseRewards(rewards.FromConfig(cfg.Rewards))
+	if err := node.LoadNodeKey(cfg.NodeKeyFile); err != nil {
+		logging.Fatal("failed to load node key", "error", err)
+	}
+	node.TrustedNodeKeys = cfg.TrustedNodeKeys
+	if err := node.LoadState(); err != nil {
+		logging.Fatal("failed to restore node state", "error", err)
+	}
+	if err := node.LoadAnchors(); err != nil {
+		logging.Fatal("failed to load anchors", "error", err)
+	}
+	if cfg.Anchor.Node != "" {
+		node.Anchors = anchor.New(cfg.Anchor.Node)
+		slog.Info("anchoring blocks", "node", cfg.Anchor.Node, "interval", time.Duration(cfg.Anchor.Interval).String())
 	}
+	if err := node.LoadPeers(cfg.BootstrapPeers); err != nil {
+		logging.Fatal("failed to load peers", "error", err)
+	}
+
+	serve(cfg, node)
+
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	slog.Info("server stopped")
 }
 
-func main() {
-	// Add a flag for the port
-	port := flag.String("port", "8080", "Port to run the server on")
-	flag.Parse()
+// serve runs the HTTP server until SIGINT or SIGTERM, then drains it: new
+// connections are refused, in-flight requests get cfg.ShutdownTimeout to
+// finish before their contexts (and the AI calls made with them) are
+// cancelled, and the node state is saved.
+func serve(cfg *config.Config, node *handlers.Handler) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	srv := &http.Server{
+		Addr:        ":" + cfg.Port,
+		Handler:     routes.NewHandler(cfg, node),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
 
-	config.LoadEnv()
-	database.ConnectDB()
+	// The first health check also catches up with peers that moved on
+	// while this node was down
+	checksDone := make(chan struct{})
+	go func() {
+		defer close(checksDone)
+		node.RunPeerHealthChecks(ctx, time.Duration(cfg.PeerCheckInterval), cfg.PeerMaxFailures)
+	}()
+
+	producerDone := make(chan struct{})
+	go func() {
+		defer close(producerDone)
+		node.RunBlockProducer(ctx)
+	}()
+
+	anchorDone := make(chan struct{})
+	go func() {
+		defer close(anchorDone)
+		if node.Anchors != nil {
+			node.RunAnchoring(ctx, time.Duration(cfg.Anchor.Interval))
+		}
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "port", cfg.Port, "env", cfg.Env)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("failed to start server", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := time.Duration(cfg.ShutdownTimeout)
+	slog.Info("shutting down", "timeout", timeout.String())
+	deadline := time.Now().Add(timeout)
+	shutdownCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests still running at the shutdown timeout, cancelling them", "error", err)
+		cancelRequests()
+		srv.Close()
+	}
 
-	// Initialize the genesis block
-	blockchain.InitGenesisBlock()
+	<-checksDone
+	<-producerDone
+	<-anchorDone
 
-	// Start the Gin HTTP server
-	r := routes.SetupRouter()
-	if err := r.Run(":" + *port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Broadcasts get whatever is left of the timeout, at least a moment
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), max(time.Until(deadline), time.Second))
+	defer cancelDrain()
+	if err := node.Shutdown(drainCtx); err != nil {
+		slog.Error("failed to save node state", "error", err)
 	}
 }
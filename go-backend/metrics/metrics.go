@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors of one node. Each node has its own
+// registry so several nodes can run in one process (the integration tests do).
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	RequestDuration   *prometheus.HistogramVec
+	BroadcastFailures prometheus.Counter
+	TrainingDuration  prometheus.Histogram
+	AICallErrors      *prometheus.CounterVec
+}
+
+// Gauges are read on every scrape from the node's live state
+type Gauges struct {
+	ChainHeight func() int
+	MempoolSize func() int
+	PeerCount   func() int
+	PeerLag     func() map[string]int // blocks each peer has not acknowledged
+}
+
+func New(gauges Gauges) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		BroadcastFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "block_broadcast_failures_total",
+			Help: "Block broadcasts to a peer that failed.",
+		}),
+		TrainingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "training_job_duration_seconds",
+			Help:    "Duration of training requests to the AI service.",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		}),
+		AICallErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ai_service_errors_total",
+			Help: "Failed calls to the AI service by endpoint.",
+		}, []string{"endpoint"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.RequestDuration,
+		m.BroadcastFailures,
+		m.TrainingDuration,
+		m.AICallErrors,
+		gaugeFunc("blockchain_height", "Number of blocks in the local chain.", gauges.ChainHeight),
+		gaugeFunc("mempool_transactions", "Transactions waiting for the next block.", gauges.MempoolSize),
+		gaugeFunc("peers", "Number of known peers.", gauges.PeerCount),
+		&labeledGaugeFunc{
+			desc: prometheus.NewDesc("peer_replication_lag_blocks", "Blocks of the local chain a peer has not acknowledged.", []string{"peer"}, nil),
+			read: gauges.PeerLag,
+		},
+	)
+	return m
+}
+
+func gaugeFunc(name, help string, f func() int) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+		return float64(f())
+	})
+}
+
+// labeledGaugeFunc is a gauge per label value, read on every scrape
+type labeledGaugeFunc struct {
+	desc *prometheus.Desc
+	read func() map[string]int
+}
+
+func (g *labeledGaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *labeledGaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	for label, value := range g.read() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(value), label)
+	}
+}
+
+// Middleware records the latency of every request under its route pattern,
+// so /api/orgs/:org/members is one series however many organisations exist.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.RequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin only lets the configured admin users through. It must run
+// after JWTAuthMiddleware.
+func RequireAdmin(admins []string) gin.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, admin := range admins {
+		allowed[admin] = true
+	}
+
+	return func(c *gin.Context) {
+		if !allowed[c.GetString("username")] {
+			apperr.Abort(c, apperr.New(apperr.CodeForbidden, "Admin access required"))
+			return
+		}
+		c.Next()
+	}
+}
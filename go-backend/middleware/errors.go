@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders the last error recorded with apperr.Abort as an
+// api.ErrorResponse. Server-side failures are logged with their cause; the
+// client only sees the message and code.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		status, body := apperr.Response(err, c.GetString("request_id"))
+		if status >= 500 {
+			logging.FromContext(c.Request.Context()).Error("request failed", "status", status, "error", err)
+		}
+		c.JSON(status, body)
+	}
+}
+
+// Recover turns a panic into an internal error rendered by Errors
+func Recover() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		apperr.Abort(c, apperr.Wrap(apperr.CodeInternal, "Internal server error", panicError{recovered}))
+	})
+}
+
+type panicError struct{ value any }
+
+func (p panicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
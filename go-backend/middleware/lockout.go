@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockoutStore tracks failed login attempts per key, an account and the client
+// IP trying it (see LockoutKey). Like RateLimitStore it has an in-memory
+// implementation and can be backed by a shared store instead.
+type LockoutStore interface {
+	// Locked reports whether key is locked and for how much longer.
+	Locked(key string, now time.Time) (bool, time.Duration)
+	// Fail records a failed attempt and returns the lock duration if this
+	// attempt locked the account, or zero otherwise.
+	Fail(key string, now time.Time) time.Duration
+	// Reset clears the failures after a successful login.
+	Reset(key string)
+}
+
+type lockoutEntry struct {
+	failures    int
+	firstFail   time.Time
+	lockedUntil time.Time
+}
+
+// MemoryLockoutStore locks an account for LockFor after MaxFailures failed
+// attempts inside Window.
+type MemoryLockoutStore struct {
+	MaxFailures int
+	Window      time.Duration
+	LockFor     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+	ops     int
+}
+
+// LockoutKey is the key of the failed logins of username from ip. Keying on
+// the account alone would let anyone lock any account out.
+func LockoutKey(username, ip string) string {
+	return strings.ToLower(username) + "|" + ip
+}
+
+func NewMemoryLockoutStore(maxFailures int, window, lockFor time.Duration) *MemoryLockoutStore {
+	return &MemoryLockoutStore{
+		MaxFailures: maxFailures,
+		Window:      window,
+		LockFor:     lockFor,
+		entries:     map[string]*lockoutEntry{},
+	}
+}
+
+func (s *MemoryLockoutStore) Locked(key string, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !now.Before(e.lockedUntil) {
+		return false, 0
+	}
+	return true, e.lockedUntil.Sub(now)
+}
+
+func (s *MemoryLockoutStore) Fail(key string, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops++
+	if s.ops%1024 == 0 {
+		s.sweep(now)
+	}
+
+	e, ok := s.entries[key]
+	if !ok || now.Sub(e.firstFail) > s.Window {
+		e = &lockoutEntry{firstFail: now}
+		s.entries[key] = e
+	}
+
+	e.failures++
+	if e.failures >= s.MaxFailures {
+		e.lockedUntil = now.Add(s.LockFor)
+		e.failures = 0
+		e.firstFail = now
+		return s.LockFor
+	}
+	return 0
+}
+
+// sweep drops the entries whose failures have expired and that are not
+// locked, such as those of usernames nobody has
+func (s *MemoryLockoutStore) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if now.Sub(e.firstFail) > s.Window && !now.Before(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func (s *MemoryLockoutStore) Reset(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// NewLoginLockout returns the policy used by the login handler: 5 failures
+// in 15 minutes from one IP lock the account for that IP for 15 minutes.
+func NewLoginLockout() LockoutStore {
+	return NewMemoryLockoutStore(5, 15*time.Minute, 15*time.Minute)
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit should be enforced.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// RateLimitStore keeps the token buckets. MemoryRateLimitStore is enough for a
+// single node; a shared store (Redis, the database, ...) can implement the same
+// interface when several backends sit behind one load balancer.
+type RateLimitStore interface {
+	// Take removes one token from the bucket identified by key. When the bucket
+	// is empty it returns false and how long until a token is available.
+	Take(key string, limit Limit, now time.Time) (bool, time.Duration)
+}
+
+// RateLimitConfig configures the limits for one route group.
+type RateLimitConfig struct {
+	Name    string // prefix for bucket keys so groups do not share buckets
+	PerIP   Limit
+	PerUser Limit // only applied when JWTAuthMiddleware ran first
+	Store   RateLimitStore
+}
+
+// RateLimiter returns a middleware enforcing the per-IP and per-user buckets in cfg.
+func RateLimiter(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Store == nil {
+		cfg.Store = DefaultRateLimitStore
+	}
+
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		if cfg.PerIP.Enabled() {
+			if ok, wait := cfg.Store.Take(cfg.Name+"|ip|"+c.ClientIP(), cfg.PerIP, now); !ok {
+				abortTooManyRequests(c, wait)
+				return
+			}
+		}
+
+		if username := c.GetString("username"); username != "" && cfg.PerUser.Enabled() {
+			if ok, wait := cfg.Store.Take(cfg.Name+"|user|"+username, cfg.PerUser, now); !ok {
+				abortTooManyRequests(c, wait)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func abortTooManyRequests(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	apperr.Abort(c, apperr.New(apperr.CodeRateLimited, "Too many requests, slow down"))
+}
+
+// BodyLimit caps the size of the request body. Handlers reading past the limit
+// get an error from the reader, which surfaces as a failed bind or form parse.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			apperr.Abort(c, apperr.New(apperr.CodePayloadTooLarge, "Request body too large"))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// DefaultRateLimitStore is shared by every limiter that does not set its own store.
+var DefaultRateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	ops     int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops++
+	if s.ops%1024 == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time elapsed since the last request
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets idle for more than ten minutes; by then they are full again.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > 10*time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
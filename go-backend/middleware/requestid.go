@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Incoming IDs are echoed into logs and headers, so only accept plain tokens
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID takes the X-Request-ID of the request or generates one, stores
+// it in the request context and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.HeaderRequestID)
+		if !requestIDPattern.MatchString(id) {
+			id = logging.NewRequestID()
+		}
+
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Set("request_id", id)
+		c.Header(logging.HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+// AccessLog writes one structured log line per request
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logging.FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+			"user", c.GetString("username"),
+		)
+	}
+}
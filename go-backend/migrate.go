@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/database"
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+)
+
+const migrateUsage = `usage: go-backend migrate <up|down [N]|status> [config flags]
+
+  up      apply every pending migration
+  down    revert the last migration, or the last N
+  status  list migrations and when they were applied`
+
+// runMigrate implements the "migrate" subcommand
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+
+	// down takes an optional step count, everything else goes to the config loader
+	steps := 1
+	if command == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			steps, args = n, args[1:]
+		}
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		logging.Fatal("failed to open database", "error", err)
+	}
+
+	switch command {
+	case "up":
+		n, err := database.MigrateUp(db)
+		if err != nil {
+			logging.Fatal("migration failed", "applied", n, "error", err)
+		}
+		slog.Info("migrations applied", "count", n)
+	case "down":
+		n, err := database.MigrateDown(db, steps)
+		if err != nil {
+			logging.Fatal("migration failed", "reverted", n, "error", err)
+		}
+		slog.Info("migrations reverted", "count", n)
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			logging.Fatal("failed to read migration status", "error", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-32s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
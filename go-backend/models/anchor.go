@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Anchor records that the blocks FromIndex through ToIndex were anchored in
+// the UTXO chain by the transaction TxID, with the Merkle root Root of
+// their hashes (see blockchain.AnchorRoot)
+type Anchor struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	FromIndex int       `gorm:"not null" json:"from_index"`
+	ToIndex   int       `gorm:"index;not null" json:"to_index"`
+	Root      string    `gorm:"not null" json:"root"`  // hex
+	TxID      string    `gorm:"not null" json:"tx_id"` // hex, in the UTXO chain
+	CreatedAt time.Time `json:"created_at"`
+}
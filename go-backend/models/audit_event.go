@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditLogin        = "login"
+	AuditLoginFailed  = "login_failed"
+	AuditRegister     = "register"
+	AuditMine         = "mine"
+	AuditAddPeer      = "add_peer"
+	AuditRemovePeer   = "remove_peer"
+	AuditEvictPeer    = "evict_peer"
+	AuditOrgCreate    = "org_create"
+	AuditMemberSet    = "org_member_set"
+	AuditMemberRemove = "org_member_remove"
+	// AuditValidatorChange is a validator set change queued by an admin
+	AuditValidatorChange = "validator_change"
+	// AuditSigningKey is a user registering or replacing their signing key
+	AuditSigningKey = "signing_key_set"
+	// AuditLicence is a model owner setting the licence of a model
+	AuditLicence = "model_licence_set"
+)
+
+// AuditEvent is one row of the append-only audit trail of security-relevant actions
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Action    string    `gorm:"not null" json:"action"`
+	Actor     string    `json:"actor"`  // username, or the attempted one for failed logins
+	Target    string    `json:"target"` // what was acted on: block hash, peer, organisation...
+	Success   bool      `json:"success"`
+	ClientIP  string    `json:"client_ip"`
+	RequestID string    `json:"request_id"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// GeneratedImage is an image generated from a model, kept in the blob
+// store under Hash. The image is watermarked with BlockHash, the hash of
+// the block the model was mined in; Seed reproduces it.
+type GeneratedImage struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Hash        string    `gorm:"index;not null" json:"hash"` // hex SHA-256 of the PNG
+	ModelID     uint      `gorm:"index;not null" json:"model_id"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	Seed        int64     `json:"seed"`
+	RequestedBy string    `gorm:"not null" json:"requested_by"`
+	RequestID   string    `json:"request_id"`
+	Size        int       `json:"size"` // bytes
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -8,4 +8,5 @@ type Model struct {
     CreatedBy string    `json:"created_by"`  // Username of the creator
     CreatedAt time.Time `json:"created_at"`  // Timestamp of creation
     Hash      string    `json:"hash"`        // Hash of the block
+    Organization string `json:"organization,omitempty"` // Owning organisation, empty for personal models
 }
\ No newline at end of file
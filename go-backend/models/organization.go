@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Roles a member can hold inside an organisation
+const (
+	RoleOwner  = "owner"  // manages members and everything an admin can do
+	RoleAdmin  = "admin"  // adds and removes members
+	RoleMember = "member" // trains and generates with the organisation's models
+)
+
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"unique;not null" json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationWithRole is an organisation together with the caller's role in it
+type OrganizationWithRole struct {
+	Organization
+	Role string `json:"role"`
+}
+
+type Membership struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"uniqueIndex:idx_membership_org_user;not null" json:"organization_id"`
+	Username       string    `gorm:"uniqueIndex:idx_membership_org_user;not null" json:"username"`
+	Role           string    `gorm:"not null" json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
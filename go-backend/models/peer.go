@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Peer is a node this node replicates with. Peers are persisted so the set
+// survives restarts, and carry the result of the periodic health checks.
+type Peer struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Address    string     `gorm:"uniqueIndex;not null" json:"address"` // normalised base URL
+	Bootstrap  bool       `json:"bootstrap"`                           // from configuration, never evicted
+	Failures   int        `json:"failures"`                            // consecutive failed health checks
+	LastError  string     `json:"last_error,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -1,16 +1,13 @@
 package models
 
-import (
-    "time"
-
-    "gorm.io/gorm"
-)
+import "time"
 
 type User struct {
-	gorm.Model
-    ID           uint      `gorm:"primaryKey"`
-    Username     string    `gorm:"unique;not null"`
-    PasswordHash string    `gorm:"not null"`
-    Email        string    `gorm:"unique"` // Optional: Add email field
-    CreatedAt    time.Time
-}
\ No newline at end of file
+	ID           uint    `gorm:"primaryKey"`
+	Username     string  `gorm:"unique;not null"`
+	PasswordHash string  `gorm:"not null"`
+	Email        *string `gorm:"unique"` // optional, NULL when not given so that users without one do not collide
+	SigningKey   string  // public key for dataset claims, see blockchain.ParseSigningKey
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,117 @@
+package provenance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var jpegSOI = []byte{0xff, 0xd8}
+
+const (
+	jpegAPP0  = 0xe0
+	jpegAPP1  = 0xe1
+	jpegAPP11 = 0xeb
+	jpegSOS   = 0xda
+	jpegEOI   = 0xd9
+)
+
+// jpegIdentifier starts the APP11 segment holding the manifest, as C2PA
+// keeps its manifest store in APP11
+var jpegIdentifier = []byte("projectcpe.provenance\x00")
+
+// jpegContainer keeps the manifest in an APP11 segment after the leading
+// APP0 (JFIF) and APP1 (Exif) segments
+type jpegContainer struct{}
+
+// jpegSegment is one marker segment of the file header, before the scan
+type jpegSegment struct {
+	marker byte
+	data   []byte // the whole segment, marker and length included
+	body   []byte // what follows the length
+}
+
+// jpegSegments splits the header of a JPEG, SOI excluded, into its segments
+// up to the first scan, and returns the rest from the scan on
+func jpegSegments(data []byte) ([]jpegSegment, []byte, error) {
+	var segments []jpegSegment
+	rest := data[len(jpegSOI):]
+	for {
+		// Markers may be padded with fill bytes
+		start := rest
+		for len(rest) > 1 && rest[0] == 0xff && rest[1] == 0xff {
+			rest = rest[1:]
+		}
+		if len(rest) < 2 || rest[0] != 0xff {
+			return nil, nil, fmt.Errorf("%w: JPEG marker expected", ErrCorrupt)
+		}
+		marker := rest[1]
+		if marker == jpegSOS || marker == jpegEOI {
+			return segments, start, nil
+		}
+		if len(rest) < 4 {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG segment", ErrCorrupt)
+		}
+		n := int(binary.BigEndian.Uint16(rest[2:]))
+		if n < 2 || 2+n > len(rest) {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG segment", ErrCorrupt)
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: start[:len(start)-len(rest)+2+n], body: rest[4 : 2+n]})
+		rest = rest[2+n:]
+	}
+}
+
+func (jpegContainer) split(data []byte) (content, manifest []byte, err error) {
+	segments, scan, err := jpegSegments(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	content = append(content, jpegSOI...)
+	for _, s := range segments {
+		if text, ok := jpegManifest(s); ok {
+			if manifest != nil {
+				return nil, nil, fmt.Errorf("%w: more than one manifest", ErrMalformed)
+			}
+			manifest = text
+			continue
+		}
+		content = append(content, s.data...)
+	}
+	return append(content, scan...), manifest, nil
+}
+
+// jpegManifest returns the manifest in s if it is our APP11 segment
+func jpegManifest(s jpegSegment) ([]byte, bool) {
+	if s.marker != jpegAPP11 {
+		return nil, false
+	}
+	if !bytes.HasPrefix(s.body, jpegIdentifier) {
+		return nil, false
+	}
+	return s.body[len(jpegIdentifier):], true
+}
+
+func (jpegContainer) insert(content, manifest []byte) ([]byte, error) {
+	n := 2 + len(jpegIdentifier) + len(manifest)
+	if n > 0xffff {
+		return nil, fmt.Errorf("manifest of %d bytes does not fit a JPEG segment", len(manifest))
+	}
+	segments, _, err := jpegSegments(content)
+	if err != nil {
+		return nil, err
+	}
+	at := len(jpegSOI)
+	for _, s := range segments {
+		if s.marker != jpegAPP0 && s.marker != jpegAPP1 {
+			break
+		}
+		at += len(s.data)
+	}
+
+	out := make([]byte, 0, len(content)+2+n)
+	out = append(out, content[:at]...)
+	out = append(out, 0xff, jpegAPP11, byte(n>>8), byte(n))
+	out = append(out, jpegIdentifier...)
+	out = append(out, manifest...)
+	return append(out, content[at:]...), nil
+}
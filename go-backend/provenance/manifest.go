@@ -0,0 +1,164 @@
+// Package provenance attaches a signed manifest to generated images, telling
+// which model made them, the block it was mined in and which node served
+// them. It follows the shape of a C2PA manifest: a claim made by a claim
+// generator, hard-bound to the image by the hash of the file without the
+// manifest, and signed by the generator's key. The manifest travels in the
+// file's metadata, an iTXt chunk in PNG and an APP11 segment in JPEG, so
+// unlike the watermark it is lost when the image is re-encoded.
+package provenance
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Format names this version of the manifest
+const Format = "projectcpe.provenance/1"
+
+// Generator is the claim generator of the manifests this package makes
+const Generator = "go-backend"
+
+var (
+	ErrNoManifest = errors.New("image carries no provenance manifest")
+	// ErrMalformed means the manifest cannot be parsed
+	ErrMalformed = errors.New("malformed provenance manifest")
+	// ErrUnsupportedFormat means the file is neither PNG nor JPEG
+	ErrUnsupportedFormat = errors.New("not a PNG or JPEG file")
+	// ErrCorrupt means the PNG or JPEG file cannot be parsed
+	ErrCorrupt      = errors.New("corrupt PNG or JPEG file")
+	ErrBadSignature = errors.New("manifest signature does not verify")
+	// ErrModified means the image changed since the manifest was signed
+	ErrModified = errors.New("image does not match its manifest")
+)
+
+// Claim is what a manifest asserts about an image
+type Claim struct {
+	Format         string `json:"format"`
+	ClaimGenerator string `json:"claim_generator"`
+	// The model that generated the image, and the block it was mined in
+	ModelID      uint   `json:"model_id"`
+	ModelName    string `json:"model_name"`
+	Owner        string `json:"owner"` // creator of the model, or its organisation
+	Organization bool   `json:"organization,omitempty"`
+	BlockHash    string `json:"block_hash"`
+	// NodeKey is the hex ed25519 public key of the node that signed
+	NodeKey   string    `json:"node_key"`
+	Timestamp time.Time `json:"timestamp"`
+	// ContentHash binds the claim to the image: the hex SHA-256 of the file
+	// with the manifest taken out
+	ContentHash string `json:"content_hash"`
+}
+
+// Manifest is a claim and the signature of its JSON encoding by NodeKey
+type Manifest struct {
+	Claim     Claim  `json:"claim"`
+	Signature string `json:"signature"`
+}
+
+// Attach returns the PNG or JPEG file data carrying a manifest for claim,
+// signed with key, in place of any it had. Format, ClaimGenerator, NodeKey
+// and ContentHash are filled in.
+func Attach(data []byte, claim Claim, key ed25519.PrivateKey) ([]byte, error) {
+	c, err := containerOf(data)
+	if err != nil {
+		return nil, err
+	}
+	content, _, err := c.split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	claim.Format = Format
+	claim.ClaimGenerator = Generator
+	claim.NodeKey = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	sum := sha256.Sum256(content)
+	claim.ContentHash = hex.EncodeToString(sum[:])
+	signed, err := json.Marshal(claim)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := json.Marshal(Manifest{Claim: claim, Signature: hex.EncodeToString(ed25519.Sign(key, signed))})
+	if err != nil {
+		return nil, err
+	}
+	return c.insert(content, manifest)
+}
+
+// Read returns the manifest of the PNG or JPEG file data and checks it: a
+// manifest that parses is returned along with ErrBadSignature if its
+// signature does not verify, or ErrModified if the file has changed since
+// it was signed. The signature only shows that whoever holds the key in
+// the claim signed it; whether that is a node to trust is for the caller
+// to decide.
+func Read(data []byte) (Manifest, error) {
+	c, err := containerOf(data)
+	if err != nil {
+		return Manifest{}, err
+	}
+	content, raw, err := c.split(data)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if raw == nil {
+		return Manifest{}, ErrNoManifest
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Manifest{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if m.Claim.Format != Format {
+		return Manifest{}, fmt.Errorf("%w: format %q, want %q", ErrMalformed, m.Claim.Format, Format)
+	}
+
+	key, err := hex.DecodeString(m.Claim.NodeKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return m, fmt.Errorf("%w: node key %q is not %d hex-encoded bytes", ErrBadSignature, m.Claim.NodeKey, ed25519.PublicKeySize)
+	}
+	sig, err := hex.DecodeString(m.Signature)
+	signed, _ := json.Marshal(m.Claim)
+	if err != nil || !ed25519.Verify(key, signed, sig) {
+		return m, ErrBadSignature
+	}
+
+	sum := sha256.Sum256(content)
+	if m.Claim.ContentHash != hex.EncodeToString(sum[:]) {
+		return m, ErrModified
+	}
+	return m, nil
+}
+
+// Strip returns the PNG or JPEG file data without its manifest
+func Strip(data []byte) ([]byte, error) {
+	c, err := containerOf(data)
+	if err != nil {
+		return nil, err
+	}
+	content, _, err := c.split(data)
+	return content, err
+}
+
+// container is a file format that can carry a manifest
+type container interface {
+	// split takes the manifest out of data, returning the rest of the file
+	// and the manifest, nil if there is none
+	split(data []byte) (content, manifest []byte, err error)
+	// insert puts manifest into content, which carries none
+	insert(content, manifest []byte) ([]byte, error)
+}
+
+func containerOf(data []byte) (container, error) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return pngContainer{}, nil
+	case bytes.HasPrefix(data, jpegSOI):
+		return jpegContainer{}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
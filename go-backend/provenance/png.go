@@ -0,0 +1,89 @@
+package provenance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngKeyword is the keyword of the iTXt chunk holding the manifest
+const pngKeyword = "projectcpe.provenance"
+
+// pngContainer keeps the manifest in an uncompressed iTXt chunk right after
+// IHDR
+type pngContainer struct{}
+
+func (pngContainer) split(data []byte) (content, manifest []byte, err error) {
+	content = append(content, pngSignature...)
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, nil, fmt.Errorf("%w: truncated PNG chunk", ErrCorrupt)
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n) > uint64(len(rest)-12) {
+			return nil, nil, fmt.Errorf("%w: truncated PNG chunk", ErrCorrupt)
+		}
+		chunk := rest[:12+n]
+		rest = rest[12+n:]
+
+		if text, ok := pngManifest(chunk); ok {
+			if manifest != nil {
+				return nil, nil, fmt.Errorf("%w: more than one manifest", ErrMalformed)
+			}
+			manifest = text
+			continue
+		}
+		content = append(content, chunk...)
+	}
+	return content, manifest, nil
+}
+
+// pngManifest returns the text of chunk if it is our iTXt chunk
+func pngManifest(chunk []byte) ([]byte, bool) {
+	if string(chunk[4:8]) != "iTXt" {
+		return nil, false
+	}
+	// keyword NUL, compression flag and method, language tag NUL,
+	// translated keyword NUL, text
+	fields := bytes.SplitN(chunk[8:len(chunk)-4], []byte{0}, 2)
+	if len(fields) != 2 || string(fields[0]) != pngKeyword || len(fields[1]) < 2 || fields[1][0] != 0 {
+		return nil, false
+	}
+	rest := fields[1][2:]
+	for range 2 {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return nil, false
+		}
+		rest = rest[i+1:]
+	}
+	return rest, true
+}
+
+func (pngContainer) insert(content, manifest []byte) ([]byte, error) {
+	// IHDR must come first
+	at := len(pngSignature)
+	if len(content) < at+12 || string(content[at+4:at+8]) != "IHDR" {
+		return nil, fmt.Errorf("%w: PNG does not start with IHDR", ErrCorrupt)
+	}
+	at += 12 + int(binary.BigEndian.Uint32(content[at:]))
+
+	var body []byte
+	body = append(body, pngKeyword...)
+	body = append(body, 0, 0, 0, 0, 0) // NUL, uncompressed, method, no language or translation
+	body = append(body, manifest...)
+
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(len(body)))
+	chunk.WriteString("iTXt")
+	chunk.Write(body)
+	binary.Write(&chunk, binary.BigEndian, crc32.ChecksumIEEE(chunk.Bytes()[4:]))
+
+	out := make([]byte, 0, len(content)+chunk.Len())
+	out = append(out, content[:at]...)
+	out = append(out, chunk.Bytes()...)
+	return append(out, content[at:]...), nil
+}
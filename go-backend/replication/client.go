@@ -0,0 +1,170 @@
+package replication
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/url"
+	"sync"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	pb "github.com/Kami0rn/ProjectCPE/go-backend/replication/replicationpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client talks to peers' ReplicationService. Peers are identified by the
+// base URL of their REST API; gRPC is served on the same port, in
+// cleartext HTTP/2 for http peers and over TLS for https peers. One
+// connection per peer is kept open until Close.
+type Client struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func NewClient() *Client {
+	return &Client{conns: make(map[string]*grpc.ClientConn)}
+}
+
+func (c *Client) service(peer string) (pb.ReplicationServiceClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[peer]; ok {
+		return pb.NewReplicationServiceClient(conn), nil
+	}
+
+	u, err := url.Parse(peer)
+	if err != nil || u.Host == "" {
+		return nil, blockchain.ErrInvalidPeer
+	}
+	creds := insecure.NewCredentials()
+	if u.Scheme == "https" {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient(u.Host,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(clientRequestID),
+		grpc.WithChainStreamInterceptor(clientStreamRequestID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[peer] = conn
+	return pb.NewReplicationServiceClient(conn), nil
+}
+
+// AnnounceBlock implements blockchain.Transport
+func (c *Client) AnnounceBlock(ctx context.Context, peer string, block blockchain.Block) (blockchain.Ack, error) {
+	service, err := c.service(peer)
+	if err != nil {
+		return blockchain.Ack{}, err
+	}
+	resp, err := service.AnnounceBlock(ctx, &pb.AnnounceBlockRequest{Block: toProtoBlock(block)})
+	if err != nil {
+		return blockchain.Ack{}, err
+	}
+	return blockchain.Ack{Accepted: resp.GetAccepted(), Height: int(resp.GetHeight())}, nil
+}
+
+// RelayTransaction implements blockchain.Transport
+func (c *Client) RelayTransaction(ctx context.Context, peer string, tx blockchain.Transaction) error {
+	service, err := c.service(peer)
+	if err != nil {
+		return err
+	}
+	_, err = service.RelayTransaction(ctx, &pb.RelayTransactionRequest{Transaction: toProtoTransaction(tx)})
+	return err
+}
+
+// ChainStatus describes the tip of a peer's chain
+type ChainStatus struct {
+	Height      int
+	TipHash     string
+	MempoolSize int
+}
+
+func (c *Client) ChainStatus(ctx context.Context, peer string) (ChainStatus, error) {
+	service, err := c.service(peer)
+	if err != nil {
+		return ChainStatus{}, err
+	}
+	resp, err := service.GetChainStatus(ctx, &pb.GetChainStatusRequest{})
+	if err != nil {
+		return ChainStatus{}, err
+	}
+	return ChainStatus{
+		Height:      int(resp.GetHeight()),
+		TipHash:     resp.GetTipHash(),
+		MempoolSize: int(resp.GetMempoolSize()),
+	}, nil
+}
+
+// Header identifies a block without its transactions
+type Header struct {
+	Index    int
+	Hash     string
+	PrevHash string
+}
+
+// Headers returns the headers of blocks from..to (to = 0 means the tip).
+// The peer may return fewer than asked for; page on from.
+func (c *Client) Headers(ctx context.Context, peer string, from, to int) ([]Header, error) {
+	service, err := c.service(peer)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := service.GetHeaders(ctx, &pb.GetHeadersRequest{From: int64(from), To: int64(to)})
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]Header, len(resp.GetHeaders()))
+	for i, h := range resp.GetHeaders() {
+		headers[i] = Header{Index: int(h.GetIndex()), Hash: h.GetHash(), PrevHash: h.GetPrevHash()}
+	}
+	return headers, nil
+}
+
+// Blocks streams blocks from..to (to = 0 means the tip) from peer
+func (c *Client) Blocks(ctx context.Context, peer string, from, to int) ([]blockchain.Block, error) {
+	service, err := c.service(peer)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := service.GetBlocks(ctx, &pb.GetBlocksRequest{From: int64(from), To: int64(to)})
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []blockchain.Block
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		block, err := fromProtoBlock(resp.GetBlock())
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// Close closes the connections to all peers
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for peer, conn := range c.conns {
+		errs = append(errs, conn.Close())
+		delete(c.conns, peer)
+	}
+	return errors.Join(errs...)
+}
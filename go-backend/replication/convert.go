@@ -0,0 +1,147 @@
+package replication
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	pb "github.com/Kami0rn/ProjectCPE/go-backend/replication/replicationpb"
+)
+
+func toProtoBlock(b blockchain.Block) *pb.Block {
+	txs := make([]*pb.Transaction, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		txs[i] = toProtoTransaction(tx)
+	}
+	return &pb.Block{
+		Version:       uint32(b.Version),
+		Index:         int64(b.Index),
+		Timestamp:     b.Timestamp.Format(time.RFC3339Nano),
+		Transactions:  txs,
+		PrevHash:      b.PrevHash,
+		Hash:          b.Hash,
+		Proof:         b.Proof,
+		TrainingProof: toProtoTrainingProof(b.TrainingProof),
+		Validator:     b.Validator,
+		Signature:     b.Signature,
+	}
+}
+
+func fromProtoBlock(b *pb.Block) (blockchain.Block, error) {
+	if b == nil {
+		return blockchain.Block{}, fmt.Errorf("missing block")
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, b.GetTimestamp())
+	if err != nil {
+		return blockchain.Block{}, fmt.Errorf("block %d: %w", b.GetIndex(), err)
+	}
+
+	var txs []blockchain.Transaction
+	for _, tx := range b.GetTransactions() {
+		txs = append(txs, fromProtoTransaction(tx))
+	}
+	return blockchain.Block{
+		Version:       int(b.GetVersion()),
+		Index:         int(b.GetIndex()),
+		Timestamp:     timestamp,
+		Transactions:  txs,
+		PrevHash:      b.GetPrevHash(),
+		Hash:          b.GetHash(),
+		Proof:         b.GetProof(),
+		TrainingProof: fromProtoTrainingProof(b.GetTrainingProof()),
+		Validator:     b.GetValidator(),
+		Signature:     b.GetSignature(),
+	}, nil
+}
+
+func toProtoTrainingProof(p *blockchain.TrainingProof) *pb.TrainingProof {
+	if p == nil {
+		return nil
+	}
+	hp := p.Hyperparameters
+	return &pb.TrainingProof{
+		Owner:       p.Owner,
+		ModelName:   p.ModelName,
+		WeightsHash: p.WeightsHash,
+		DatasetRoot: p.DatasetRoot,
+		Seed:        p.Seed,
+		Hyperparameters: &pb.Hyperparameters{
+			Epochs:    int32(hp.Epochs),
+			LatentDim: int32(hp.LatentDim),
+			BatchSize: int32(hp.BatchSize),
+			Lr:        hp.LearningRate,
+			NCritic:   int32(hp.NCritic),
+			LambdaGp:  hp.LambdaGP,
+		},
+		SampleHash: p.SampleHash,
+	}
+}
+
+func fromProtoTrainingProof(p *pb.TrainingProof) *blockchain.TrainingProof {
+	if p == nil {
+		return nil
+	}
+	hp := p.GetHyperparameters()
+	return &blockchain.TrainingProof{
+		Owner:       p.GetOwner(),
+		ModelName:   p.GetModelName(),
+		WeightsHash: p.GetWeightsHash(),
+		DatasetRoot: p.GetDatasetRoot(),
+		Seed:        p.GetSeed(),
+		Hyperparameters: blockchain.Hyperparameters{
+			Epochs:       int(hp.GetEpochs()),
+			LatentDim:    int(hp.GetLatentDim()),
+			BatchSize:    int(hp.GetBatchSize()),
+			LearningRate: hp.GetLr(),
+			NCritic:      int(hp.GetNCritic()),
+			LambdaGP:     hp.GetLambdaGp(),
+		},
+		SampleHash: p.GetSampleHash(),
+	}
+}
+
+func toProtoTransaction(tx blockchain.Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Sender:       tx.Sender,
+		Receiver:     tx.Receiver,
+		Amount:       tx.Amount,
+		ImageHash:    tx.ImageHash,
+		Organization: tx.Organization,
+		ValidatorKey: tx.ValidatorKey,
+		SenderKey:    tx.SenderKey,
+		Signature:    tx.Signature,
+		Reference:    tx.Reference,
+		Model:        tx.Model,
+		Licence:      toProtoLicence(tx.Licence),
+	}
+}
+
+func fromProtoTransaction(tx *pb.Transaction) blockchain.Transaction {
+	return blockchain.Transaction{
+		Sender:       tx.GetSender(),
+		Receiver:     tx.GetReceiver(),
+		Amount:       tx.GetAmount(),
+		ImageHash:    tx.GetImageHash(),
+		Organization: tx.GetOrganization(),
+		ValidatorKey: tx.GetValidatorKey(),
+		SenderKey:    tx.GetSenderKey(),
+		Signature:    tx.GetSignature(),
+		Reference:    tx.GetReference(),
+		Model:        tx.GetModel(),
+		Licence:      fromProtoLicence(tx.GetLicence()),
+	}
+}
+
+func toProtoLicence(l *blockchain.Licence) *pb.Licence {
+	if l == nil {
+		return nil
+	}
+	return &pb.Licence{Id: l.ID, Commercial: l.Commercial, Attribution: l.Attribution}
+}
+
+func fromProtoLicence(l *pb.Licence) *blockchain.Licence {
+	if l == nil {
+		return nil
+	}
+	return &blockchain.Licence{ID: l.GetId(), Commercial: l.GetCommercial(), Attribution: l.GetAttribution()}
+}
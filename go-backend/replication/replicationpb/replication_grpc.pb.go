@@ -0,0 +1,301 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: replication/v1/replication.proto
+
+package replicationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ReplicationService_AnnounceBlock_FullMethodName    = "/replication.v1.ReplicationService/AnnounceBlock"
+	ReplicationService_GetBlocks_FullMethodName        = "/replication.v1.ReplicationService/GetBlocks"
+	ReplicationService_GetHeaders_FullMethodName       = "/replication.v1.ReplicationService/GetHeaders"
+	ReplicationService_RelayTransaction_FullMethodName = "/replication.v1.ReplicationService/RelayTransaction"
+	ReplicationService_GetChainStatus_FullMethodName   = "/replication.v1.ReplicationService/GetChainStatus"
+)
+
+// ReplicationServiceClient is the client API for ReplicationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ReplicationService is the node-to-node API. Nodes serve it on the same port as
+// the REST API (gRPC over cleartext HTTP/2), so a peer is still addressed by
+// its base URL.
+type ReplicationServiceClient interface {
+	// AnnounceBlock offers a newly mined block. A node that is behind answers
+	// accepted=false with its height and catches up with GetBlocks.
+	AnnounceBlock(ctx context.Context, in *AnnounceBlockRequest, opts ...grpc.CallOption) (*AnnounceBlockResponse, error)
+	// GetBlocks streams the blocks with from <= index <= to; to = 0 means up
+	// to the tip.
+	GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetBlocksResponse], error)
+	// GetHeaders returns index and hashes only, to find where two chains fork.
+	GetHeaders(ctx context.Context, in *GetHeadersRequest, opts ...grpc.CallOption) (*GetHeadersResponse, error)
+	// RelayTransaction adds a transaction to the receiver's mempool. Relayed
+	// transactions are not relayed again.
+	RelayTransaction(ctx context.Context, in *RelayTransactionRequest, opts ...grpc.CallOption) (*RelayTransactionResponse, error)
+	// GetChainStatus reports the receiver's tip.
+	GetChainStatus(ctx context.Context, in *GetChainStatusRequest, opts ...grpc.CallOption) (*GetChainStatusResponse, error)
+}
+
+type replicationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplicationServiceClient(cc grpc.ClientConnInterface) ReplicationServiceClient {
+	return &replicationServiceClient{cc}
+}
+
+func (c *replicationServiceClient) AnnounceBlock(ctx context.Context, in *AnnounceBlockRequest, opts ...grpc.CallOption) (*AnnounceBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnnounceBlockResponse)
+	err := c.cc.Invoke(ctx, ReplicationService_AnnounceBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replicationServiceClient) GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetBlocksResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReplicationService_ServiceDesc.Streams[0], ReplicationService_GetBlocks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetBlocksRequest, GetBlocksResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplicationService_GetBlocksClient = grpc.ServerStreamingClient[GetBlocksResponse]
+
+func (c *replicationServiceClient) GetHeaders(ctx context.Context, in *GetHeadersRequest, opts ...grpc.CallOption) (*GetHeadersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetHeadersResponse)
+	err := c.cc.Invoke(ctx, ReplicationService_GetHeaders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replicationServiceClient) RelayTransaction(ctx context.Context, in *RelayTransactionRequest, opts ...grpc.CallOption) (*RelayTransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelayTransactionResponse)
+	err := c.cc.Invoke(ctx, ReplicationService_RelayTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replicationServiceClient) GetChainStatus(ctx context.Context, in *GetChainStatusRequest, opts ...grpc.CallOption) (*GetChainStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetChainStatusResponse)
+	err := c.cc.Invoke(ctx, ReplicationService_GetChainStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReplicationServiceServer is the server API for ReplicationService service.
+// All implementations must embed UnimplementedReplicationServiceServer
+// for forward compatibility.
+//
+// ReplicationService is the node-to-node API. Nodes serve it on the same port as
+// the REST API (gRPC over cleartext HTTP/2), so a peer is still addressed by
+// its base URL.
+type ReplicationServiceServer interface {
+	// AnnounceBlock offers a newly mined block. A node that is behind answers
+	// accepted=false with its height and catches up with GetBlocks.
+	AnnounceBlock(context.Context, *AnnounceBlockRequest) (*AnnounceBlockResponse, error)
+	// GetBlocks streams the blocks with from <= index <= to; to = 0 means up
+	// to the tip.
+	GetBlocks(*GetBlocksRequest, grpc.ServerStreamingServer[GetBlocksResponse]) error
+	// GetHeaders returns index and hashes only, to find where two chains fork.
+	GetHeaders(context.Context, *GetHeadersRequest) (*GetHeadersResponse, error)
+	// RelayTransaction adds a transaction to the receiver's mempool. Relayed
+	// transactions are not relayed again.
+	RelayTransaction(context.Context, *RelayTransactionRequest) (*RelayTransactionResponse, error)
+	// GetChainStatus reports the receiver's tip.
+	GetChainStatus(context.Context, *GetChainStatusRequest) (*GetChainStatusResponse, error)
+	mustEmbedUnimplementedReplicationServiceServer()
+}
+
+// UnimplementedReplicationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReplicationServiceServer struct{}
+
+func (UnimplementedReplicationServiceServer) AnnounceBlock(context.Context, *AnnounceBlockRequest) (*AnnounceBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnnounceBlock not implemented")
+}
+func (UnimplementedReplicationServiceServer) GetBlocks(*GetBlocksRequest, grpc.ServerStreamingServer[GetBlocksResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetBlocks not implemented")
+}
+func (UnimplementedReplicationServiceServer) GetHeaders(context.Context, *GetHeadersRequest) (*GetHeadersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHeaders not implemented")
+}
+func (UnimplementedReplicationServiceServer) RelayTransaction(context.Context, *RelayTransactionRequest) (*RelayTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RelayTransaction not implemented")
+}
+func (UnimplementedReplicationServiceServer) GetChainStatus(context.Context, *GetChainStatusRequest) (*GetChainStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChainStatus not implemented")
+}
+func (UnimplementedReplicationServiceServer) mustEmbedUnimplementedReplicationServiceServer() {}
+func (UnimplementedReplicationServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeReplicationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplicationServiceServer will
+// result in compilation errors.
+type UnsafeReplicationServiceServer interface {
+	mustEmbedUnimplementedReplicationServiceServer()
+}
+
+func RegisterReplicationServiceServer(s grpc.ServiceRegistrar, srv ReplicationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedReplicationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ReplicationService_ServiceDesc, srv)
+}
+
+func _ReplicationService_AnnounceBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnounceBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServiceServer).AnnounceBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplicationService_AnnounceBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServiceServer).AnnounceBlock(ctx, req.(*AnnounceBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReplicationService_GetBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServiceServer).GetBlocks(m, &grpc.GenericServerStream[GetBlocksRequest, GetBlocksResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplicationService_GetBlocksServer = grpc.ServerStreamingServer[GetBlocksResponse]
+
+func _ReplicationService_GetHeaders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHeadersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServiceServer).GetHeaders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplicationService_GetHeaders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServiceServer).GetHeaders(ctx, req.(*GetHeadersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReplicationService_RelayTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RelayTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServiceServer).RelayTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplicationService_RelayTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServiceServer).RelayTransaction(ctx, req.(*RelayTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReplicationService_GetChainStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChainStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServiceServer).GetChainStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplicationService_GetChainStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServiceServer).GetChainStatus(ctx, req.(*GetChainStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ReplicationService_ServiceDesc is the grpc.ServiceDesc for ReplicationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReplicationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "replication.v1.ReplicationService",
+	HandlerType: (*ReplicationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AnnounceBlock",
+			Handler:    _ReplicationService_AnnounceBlock_Handler,
+		},
+		{
+			MethodName: "GetHeaders",
+			Handler:    _ReplicationService_GetHeaders_Handler,
+		},
+		{
+			MethodName: "RelayTransaction",
+			Handler:    _ReplicationService_RelayTransaction_Handler,
+		},
+		{
+			MethodName: "GetChainStatus",
+			Handler:    _ReplicationService_GetChainStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetBlocks",
+			Handler:       _ReplicationService_GetBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "replication/v1/replication.proto",
+}
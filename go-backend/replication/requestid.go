@@ -0,0 +1,53 @@
+package replication
+
+import (
+	"context"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// gRPC metadata keys are lower case
+const requestIDKey = "x-request-id"
+
+func clientRequestID(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+func clientStreamRequestID(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	if id := logging.RequestID(ctx); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, requestIDKey, id)
+	}
+	return ctx
+}
+
+func serverRequestID(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(incomingRequestID(ctx), req)
+}
+
+func serverStreamRequestID(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &requestIDStream{ServerStream: ss, ctx: incomingRequestID(ss.Context())})
+}
+
+// incomingRequestID carries the caller's request ID into ctx, or a new one
+func incomingRequestID(ctx context.Context) context.Context {
+	id := logging.NewRequestID()
+	if values := metadata.ValueFromIncomingContext(ctx, requestIDKey); len(values) > 0 && values[0] != "" {
+		id = values[0]
+	}
+	return logging.WithRequestID(ctx, id)
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
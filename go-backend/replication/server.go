@@ -0,0 +1,149 @@
+// Package replication is the gRPC transport between nodes: the server side
+// of ReplicationService, a client implementing blockchain.Transport, and
+// chain synchronisation built on both. The service is defined in
+// proto/replication/v1/replication.proto; run buf generate after changing it.
+package replication
+
+import (
+	"context"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	pb "github.com/Kami0rn/ProjectCPE/go-backend/replication/replicationpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxHeaders bounds one GetHeaders answer; callers page with from
+const maxHeaders = 2000
+
+// Server serves ReplicationService for one node
+type Server struct {
+	pb.UnimplementedReplicationServiceServer
+
+	Chain   *blockchain.Chain
+	Mempool *blockchain.Mempool
+
+	// OnBehind, if set, is called when a peer announces a block beyond our
+	// tip, so the node can catch up
+	OnBehind func()
+}
+
+// NewGRPCServer returns a gRPC server exposing s
+func NewGRPCServer(s *Server) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(serverRequestID),
+		grpc.ChainStreamInterceptor(serverStreamRequestID),
+	)
+	pb.RegisterReplicationServiceServer(server, s)
+	return server
+}
+
+// AcceptBlock appends a block received from a peer and drops its
+// transactions from the mempool
+func (s *Server) AcceptBlock(block blockchain.Block) error {
+	if err := s.Chain.AddBlock(block); err != nil {
+		return err
+	}
+	s.Mempool.Remove(block.Transactions...)
+	return nil
+}
+
+func (s *Server) AnnounceBlock(ctx context.Context, req *pb.AnnounceBlockRequest) (*pb.AnnounceBlockResponse, error) {
+	block, err := fromProtoBlock(req.GetBlock())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	accepted := s.AcceptBlock(block) == nil
+	tip := s.Chain.LastBlock()
+	if !accepted && block.Index > tip.Index && s.OnBehind != nil {
+		go s.OnBehind()
+	}
+	return &pb.AnnounceBlockResponse{Accepted: accepted, Height: int64(tip.Index)}, nil
+}
+
+func (s *Server) GetBlocks(req *pb.GetBlocksRequest, stream pb.ReplicationService_GetBlocksServer) error {
+	blocks := s.Chain.Blocks()
+	from, to, err := blockRange(req.GetFrom(), req.GetTo(), len(blocks))
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks[from : to+1] {
+		if err := stream.Send(&pb.GetBlocksResponse{Block: toProtoBlock(block)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) GetHeaders(ctx context.Context, req *pb.GetHeadersRequest) (*pb.GetHeadersResponse, error) {
+	blocks := s.Chain.Blocks()
+	from, to, err := blockRange(req.GetFrom(), req.GetTo(), len(blocks))
+	if err != nil {
+		return nil, err
+	}
+	to = min(to, from+maxHeaders-1)
+
+	resp := &pb.GetHeadersResponse{}
+	for _, block := range blocks[from : to+1] {
+		resp.Headers = append(resp.Headers, &pb.BlockHeader{
+			Index:    int64(block.Index),
+			Hash:     block.Hash,
+			PrevHash: block.PrevHash,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) RelayTransaction(ctx context.Context, req *pb.RelayTransactionRequest) (*pb.RelayTransactionResponse, error) {
+	if req.GetTransaction() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing transaction")
+	}
+	tx := fromProtoTransaction(req.GetTransaction())
+	if tx.Sender == "" || tx.Receiver == "" || tx.Amount != 0 {
+		return nil, status.Error(codes.InvalidArgument, "transaction needs a sender, a receiver and no amount")
+	}
+	if tx.IsValidatorChange() {
+		return nil, status.Error(codes.InvalidArgument, "validator set changes are not relayed")
+	}
+	// Only a block, checked against the rewards policy, may mint credits
+	if tx.IsReward() || (tx.Reference != "" && !tx.IsUsage()) {
+		return nil, status.Error(codes.InvalidArgument, "rewards are not relayed")
+	}
+	// Images are claimed by a training run, in the block holding its proof
+	if tx.ImageHash != "" || tx.SenderKey != "" || tx.Signature != "" {
+		return nil, status.Error(codes.InvalidArgument, "image transactions are not relayed")
+	}
+	if err := tx.ValidateModelRecord(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := blockchain.CheckLicence(s.Chain.Blocks(), tx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	s.Mempool.Add(tx)
+	return &pb.RelayTransactionResponse{}, nil
+}
+
+func (s *Server) GetChainStatus(ctx context.Context, req *pb.GetChainStatusRequest) (*pb.GetChainStatusResponse, error) {
+	tip := s.Chain.LastBlock()
+	return &pb.GetChainStatusResponse{
+		Height:      int64(tip.Index),
+		TipHash:     tip.Hash,
+		MempoolSize: int64(s.Mempool.Len()),
+	}, nil
+}
+
+// blockRange clamps [from, to] to a chain of length n; to = 0 means the tip
+func blockRange(from, to int64, n int) (int, int, error) {
+	last := int64(n - 1)
+	if to == 0 || to > last {
+		to = last
+	}
+	if from < 0 || from > to {
+		return 0, 0, status.Errorf(codes.OutOfRange, "range [%d, %d] outside the chain of height %d", from, to, last)
+	}
+	return int(from), int(to), nil
+}
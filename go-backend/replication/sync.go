@@ -0,0 +1,108 @@
+package replication
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+)
+
+// ErrNoCommonAncestor is returned when a peer's chain does not share our
+// genesis block
+var ErrNoCommonAncestor = errors.New("peer chain shares no block with ours")
+
+// Sync catches chain up with peer if the peer is ahead. It finds the last
+// block both chains share, streams the peer's blocks after it and adopts
+// the result if it is valid and longer. Transactions of our blocks that the
+// switch orphans go back into the mempool where they may wait on their own,
+// see requeueable. It returns the number of blocks adopted from the peer.
+func (c *Client) Sync(ctx context.Context, peer string, chain *blockchain.Chain, mempool *blockchain.Mempool) (int, error) {
+	status, err := c.ChainStatus(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+	ours := chain.Blocks()
+	if status.Height <= ours[len(ours)-1].Index {
+		return 0, nil
+	}
+
+	ancestor, err := c.commonAncestor(ctx, peer, ours)
+	if err != nil {
+		return 0, err
+	}
+	blocks, err := c.Blocks(ctx, peer, ancestor+1, status.Height)
+	if err != nil {
+		return 0, err
+	}
+
+	candidate := append(append([]blockchain.Block(nil), ours[:ancestor+1]...), blocks...)
+	if !chain.Replace(candidate) {
+		return 0, blockchain.ErrInvalidBlock
+	}
+
+	for _, orphan := range ours[ancestor+1:] {
+		mempool.Add(requeueable(chain, orphan.Transactions)...)
+	}
+	for _, block := range blocks {
+		mempool.Remove(block.Transactions...)
+	}
+	return len(blocks), nil
+}
+
+// requeueable picks the transactions of an orphaned block that may go back
+// into the mempool: transfers, and licence and usage records of a model
+// still on chain. Images and signed claims need the block's training proof,
+// and rewards and validator changes are the block producer's to make.
+func requeueable(chain *blockchain.Chain, txs []blockchain.Transaction) []blockchain.Transaction {
+	var keep []blockchain.Transaction
+	for _, tx := range txs {
+		switch {
+		case tx.IsTransfer():
+			keep = append(keep, tx)
+		case tx.IsLicence() || tx.IsUsage():
+			if _, ok := chain.BlockByHash(tx.Model); ok && tx.ValidateModelRecord() == nil && !chain.Credited(tx.Reference) {
+				keep = append(keep, tx)
+			}
+		}
+	}
+	return keep
+}
+
+// commonAncestor returns the index of the last block of ours that peer also
+// has. The usual case, peer extending our tip, takes a single call.
+func (c *Client) commonAncestor(ctx context.Context, peer string, ours []blockchain.Block) (int, error) {
+	tip := ours[len(ours)-1]
+	headers, err := c.Headers(ctx, peer, tip.Index, tip.Index)
+	if err != nil {
+		return 0, err
+	}
+	if len(headers) == 1 && headers[0].Hash == tip.Hash {
+		return tip.Index, nil
+	}
+
+	ancestor := -1
+	for from := 0; from < len(ours); {
+		headers, err := c.Headers(ctx, peer, from, len(ours)-1)
+		if err != nil {
+			return 0, err
+		}
+		if len(headers) == 0 {
+			break
+		}
+		for _, h := range headers {
+			if h.Index >= len(ours) || ours[h.Index].Hash != h.Hash {
+				return checkAncestor(ancestor)
+			}
+			ancestor = h.Index
+		}
+		from = headers[len(headers)-1].Index + 1
+	}
+	return checkAncestor(ancestor)
+}
+
+func checkAncestor(ancestor int) (int, error) {
+	if ancestor < 0 {
+		return 0, ErrNoCommonAncestor
+	}
+	return ancestor, nil
+}
@@ -0,0 +1,251 @@
+// Package rewards credits the owners of training images when their images
+// train a model or a model trained on them generates. Credits are reward
+// transactions (blockchain.Transaction.IsReward) whose Reference names the
+// event and the owner, so the chain takes each credit once. Balances are
+// derived from the Amount of the rewards on the chain. Every node checks the
+// rewards of a block against its own policy, see CheckRewards, so the nodes
+// of a network must share one.
+package rewards
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/blockchain"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+)
+
+// Policy decides how much an event pays and how it is split
+type Policy struct {
+	Split    string  // config.RewardPerImage or config.RewardEqual
+	Train    float64 // per training run
+	Generate float64 // per generated image
+}
+
+func FromConfig(cfg config.RewardsConfig) Policy {
+	return Policy{Split: cfg.Policy, Train: cfg.Train, Generate: cfg.Generate}
+}
+
+// TrainingReference names the training run of proof
+func TrainingReference(proof blockchain.TrainingProof) string {
+	return "train:" + proof.SampleHash
+}
+
+// GenerationReference names one generation from the model mined in the
+// block modelHash; key tells generations apart, such as the hash of the
+// generated image
+func GenerationReference(modelHash, key string) string {
+	return "generate:" + modelHash + ":" + key
+}
+
+// Owners returns the owner of each image hash: the uploader of the first
+// training run on chain that used it, or uploader for images new to the
+// chain. The uploader of a run is the owner of its training proof. Only
+// the images of blocks with a training proof count, see TrainingImages;
+// anyone can put an image hash in a transaction.
+func Owners(chain []blockchain.Block, imageHashes []string, uploader string) []string {
+	wanted := map[string]string{}
+	for _, hash := range imageHashes {
+		wanted[hash] = ""
+	}
+	for _, block := range chain {
+		for _, tx := range TrainingImages(block) {
+			if owner, ok := wanted[tx.ImageHash]; ok && owner == "" {
+				wanted[tx.ImageHash] = block.TrainingProof.Owner
+			}
+		}
+	}
+
+	owners := make([]string, len(imageHashes))
+	for i, hash := range imageHashes {
+		owners[i] = wanted[hash]
+		if owners[i] == "" {
+			owners[i] = uploader
+		}
+	}
+	return owners
+}
+
+// TrainingImages returns the image transactions of the training run of
+// block, none if the block holds no training proof or if the image hashes
+// of the block are not the dataset the proof commits to: a block
+// producer could otherwise slip in image hashes the run never trained on.
+func TrainingImages(block blockchain.Block) []blockchain.Transaction {
+	if block.TrainingProof == nil {
+		return nil
+	}
+	var txs []blockchain.Transaction
+	var imageHashes []string
+	for _, tx := range block.Transactions {
+		if tx.ImageHash != "" && !tx.IsReward() {
+			txs = append(txs, tx)
+			imageHashes = append(imageHashes, tx.ImageHash)
+		}
+	}
+	if root := blockchain.DatasetRoot(imageHashes); hex.EncodeToString(root[:]) != block.TrainingProof.DatasetRoot {
+		return nil
+	}
+	return txs
+}
+
+// Credits splits amount between the owners of the images of an event, one
+// owner per image, and returns the reward transactions paying them, sorted
+// by receiver. Each carries reference followed by ":" and its receiver.
+func (p Policy) Credits(reference string, amount float64, owners []string) []blockchain.Transaction {
+	if amount <= 0 || len(owners) == 0 {
+		return nil
+	}
+
+	images := map[string]int{}
+	for _, owner := range owners {
+		images[owner]++
+	}
+	receivers := make([]string, 0, len(images))
+	for owner := range images {
+		receivers = append(receivers, owner)
+	}
+	sort.Strings(receivers)
+
+	txs := make([]blockchain.Transaction, len(receivers))
+	for i, receiver := range receivers {
+		share := amount / float64(len(receivers))
+		if p.Split == config.RewardPerImage {
+			share = amount * float64(images[receiver]) / float64(len(owners))
+		}
+		txs[i] = blockchain.Transaction{
+			Sender:    blockchain.SenderRewards,
+			Receiver:  receiver,
+			Amount:    share,
+			Reference: reference + ":" + receiver,
+		}
+	}
+	return txs
+}
+
+// Entry is one Amount-bearing transaction of a user's statement
+type Entry struct {
+	BlockIndex   int       `json:"block_index"`
+	Timestamp    time.Time `json:"timestamp"`
+	Counterparty string    `json:"counterparty"` // the sender of a credit, the receiver of a debit
+	Amount       float64   `json:"amount"`       // negative for debits
+	Reference    string    `json:"reference,omitempty"`
+	Balance      float64   `json:"balance"` // after this entry
+}
+
+// Statement lists the transactions of chain moving credits to or from
+// username, oldest first
+func Statement(chain []blockchain.Block, username string) []Entry {
+	var entries []Entry
+	balance := 0.0
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			amount := Net(tx, username)
+			if amount == 0 {
+				continue
+			}
+			counterparty := tx.Sender
+			if amount < 0 {
+				counterparty = tx.Receiver
+			}
+			balance += amount
+			entries = append(entries, Entry{
+				BlockIndex:   block.Index,
+				Timestamp:    block.Timestamp,
+				Counterparty: counterparty,
+				Amount:       amount,
+				Reference:    tx.Reference,
+				Balance:      balance,
+			})
+		}
+	}
+	return entries
+}
+
+// Net is what tx moves to username. Only rewards move credits: the nodes
+// take no transfer carrying an Amount, so the Amount of any other
+// transaction is ignored.
+func Net(tx blockchain.Transaction, username string) float64 {
+	if tx.IsReward() && tx.Receiver == username {
+		return tx.Amount
+	}
+	return 0
+}
+
+// ErrUnpaidReward means a block holds a reward the policy does not pay
+var ErrUnpaidReward = errors.New("reward not paid by the rewards policy")
+
+// CheckRewards reports why a reward of block, which follows chain, is not
+// one the policy pays. A training reward must be one of the credits of the
+// training run of the block itself, a generation reward one of the
+// credits of a generation from a model on chain whose usage record is on
+// chain or in the block.
+func (p Policy) CheckRewards(chain []blockchain.Block, block blockchain.Block) error {
+	for _, tx := range block.Transactions {
+		if !tx.IsReward() {
+			continue
+		}
+		if !slices.Contains(p.expected(chain, block, tx), tx) {
+			return fmt.Errorf("%w: %s to %s", ErrUnpaidReward, tx.Reference, tx.Receiver)
+		}
+	}
+	return nil
+}
+
+// expected returns the credits of the event the reference of the reward
+// tx names, nil if it names none
+func (p Policy) expected(chain []blockchain.Block, block blockchain.Block, tx blockchain.Transaction) []blockchain.Transaction {
+	event, ok := strings.CutSuffix(tx.Reference, ":"+tx.Receiver)
+	if !ok {
+		return nil
+	}
+
+	if block.TrainingProof != nil && event == TrainingReference(*block.TrainingProof) {
+		if len(TrainingImages(block)) == 0 {
+			return nil
+		}
+		return p.Credits(event, p.Train, Owners(chain, ModelImages(block), block.TrainingProof.Owner))
+	}
+
+	rest, ok := strings.CutPrefix(event, "generate:")
+	if !ok {
+		return nil
+	}
+	modelHash, key, _ := strings.Cut(rest, ":")
+	if !usageRecorded(chain, block, blockchain.UsageReference(modelHash, key)) {
+		return nil
+	}
+	for _, model := range chain {
+		if model.Hash == modelHash {
+			return p.Credits(event, p.Generate, Owners(chain, ModelImages(model), ""))
+		}
+	}
+	return nil
+}
+
+// usageRecorded reports whether the usage record with reference is on
+// chain or in block, so a generation is only paid once it is recorded
+func usageRecorded(chain []blockchain.Block, block blockchain.Block, reference string) bool {
+	for _, b := range append(chain[:len(chain):len(chain)], block) {
+		for _, tx := range b.Transactions {
+			if tx.IsUsage() && tx.Reference == reference {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ModelImages returns the hashes of the images the model mined in block
+// was trained on
+func ModelImages(block blockchain.Block) []string {
+	var imageHashes []string
+	for _, tx := range TrainingImages(block) {
+		imageHashes = append(imageHashes, tx.ImageHash)
+	}
+	return imageHashes
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
+	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
+	"github.com/Kami0rn/ProjectCPE/go-backend/replication"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// NewHandler serves the REST API and the gRPC replication service of node h
+// on one port. gRPC requests are recognised by protocol and content type;
+// cleartext HTTP/2 is accepted so peers can reach gRPC without TLS.
+func NewHandler(cfg *config.Config, h *handlers.Handler) http.Handler {
+	router := SetupRouter(cfg, h)
+	grpcServer := replication.NewGRPCServer(h.Replication)
+
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
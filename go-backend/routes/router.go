@@ -1,8 +1,12 @@
 package routes
 
 import (
+	"log/slog"
 	"net/http"
 
+	apidoc "github.com/Kami0rn/ProjectCPE/go-backend/api"
+	"github.com/Kami0rn/ProjectCPE/go-backend/apperr"
+	"github.com/Kami0rn/ProjectCPE/go-backend/config"
 	"github.com/Kami0rn/ProjectCPE/go-backend/controllers"
 	"github.com/Kami0rn/ProjectCPE/go-backend/handlers"
 	"github.com/Kami0rn/ProjectCPE/go-backend/middleware"
@@ -13,7 +17,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*") // Allow all origins
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		// Handle preflight OPTIONS request
 		if c.Request.Method == "OPTIONS" {
@@ -25,34 +30,111 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// Request body limits for the upload endpoints
+const (
+	maxMineBody       = 200 << 20 // a training set of images
+	maxCheckImageBody = 20 << 20  // a single image
+	maxJSONBody       = 1 << 20
+)
+
+// rateLimit is the rate limit config of the route group name
+func rateLimit(name string, limits config.GroupLimits, store middleware.RateLimitStore) middleware.RateLimitConfig {
+	return middleware.RateLimitConfig{
+		Name:    name,
+		PerIP:   middleware.Limit{Rate: limits.PerIP.Rate, Burst: limits.PerIP.Burst},
+		PerUser: middleware.Limit{Rate: limits.PerUser.Rate, Burst: limits.PerUser.Burst},
+		Store:   store,
+	}
+}
+
+// SetupRouter wires the routes for the node h. Each router gets its own rate
+// limit buckets and login lockout state.
+func SetupRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
+	if cfg.Env == config.EnvProduction {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	// Only trusted proxies may name the client IP the rate limits, the
+	// login lockout and the audit trail key on. cfg.Validate checks them;
+	// should one still not parse, none is trusted.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		slog.Error("invalid trusted proxies, trusting none", "error", err)
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(middleware.RequestID(), middleware.AccessLog(), h.Metrics.Middleware(), middleware.Errors(), middleware.Recover())
+	r.NoRoute(func(c *gin.Context) {
+		apperr.Abort(c, apperr.New(apperr.CodeNotFound, "Route not found"))
+	})
+
+	authController := controllers.NewAuthController(h.Repo, cfg.JWTSecret)
+
+	store := middleware.NewMemoryRateLimitStore()
+	limits := cfg.RateLimits
+	authLimits := rateLimit("auth", limits.Auth, store)
+	apiLimits := rateLimit("api", limits.API, store)
+	mineLimits := rateLimit("mine", limits.Mine, store)
+	checkImageLimits := rateLimit("check-image", limits.CheckImage, store)
+	verifyProofLimits := rateLimit("verify-proof", limits.VerifyProof, store)
+	peerLimits := rateLimit("peer", limits.Peer, store)
 
 	// Apply CORS middleware
 	r.Use(CORSMiddleware())
 
 	auth := r.Group("/auth")
-
+	auth.Use(middleware.BodyLimit(maxJSONBody), middleware.RateLimiter(authLimits))
 	{
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
+		auth.POST("/register", authController.Register)
+		auth.POST("/login", authController.Login)
 	}
 
 	api := r.Group("/api")
-	api.Use(middleware.JWTAuthMiddleware())
+	api.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret), middleware.RateLimiter(apiLimits))
 	{
-		api.GET("/chain", handlers.GetChain)
-		api.POST("/transaction", handlers.AddTransaction)
-		api.POST("/mine", handlers.MineBlock)
-		api.GET("/me", controllers.Me)
-		api.POST("/check-image", handlers.CheckImage) // New endpoint
-		api.POST("/add-peer", handlers.AddPeer)
-		api.GET("/generate-image", handlers.GenerateImageHandler)
-		api.POST("/model", handlers.GetModel) // Add GetModel endpoint
+		api.GET("/chain", h.GetChain)
+		api.GET("/blocks/:index/anchor", h.GetBlockAnchor)
+		api.POST("/blocks/:index/verify-proof", middleware.RateLimiter(verifyProofLimits), h.VerifyProof)
+		api.POST("/transaction", middleware.BodyLimit(maxJSONBody), h.AddTransaction)
+		api.POST("/mine", middleware.BodyLimit(maxMineBody), middleware.RateLimiter(mineLimits), h.MineBlock)
+		api.GET("/me", authController.Me)
+		api.GET("/me/signing-key/challenge", authController.SigningKeyChallenge)
+		api.PUT("/me/signing-key", middleware.BodyLimit(maxJSONBody), authController.SetSigningKey)
+		api.GET("/users/:username/signing-key", authController.GetSigningKey)
+		api.GET("/users/:username/balance", h.GetBalance)
+		api.GET("/users/:username/statement", h.GetStatement)
+		api.POST("/check-image", middleware.BodyLimit(maxCheckImageBody), middleware.RateLimiter(checkImageLimits), h.CheckImage) // New endpoint
+		api.POST("/verify-image", middleware.BodyLimit(maxCheckImageBody), middleware.RateLimiter(checkImageLimits), h.VerifyImage)
+		api.POST("/inspect-image", middleware.BodyLimit(maxCheckImageBody), middleware.RateLimiter(checkImageLimits), h.InspectImage)
+		api.POST("/add-peer", middleware.BodyLimit(maxJSONBody), middleware.RequireAdmin(cfg.AdminUsers), h.AddPeer)
+		api.GET("/peers", h.ListPeers)
+		api.DELETE("/peers/:id", middleware.RequireAdmin(cfg.AdminUsers), h.RemovePeer)
+		api.GET("/generate-image", h.GenerateImageHandler)
+		api.POST("/model", h.GetModel) // Add GetModel endpoint
+		api.PUT("/model/licence", middleware.BodyLimit(maxJSONBody), h.SetLicence)
+		api.GET("/model/licence", h.GetLicence)
+		api.GET("/model/usage", h.GetModelUsage)
+		api.GET("/model/images", h.ListGeneratedImages)
+		api.GET("/generated-images/:hash", h.GetGeneratedImage)
+		api.GET("/generated-images/:hash/content", h.GetGeneratedImageContent)
+
+		api.POST("/orgs", middleware.BodyLimit(maxJSONBody), h.CreateOrganization)
+		api.GET("/orgs", h.ListOrganizations)
+		api.GET("/orgs/:org/members", h.ListMembers)
+		api.POST("/orgs/:org/members", middleware.BodyLimit(maxJSONBody), h.AddMember)
+		api.DELETE("/orgs/:org/members/:username", h.RemoveMember)
+
+		api.GET("/validators", h.GetValidators)
+
+		api.GET("/admin/audit", middleware.RequireAdmin(cfg.AdminUsers), h.ListAuditEvents)
+		api.POST("/admin/validators", middleware.BodyLimit(maxJSONBody), middleware.RequireAdmin(cfg.AdminUsers), h.ChangeValidator)
 	}
 
-	r.GET("/models", handlers.GetAllModels)
-	r.POST("/api/receive-block", handlers.ReceiveBlock)
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	r.GET("/metrics", gin.WrapH(h.Metrics.Handler()))
+	r.GET("/openapi.json", gin.WrapF(apidoc.ServeSpec))
+
+	r.GET("/models", h.GetAllModels)
+	r.POST("/api/receive-block", middleware.BodyLimit(maxCheckImageBody), middleware.RateLimiter(peerLimits), h.ReceiveBlock)
 
 	return r
 }
@@ -0,0 +1,204 @@
+package watermark
+
+import "errors"
+
+var errUncorrectable = errors.New("too many errors to correct")
+
+// Reed-Solomon over GF(2^8) with the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 (0x11d) and generator roots α^0 … α^(nsym-1).
+// A codeword c is read as the polynomial with c[0] as its highest
+// coefficient.
+var gfExp, gfLog = gfTables()
+
+func gfTables() (exp [512]byte, log [256]byte) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfPow returns α^n
+func gfPow(n int) byte {
+	n %= 255
+	if n < 0 {
+		n += 255
+	}
+	return gfExp[n]
+}
+
+// rsEncode appends nsym parity bytes to msg
+func rsEncode(msg []byte, nsym int) []byte {
+	gen := []byte{1}
+	for i := 0; i < nsym; i++ {
+		// gen *= (x - α^i)
+		next := make([]byte, len(gen)+1)
+		for j, g := range gen {
+			next[j] ^= g
+			next[j+1] ^= gfMul(g, gfPow(i))
+		}
+		gen = next
+	}
+
+	out := make([]byte, len(msg)+nsym)
+	copy(out, msg)
+	for i := range msg {
+		if coef := out[i]; coef != 0 {
+			for j := 1; j < len(gen); j++ {
+				out[i+j] ^= gfMul(gen[j], coef)
+			}
+		}
+	}
+	copy(out, msg)
+	return out
+}
+
+// syndromes returns S_j = c(α^j), all zero for a codeword
+func syndromes(c []byte, nsym int) []byte {
+	s := make([]byte, nsym)
+	for j := range s {
+		x := gfPow(j)
+		var y byte
+		for _, coef := range c {
+			y = gfMul(y, x) ^ coef
+		}
+		s[j] = y
+	}
+	return s
+}
+
+// rsDecode corrects up to nsym/2 byte errors in c in place and returns how
+// many it corrected
+func rsDecode(c []byte, nsym int) (int, error) {
+	s := syndromes(c, nsym)
+	clean := true
+	for _, v := range s {
+		clean = clean && v == 0
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Berlekamp-Massey: the error locator Λ(x) = Π (1 - X_k x), lowest
+	// coefficient first, where X_k = α^p for an error at power p
+	locator, prev := []byte{1}, []byte{1}
+	errs, shift, last := 0, 1, byte(1)
+	for n := 0; n < nsym; n++ {
+		d := s[n]
+		for i := 1; i <= errs && i < len(locator); i++ {
+			d ^= gfMul(locator[i], s[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		scale := gfMul(d, gfInv(last))
+		next := make([]byte, max(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for i, p := range prev {
+			next[i+shift] ^= gfMul(scale, p)
+		}
+		if 2*errs <= n {
+			prev, last, errs, shift = locator, d, n+1-errs, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errs > nsym {
+		return 0, errUncorrectable
+	}
+
+	// Chien search: an error at index i, power p = n-1-i, makes
+	// Λ(α^-p) vanish
+	var positions []int
+	var xs []byte
+	for i := range c {
+		p := len(c) - 1 - i
+		xInv := gfPow(-p)
+		var y byte
+		for k := len(locator) - 1; k >= 0; k-- {
+			y = gfMul(y, xInv) ^ locator[k]
+		}
+		if y == 0 {
+			positions = append(positions, i)
+			xs = append(xs, gfPow(p))
+		}
+	}
+	if len(positions) != errs {
+		return 0, errUncorrectable
+	}
+
+	// The magnitudes e_k solve Σ e_k X_k^j = S_j for j < errs, a
+	// Vandermonde system
+	m := make([][]byte, errs)
+	for j := range m {
+		m[j] = make([]byte, errs+1)
+		for k, x := range xs {
+			m[j][k] = gfPowOf(x, j)
+		}
+		m[j][errs] = s[j]
+	}
+	for col := 0; col < errs; col++ {
+		pivot := col
+		for pivot < errs && m[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == errs {
+			return 0, errUncorrectable
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv := gfInv(m[col][col])
+		for k := col; k <= errs; k++ {
+			m[col][k] = gfMul(m[col][k], inv)
+		}
+		for row := 0; row < errs; row++ {
+			if row != col && m[row][col] != 0 {
+				f := m[row][col]
+				for k := col; k <= errs; k++ {
+					m[row][k] ^= gfMul(f, m[col][k])
+				}
+			}
+		}
+	}
+	for k, i := range positions {
+		c[i] ^= m[k][errs]
+	}
+
+	for _, v := range syndromes(c, nsym) {
+		if v != 0 {
+			return 0, errUncorrectable
+		}
+	}
+	return errs, nil
+}
+
+// gfPowOf returns x^n
+func gfPowOf(x byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if x == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[x])*n)%255]
+}
@@ -0,0 +1,74 @@
+package watermark
+
+import "math"
+
+// plane is one channel of an image, row by row
+type plane struct {
+	w, h int
+	pix  []float64
+}
+
+func newPlane(w, h int) plane {
+	return plane{w: w, h: h, pix: make([]float64, w*h)}
+}
+
+// kernel is the weights one output sample gives the input samples
+type kernel struct {
+	index  []int
+	weight []float64
+}
+
+// kernels resamples n samples to m with a triangle filter, widened when
+// shrinking so every input sample counts, as an area average does
+func kernels(n, m int) []kernel {
+	scale := float64(n) / float64(m)
+	support := max(scale, 1)
+	ks := make([]kernel, m)
+	for i := range ks {
+		center := (float64(i)+0.5)*scale - 0.5
+		var sum float64
+		for j := int(math.Ceil(center - support)); j <= int(math.Floor(center+support)); j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+			ks[i].index = append(ks[i].index, min(max(j, 0), n-1))
+			ks[i].weight = append(ks[i].weight, w)
+			sum += w
+		}
+		for j := range ks[i].weight {
+			ks[i].weight[j] /= sum
+		}
+	}
+	return ks
+}
+
+// resize returns p resampled to w×h
+func (p plane) resize(w, h int) plane {
+	if p.w == w && p.h == h {
+		return p
+	}
+
+	rows := newPlane(w, p.h)
+	for x, k := range kernels(p.w, w) {
+		for y := 0; y < p.h; y++ {
+			var v float64
+			for j, i := range k.index {
+				v += k.weight[j] * p.pix[y*p.w+i]
+			}
+			rows.pix[y*w+x] = v
+		}
+	}
+
+	out := newPlane(w, h)
+	for y, k := range kernels(p.h, h) {
+		for x := 0; x < w; x++ {
+			var v float64
+			for j, i := range k.index {
+				v += k.weight[j] * rows.pix[i*w+x]
+			}
+			out.pix[y*w+x] = v
+		}
+	}
+	return out
+}
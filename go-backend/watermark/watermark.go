@@ -0,0 +1,259 @@
+// Package watermark hides a short payload in the luminance of an image so
+// that it survives JPEG recompression, resizing and screenshots. The image
+// is resampled to a Size×Size grid, cut into 8×8 blocks, and each payload
+// bit, Reed-Solomon coded, is repeated across the blocks by quantising three
+// low-frequency DCT coefficients of each block onto one of two dithered
+// lattices (QIM). Extraction votes on each bit over its copies and lets
+// the code repair what the votes got wrong.
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// Size is the side of the grid the mark is laid on. Smaller images are
+	// enlarged to it first, since a mark that fits them would not survive.
+	Size = 256
+	// PayloadSize is the bytes an image carries
+	PayloadSize = 16
+
+	paritySize = 16 // Reed-Solomon parity bytes, correcting 8 byte errors
+	codeBits   = 8 * (PayloadSize + paritySize)
+	blockSize  = 8
+	blocks     = Size / blockSize
+	// step is the QIM lattice spacing, in DCT units of 8-bit luminance.
+	// Wider survives heavier compression but shows more.
+	step = 28.0
+	// passes of embedding. Clipping and the resampling between the image
+	// and the grid leave part of a pass undone; the next pass measures the
+	// grid as Extract does and makes up for it.
+	passes = 4
+	// ditherSeed keeps the lattices of marks from other schemes apart
+	ditherSeed = 0x6d61726b2d763031
+)
+
+// ErrNotFound means the image carries no mark, or one too damaged to read
+var ErrNotFound = errors.New("no watermark found")
+
+// carriers are the DCT coefficients, (vertical, horizontal) frequency,
+// each block carries a bit in. Higher ones show less, but once an image is
+// scaled down JPEG quantises them away.
+var carriers = [...][2]int{{0, 1}, {1, 0}, {1, 1}}
+
+// Result is a payload read from an image
+type Result struct {
+	Payload []byte
+	// Corrected is how many bytes of the coded payload were damaged and
+	// repaired, out of the 8 the code can repair
+	Corrected int
+}
+
+// Embed returns img marked with payload. Images smaller than Size on a side
+// come back enlarged so that the shorter side is Size.
+func Embed(img image.Image, payload []byte) (*image.NRGBA, error) {
+	if len(payload) != PayloadSize {
+		return nil, fmt.Errorf("payload is %d bytes, want %d", len(payload), PayloadSize)
+	}
+	ch := channels(img)
+	w, h := ch[0].w, ch[0].h
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty image")
+	}
+	if w < Size || h < Size {
+		f := max(float64(Size)/float64(w), float64(Size)/float64(h))
+		w, h = max(Size, int(math.Round(float64(w)*f))), max(Size, int(math.Round(float64(h)*f)))
+		for i := range ch {
+			ch[i] = ch[i].resize(w, h)
+		}
+	}
+
+	code := rsEncode(payload, paritySize)
+	bits := make([]bool, codeBits)
+	for i := range bits {
+		bits[i] = code[i/8]&(0x80>>(i%8)) != 0
+	}
+	for pass := 0; pass < passes; pass++ {
+		delta := markDelta(luma(ch).resize(Size, Size), bits).resize(w, h)
+		addLuma(ch, delta)
+	}
+	return toNRGBA(ch), nil
+}
+
+// Extract reads the payload Embed hid in img, which may since have been
+// recompressed or resized
+func Extract(img image.Image) (Result, error) {
+	ch := channels(img)
+	if ch[0].w == 0 || ch[0].h == 0 {
+		return Result{}, ErrNotFound
+	}
+	grid := luma(ch).resize(Size, Size)
+
+	votes := make([]float64, codeBits)
+	for slot := 0; slot < blocks*blocks*len(carriers); slot++ {
+		c := coefficient(grid, slot)
+		votes[slot%codeBits] += soft(c, dither(slot))
+	}
+	code := make([]byte, PayloadSize+paritySize)
+	for i, v := range votes {
+		if v > 0 {
+			code[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	corrected, err := rsDecode(code, paritySize)
+	if err != nil {
+		return Result{}, ErrNotFound
+	}
+	return Result{Payload: code[:PayloadSize], Corrected: corrected}, nil
+}
+
+// basis[u][x] is the orthonormal 8-point DCT-II basis
+var basis = func() (b [blockSize][blockSize]float64) {
+	for u := range b {
+		a := math.Sqrt(2.0 / blockSize)
+		if u == 0 {
+			a = math.Sqrt(1.0 / blockSize)
+		}
+		for x := range b[u] {
+			b[u][x] = a * math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*blockSize))
+		}
+	}
+	return b
+}()
+
+// coefficient returns the DCT coefficient of grid that slot is carried in
+func coefficient(grid plane, slot int) float64 {
+	block, carrier := slot/len(carriers), carriers[slot%len(carriers)]
+	x0, y0 := blockSize*(block%blocks), blockSize*(block/blocks)
+	var c float64
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			c += basis[carrier[0]][y] * basis[carrier[1]][x] * grid.pix[(y0+y)*grid.w+x0+x]
+		}
+	}
+	return c
+}
+
+// markDelta returns the change to grid that moves every carrier onto the
+// lattice of its bit
+func markDelta(grid plane, bits []bool) plane {
+	delta := newPlane(grid.w, grid.h)
+	for slot := 0; slot < blocks*blocks*len(carriers); slot++ {
+		c := coefficient(grid, slot)
+		d := quantise(c, bits[slot%len(bits)], dither(slot)) - c
+		block, carrier := slot/len(carriers), carriers[slot%len(carriers)]
+		x0, y0 := blockSize*(block%blocks), blockSize*(block/blocks)
+		for y := 0; y < blockSize; y++ {
+			for x := 0; x < blockSize; x++ {
+				delta.pix[(y0+y)*delta.w+x0+x] += d * basis[carrier[0]][y] * basis[carrier[1]][x]
+			}
+		}
+	}
+	return delta
+}
+
+// quantise returns the point nearest c on the lattice of bit: offset by d,
+// and by half a step for a one
+func quantise(c float64, bit bool, d float64) float64 {
+	if bit {
+		d += step / 2
+	}
+	return math.Round((c-d)/step)*step + d
+}
+
+// soft is how sure c is a one, from -1 on the lattice of a zero to 1 on
+// that of a one
+func soft(c, d float64) float64 {
+	r := (c - d) / step
+	frac := r - math.Floor(r)
+	zero, one := min(frac, 1-frac), math.Abs(frac-0.5)
+	return 2 * (zero - one)
+}
+
+// dither returns the lattice offset of slot, in [0, step)
+func dither(slot int) float64 {
+	// splitmix64
+	z := uint64(slot) + ditherSeed + 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return step * float64(z>>11) / (1 << 53)
+}
+
+// BT.601 luma weights, as JPEG uses
+var lumaWeights = [3]float64{0.299, 0.587, 0.114}
+
+// channels splits img into R, G, B and alpha planes, not premultiplied
+func channels(img image.Image) [4]plane {
+	b := img.Bounds()
+	var ch [4]plane
+	for i := range ch {
+		ch[i] = newPlane(b.Dx(), b.Dy())
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			i := y*b.Dx() + x
+			ch[0].pix[i], ch[1].pix[i], ch[2].pix[i], ch[3].pix[i] = float64(c.R), float64(c.G), float64(c.B), float64(c.A)
+		}
+	}
+	return ch
+}
+
+func luma(ch [4]plane) plane {
+	p := newPlane(ch[0].w, ch[0].h)
+	for i := range p.pix {
+		for c, w := range lumaWeights {
+			p.pix[i] += w * ch[c].pix[i]
+		}
+	}
+	return p
+}
+
+// addLuma changes the luminance of each pixel by delta. What a channel
+// cannot take without clipping goes to the others.
+func addLuma(ch [4]plane, delta plane) {
+	for i, d := range delta.pix {
+		for range 3 {
+			up := d > 0
+			var free float64
+			for c, w := range lumaWeights {
+				if movable(ch[c].pix[i], up) {
+					free += w
+				}
+			}
+			if math.Abs(d) < 1e-3 || free == 0 {
+				break
+			}
+			move := d / free
+			for c, w := range lumaWeights {
+				if v := ch[c].pix[i]; movable(v, up) {
+					ch[c].pix[i] = min(max(v+move, 0), 255)
+					d -= w * (ch[c].pix[i] - v)
+				}
+			}
+		}
+	}
+}
+
+// movable reports whether a channel at v has room to go up, or down
+func movable(v float64, up bool) bool {
+	if up {
+		return v < 255
+	}
+	return v > 0
+}
+
+func toNRGBA(ch [4]plane) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, ch[0].w, ch[0].h))
+	for i := range ch[0].pix {
+		for c := range ch {
+			img.Pix[4*i+c] = uint8(min(max(math.Round(ch[c].pix[i]), 0), 255))
+		}
+	}
+	return img
+}
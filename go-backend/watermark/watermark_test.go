@@ -0,0 +1,107 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestWatermarkSurvivesRecompressionAndResizing(t *testing.T) {
+	payload := []byte("0123456789abcdef")
+	marked, err := Embed(texturedImage(300, 200), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := marked.Bounds(); b.Dx() != 384 || b.Dy() != 256 {
+		t.Fatalf("a 300x200 image came back %v, want enlarged to 384x256", b.Size())
+	}
+	small, err := Embed(texturedImage(64, 64), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		img  image.Image
+	}{
+		{"as embedded", marked},
+		{"JPEG quality 75", recompress(t, marked, 75)},
+		{"JPEG quality 50", recompress(t, marked, 50)},
+		{"scaled to 0.6", scale(marked, 0.6)},
+		{"scaled to 1.7", scale(marked, 1.7)},
+		{"screenshot", recompress(t, scale(marked, 0.75), 80)},
+		{"enlarged from 64x64, JPEG quality 75", recompress(t, small, 75)},
+	} {
+		mark, err := Extract(c.img)
+		if err != nil || !bytes.Equal(mark.Payload, payload) {
+			t.Errorf("%s: extracted %q, %v", c.name, mark.Payload, err)
+		}
+	}
+
+	if _, err := Extract(recompress(t, texturedImage(300, 200), 75)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unmarked image: %v, want ErrNotFound", err)
+	}
+	if _, err := Embed(marked, payload[:8]); err == nil {
+		t.Fatal("embedding a short payload succeeded")
+	}
+}
+
+// texturedImage is a gradient with noise, closer to a photograph than a
+// flat colour
+func texturedImage(w, h int) image.Image {
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8(108 + rng.IntN(40)), 255})
+		}
+	}
+	return img
+}
+
+func recompress(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// scale resizes img by f with bilinear interpolation, as an image viewer
+// or a screenshot of one would
+func scale(img image.Image, f float64) image.Image {
+	b := img.Bounds()
+	w, h := int(float64(b.Dx())*f), int(float64(b.Dy())*f)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	at := func(x, y int) [4]float64 {
+		x, y = min(max(x, 0), b.Dx()-1), min(max(y, 0), b.Dy()-1)
+		r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return [4]float64{float64(r >> 8), float64(g >> 8), float64(bl >> 8), float64(a >> 8)}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := (float64(x)+0.5)/f-0.5, (float64(y)+0.5)/f-0.5
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			p00, p10, p01, p11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+			var c [4]uint8
+			for i := range c {
+				v := (p00[i]*(1-fx)+p10[i]*fx)*(1-fy) + (p01[i]*(1-fx)+p11[i]*fx)*fy
+				c[i] = uint8(math.Round(v))
+			}
+			out.Set(x, y, color.RGBA{c[0], c[1], c[2], c[3]})
+		}
+	}
+	return out
+}
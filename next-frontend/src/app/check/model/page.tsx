@@ -36,7 +36,7 @@ function CheckModelPage() {
       const formData = new FormData();
       formData.append("image", selectedFile);
 
-      const response = await axios.post("http://localhost:5000/extract", formData, {
+      const response = await axios.post("http://localhost:8080/api/verify-image", formData, {
         headers: {
           Authorization: `Bearer ${token}`,
         },
@@ -44,16 +44,19 @@ function CheckModelPage() {
 
       const data = response.data;
 
-      if (data.block_hash) {
-        const segments = data.block_hash.split("/").filter(Boolean);
-        if (segments.length === 2) {
-          setCreator(segments[0]);
-          setModelName(segments[1]);
-          setModelPath(`/generate/${segments[0]}/${segments[1]}`);
-          setModalVisible(true);
-        } else {
-          setErrorMessage("Invalid block hash format.");
-        }
+      if (!data.watermarked) {
+        setErrorMessage("No watermark found in this image.");
+      } else if (!data.model) {
+        setErrorMessage(
+          data.block_index >= 0
+            ? `Generated by the model of block ${data.block_index}.`
+            : "The watermarked block is not on this node's chain."
+        );
+      } else {
+        setCreator(data.model.created_by);
+        setModelName(data.model.name);
+        setModelPath(`/generate/${data.model.created_by}/${data.model.name}`);
+        setModalVisible(true);
       }
     } catch (error: any) {
       if (error.response && error.response.data.error) {
@@ -345,6 +345,28 @@ func (bc *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("Transaction does not exist")
 }
 
+// FindTransactionBlock returns the block on the main chain that holds the
+// transaction ID
+func (bc *BlockChain) FindTransactionBlock(ID []byte) (*Block, error) {
+	iter := bc.Iterator()
+
+	for {
+		block := iter.Next()
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return block, nil
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return nil, errors.New("Transaction does not exist")
+}
+
 func (bc *BlockChain) SignTransaction(tx *Transaction, privateKey *ecdsa.PrivateKey) {
 	if tx.IsCoinbase() {
 		return
@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -64,7 +65,7 @@ func CoinbaseTx(to, data string) *Transaction {
 		if err != nil {
 			log.Panic(err)
 		}
-		data = fmt.Sprint("%x", randData)
+		data = fmt.Sprintf("%x", randData)
 	}
 
 	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
@@ -109,20 +110,79 @@ func NewTransaction(w *wallet.Wallet, to string, amount int, UTXO *UTXOSet) *Tra
 	tx.ID = tx.Hash()
 
 	// Reconstruct the private key from w.PrivateKey
-	privateKey := ecdsa.PrivateKey{
-		PublicKey: ecdsa.PublicKey{
-			Curve: elliptic.P256(),
-			X:     nil,
-			Y:     nil,
-		},
-		D: new(big.Int).SetBytes(w.PrivateKey),
-	}
+	privateKey := signingKey(w)
 
 	// Pass the pointer to the private key
 	UTXO.BlockChain.SignTransaction(&tx, &privateKey)
 
 	return &tx
 }
+// SpentOutputs lists the outputs txs spend, output indexes by hex
+// transaction ID
+func SpentOutputs(txs []Transaction) map[string][]int {
+	spent := make(map[string][]int)
+	for _, tx := range txs {
+		for _, in := range tx.Inputs {
+			txID := hex.EncodeToString(in.ID)
+			spent[txID] = append(spent[txID], in.Out)
+		}
+	}
+	return spent
+}
+
+// NewDataTransaction records data on the chain in a data output. The
+// transaction spends at least one coin of w and returns all of it as change,
+// so it only needs a funded wallet. Outputs spent by the pending
+// transactions, not yet in the UTXO set, are left alone.
+func NewDataTransaction(w *wallet.Wallet, data []byte, UTXO *UTXOSet, pending []Transaction) (*Transaction, error) {
+	dataOut, err := NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+	acc, validOutputs := UTXO.FindSpendableOutputsExcept(pubKeyHash, 1, SpentOutputs(pending))
+	if acc < 1 {
+		return nil, errors.New("not enough funds to carry data")
+	}
+
+	var inputs []TxInput
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			return nil, err
+		}
+		for _, out := range outs {
+			inputs = append(inputs, TxInput{txID, out, nil, w.PublicKey})
+		}
+	}
+
+	from := fmt.Sprintf("%s", w.Address())
+	outputs := []TxOutput{*dataOut, *NewTXOutput(acc, from)}
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+
+	privateKey := signingKey(w)
+	UTXO.BlockChain.SignTransaction(&tx, &privateKey)
+
+	return &tx, nil
+}
+
+// signingKey rebuilds the key of w. crypto/ecdsa needs the public point as
+// well as D to sign.
+func signingKey(w *wallet.Wallet) ecdsa.PrivateKey {
+	half := len(w.PublicKey) / 2
+	return ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(w.PublicKey[:half]),
+			Y:     new(big.Int).SetBytes(w.PublicKey[half:]),
+		},
+		D: new(big.Int).SetBytes(w.PrivateKey),
+	}
+}
+
 func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
 }
@@ -166,7 +226,7 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	}
 
 	for _, out := range tx.Outputs {
-		outputs = append(outputs, TxOutput{out.Value, out.PubKeyHash})
+		outputs = append(outputs, TxOutput{out.Value, out.PubKeyHash, out.Data})
 	}
 
 	txCopy := Transaction{tx.ID, inputs, outputs}
@@ -237,6 +297,9 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
 		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+		if output.IsData() {
+			lines = append(lines, fmt.Sprintf("       Data:   %x", output.Data))
+		}
 	}
 
 	return strings.Join(lines, "\n")
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Kami0rn/golang-blockchain/wallet"
+)
+
+// newTestChain starts a chain in a temporary directory, its genesis coinbase
+// paying to w
+func newTestChain(t *testing.T, w *wallet.Wallet) *BlockChain {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.MkdirAll("tmp", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := InitBlockChain(string(w.Address()), "test")
+	t.Cleanup(func() { chain.Database.Close() })
+	UTXOSet{chain}.Reindex()
+	return chain
+}
+
+func TestNewDataTransactionSkipsOutputsSpentInThePool(t *testing.T) {
+	w := wallet.MakeWallet()
+	chain := newTestChain(t, w)
+	UTXO := UTXOSet{chain}
+
+	first, err := NewDataTransaction(w, []byte("first"), &UTXO, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain.VerifyTransaction(first) {
+		t.Fatal("data transaction does not verify")
+	}
+
+	// The genesis coinbase is the wallet's only output and the first
+	// transaction, still pending, spends it
+	pending := []Transaction{*first}
+	if _, err := NewDataTransaction(w, []byte("second"), &UTXO, pending); err == nil {
+		t.Fatal("second data transaction spends the output of a pending one")
+	}
+
+	// Once the first is mined, the wallet can pay for the second
+	chain.MineBlock([]*Transaction{first, CoinbaseTx(string(w.Address()), "")})
+	UTXO.Reindex()
+	second, err := NewDataTransaction(w, []byte("second"), &UTXO, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain.VerifyTransaction(second) {
+		t.Fatal("second data transaction does not verify")
+	}
+}
@@ -3,6 +3,7 @@ package blockchain
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 
 	"github.com/Kami0rn/golang-blockchain/wallet"
 )
@@ -10,8 +11,14 @@ import (
 type TxOutput struct {
 	Value      int
 	PubKeyHash []byte
+	// Data is set on data outputs only, see NewDataOutput
+	Data []byte
 }
 
+// MaxDataSize bounds the payload of a data output
+const MaxDataSize = 80
+
+
 type TxOutputs struct {
 	Outputs []TxOutput
 }
@@ -24,12 +31,25 @@ type TxInput struct {
 }
 
 func NewTXOutput(value int, address string) *TxOutput {
-	txo := &TxOutput{value, nil}
+	txo := &TxOutput{value, nil, nil}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
+// NewDataOutput returns an output that carries data instead of coins. It
+// has no value and no owner, so it can never be spent.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data) == 0 || len(data) > MaxDataSize {
+		return nil, fmt.Errorf("data output must hold 1 to %d bytes, got %d", MaxDataSize, len(data))
+	}
+	return &TxOutput{0, nil, data}, nil
+}
+
+func (out *TxOutput) IsData() bool {
+	return len(out.Data) > 0
+}
+
 func (outs TxOutputs) Serialize() []byte {
 	var buffer bytes.Buffer
 	encode := gob.NewEncoder(&buffer)
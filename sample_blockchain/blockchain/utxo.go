@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"log"
+	"slices"
 
 	"github.com/dgraph-io/badger"
 	// "golang.org/x/telemetry/counter"
@@ -19,6 +20,13 @@ type UTXOSet struct {
 }
 
 func (u *UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+	return u.FindSpendableOutputsExcept(pubKeyHash, amount, nil)
+}
+
+// FindSpendableOutputsExcept is FindSpendableOutputs skipping the outputs in
+// spent, output indexes by hex transaction ID, such as SpentOutputs of the
+// transactions waiting in the memory pool
+func (u *UTXOSet) FindSpendableOutputsExcept(pubKeyHash []byte, amount int, spent map[string][]int) (int, map[string][]int) {
 	unspentOuts := make(map[string][]int)
 	accumulated := 0
 	db := u.BlockChain.Database
@@ -43,7 +51,7 @@ func (u *UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[
 			outs := DeserializeOutputs(v)
 
 			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount && !slices.Contains(spent[txID], outIdx) {
 					accumulated += out.Value
 					unspentOuts[txID] = append(unspentOuts[txID], outIdx)
 				}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +12,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync"
 	"syscall"
 
 	// "github.com/golang-jwt/jwt/v4/request"
@@ -18,6 +20,7 @@ import (
 	"github.com/vrecan/death/v3"
 
 	"github.com/Kami0rn/golang-blockchain/blockchain"
+	"github.com/Kami0rn/golang-blockchain/wallet"
 )
 
 const (
@@ -28,10 +31,16 @@ const (
 
 var (
 	nodeAddress    string
+	nodeId         string
 	mineAddress   string
 	KnownNodes     = []string{"localhost:3000"}
 	blockInTransit = [][]byte{}
 	memoryPool     = make(map[string]blockchain.Transaction)
+
+	// poolMu guards memoryPool, which connections share with mining in the
+	// background, and mining lets one MineTx run at a time
+	poolMu sync.Mutex
+	mining sync.Mutex
 )
 
 type Addr struct {
@@ -70,6 +79,36 @@ type Version struct {
 	AddrFrom   string
 }
 
+// Anchor asks a mining node to record Data in a data output funded by its
+// miner wallet. Unlike the commands above it is answered on the same
+// connection, with an "anchored" AnchorReply; the client closes its side
+// of the connection after the request.
+type Anchor struct {
+	AddrFrom string
+	Data     []byte
+}
+
+type AnchorReply struct {
+	TxID  []byte
+	Error string
+}
+
+// TxStatus asks where a transaction is. It is answered on the same
+// connection with a "txinfo" TxInfo.
+type TxStatus struct {
+	AddrFrom string
+	ID       []byte
+}
+
+type TxInfo struct {
+	Found      bool // in a block of this node's chain
+	Pending    bool // waiting in the memory pool
+	BlockHash  []byte
+	Height     int
+	BestHeight int
+	Data       [][]byte // the data outputs of the transaction, if found or pending
+}
+
 func CmdToBytes (cmd string) []byte {
 	var bytes [commandLength]byte
 
@@ -211,7 +250,7 @@ func HandleInv(request []byte, chain *blockchain.BlockChain) {
 		log.Panic(err)
 	}
 
-	fmt.Println("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
+	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
 		blockInTransit = payload.Items
@@ -231,7 +270,10 @@ func HandleInv(request []byte, chain *blockchain.BlockChain) {
 	if payload.Type == "tx" {
 		txID := payload.Items[0]
 
-		if memoryPool[hex.EncodeToString(txID)].ID == nil {
+		poolMu.Lock()
+		known := memoryPool[hex.EncodeToString(txID)].ID != nil
+		poolMu.Unlock()
+		if !known {
 			SendGetData(payload.AddrFrom,"tx", txID)
 		}
 	}
@@ -305,7 +347,9 @@ func HandleGetData(request []byte, chain *blockchain.BlockChain) {
 
 	if payload.Type == "tx" {
 		txID := hex.EncodeToString(payload.ID)
+		poolMu.Lock()
 		tx := memoryPool[txID]
+		poolMu.Unlock()
 
 		SendTx(payload.AddrFrom, &tx)
 	}
@@ -324,9 +368,12 @@ func HandleTx(request []byte, chain *blockchain.BlockChain) {
 
 	txData := payload.Transaction
 	tx := blockchain.DeserializeTransaction(txData)
+	poolMu.Lock()
 	memoryPool[hex.EncodeToString(tx.ID)] = tx
+	pending := len(memoryPool)
+	poolMu.Unlock()
 
-	fmt.Printf("%s, %d\n", nodeAddress, len(memoryPool))
+	fmt.Printf("%s, %d\n", nodeAddress, pending)
 
 	if nodeAddress == KnownNodes[0] {
 		for _, node := range KnownNodes {
@@ -335,15 +382,126 @@ func HandleTx(request []byte, chain *blockchain.BlockChain) {
 			}
 		}
 	} else {
-		if len(memoryPool) >= 2 && len(mineAddress) > 0 {
+		if pending >= 2 && len(mineAddress) > 0 {
+			mining.Lock()
+			defer mining.Unlock()
 			MineTx(chain)
 		}
 	}
 }
 
+// SendReply answers a request on the connection it came in on
+func SendReply(conn net.Conn, cmd string, data interface{}) {
+	request := append(CmdToBytes(cmd), GobEncode(data)...)
+
+	if _, err := conn.Write(request); err != nil {
+		fmt.Printf("failed to reply %s: %s\n", cmd, err)
+	}
+}
+
+func HandleAnchor(conn net.Conn, request []byte, chain *blockchain.BlockChain) {
+	var buff bytes.Buffer
+	var payload Anchor
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Build and queue under one lock, so that no two anchors spend the
+	// same output
+	poolMu.Lock()
+	tx, err := anchorTx(payload.Data, chain)
+	if err == nil {
+		memoryPool[hex.EncodeToString(tx.ID)] = *tx
+	}
+	poolMu.Unlock()
+	if err != nil {
+		SendReply(conn, "anchored", AnchorReply{Error: err.Error()})
+		return
+	}
+	fmt.Printf("Anchoring %x in transaction %x\n", payload.Data, tx.ID)
+
+	// Answer and hang up before the proof of work, which runs in the
+	// background; the client polls txstatus
+	SendReply(conn, "anchored", AnchorReply{TxID: tx.ID})
+	conn.Close()
+	go func() {
+		mining.Lock()
+		defer mining.Unlock()
+		MineTx(chain)
+	}()
+}
+
+// anchorTx builds a data transaction paid for by the miner wallet. The
+// caller holds poolMu.
+func anchorTx(data []byte, chain *blockchain.BlockChain) (*blockchain.Transaction, error) {
+	if len(mineAddress) == 0 {
+		return nil, errors.New("node is not mining")
+	}
+
+	wallets, err := wallet.CreateWallets(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := wallets.Wallets[mineAddress]
+	if !ok {
+		return nil, errors.New("miner address is not in this node's wallet")
+	}
+
+	var pending []blockchain.Transaction
+	for _, tx := range memoryPool {
+		pending = append(pending, tx)
+	}
+	UTXOSet := blockchain.UTXOSet{chain}
+	return blockchain.NewDataTransaction(w, data, &UTXOSet, pending)
+}
+
+func HandleTxStatus(conn net.Conn, request []byte, chain *blockchain.BlockChain) {
+	var buff bytes.Buffer
+	var payload TxStatus
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	info := TxInfo{BestHeight: chain.GetBestHeight()}
+	var tx blockchain.Transaction
+	if block, err := chain.FindTransactionBlock(payload.ID); err == nil {
+		info.Found = true
+		info.BlockHash = block.Hash
+		info.Height = block.Height
+		for _, t := range block.Transactions {
+			if bytes.Equal(t.ID, payload.ID) {
+				tx = *t
+			}
+		}
+	} else {
+		poolMu.Lock()
+		tx = memoryPool[hex.EncodeToString(payload.ID)]
+		info.Pending = tx.ID != nil
+		poolMu.Unlock()
+	}
+	// The client checks the anchored data rather than take our word for it
+	for _, out := range tx.Outputs {
+		if out.IsData() {
+			info.Data = append(info.Data, out.Data)
+		}
+	}
+
+	SendReply(conn, "txinfo", info)
+}
+
+// MineTx mines the memory pool into a block. The caller holds mining.
 func MineTx(chain *blockchain.BlockChain) {
 	var txs []*blockchain.Transaction
 
+	poolMu.Lock()
 	for id := range memoryPool {
 		fmt.Printf("tx: %s\n", memoryPool[id].ID)
 		tx := memoryPool[id]
@@ -351,6 +509,7 @@ func MineTx(chain *blockchain.BlockChain) {
 			txs = append(txs, &tx)
 		}
 	}
+	poolMu.Unlock()
 
 	if len(txs) == 0 {
 		fmt.Println("All transaction are invalid")
@@ -366,10 +525,13 @@ func MineTx(chain *blockchain.BlockChain) {
 
 	fmt.Println("New Block mined")
 
+	poolMu.Lock()
 	for _, tx := range txs {
 		txID := hex.EncodeToString(tx.ID)
 		delete(memoryPool, txID)
 	}
+	pending := len(memoryPool)
+	poolMu.Unlock()
 
 	for _, node := range KnownNodes {
 		if node != nodeAddress {
@@ -377,7 +539,7 @@ func MineTx(chain *blockchain.BlockChain) {
 		}
 	}
 
-	if len(memoryPool) > 0 {
+	if pending > 0 {
 		MineTx(chain)
 	}
 }
@@ -417,6 +579,10 @@ func HandleConnection(conn net.Conn, chain *blockchain.BlockChain){
 		HandleTx(req, chain)
 	case "version" :
 		HandleVersion(req, chain)
+	case "anchor":
+		HandleAnchor(conn, req, chain)
+	case "txstatus":
+		HandleTxStatus(conn, req, chain)
 	default:
 		fmt.Println("Unknown command")
 	}
@@ -472,7 +638,8 @@ func HandleVersion(request []byte, chain *blockchain.BlockChain) {
 	
 func StartServer(nodeID, minerAddress string) {
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
-	minerAddress = minerAddress
+	nodeId = nodeID
+	mineAddress = minerAddress
 	ln, err := net.Listen(protocol,nodeAddress)
 	if err != nil {
 		log.Panic(err)